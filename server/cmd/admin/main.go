@@ -0,0 +1,68 @@
+// Bootstraps an admin account.
+//
+// Promotes existing user:
+//
+//	go run ./cmd/admin -username alice
+//
+// Creates new admin user when it doesn't exist yet, the password is read from ADMIN_PASSWORD only,
+// a flag would end up in shell history and the process list:
+//
+//	read -rs ADMIN_PASSWORD && export ADMIN_PASSWORD
+//	go run ./cmd/admin -username alice -email alice@example.com
+package main
+
+import (
+	"context"
+	"flag"
+	"foodgenie/internal/app"
+	"foodgenie/internal/config"
+	"foodgenie/internal/database"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"log"
+	"os"
+)
+
+func main() {
+	username := flag.String("username", "", "username of the admin (required)")
+	email := flag.String("email", "", "email, required when the user doesn't exist yet")
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	if *username == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(configFlags)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := database.InitDatabase(cfg.DB)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	application := app.Init(db, &cfg.App)
+	ctx := context.Background()
+
+	user, err := application.UserService.GetUserByUsername(*username)
+	if err != nil {
+		password := os.Getenv("ADMIN_PASSWORD")
+		if *email == "" || password == "" {
+			log.Fatalf("User %s not found, pass -email and set ADMIN_PASSWORD to create it", *username)
+		}
+		user, err = application.UserService.CreateUser(ctx, &dto.RegisterUserRequestDTO{
+			Username:  *username,
+			Email:     *email,
+			Password:  password,
+			FirstName: *username,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create user: %v", err)
+		}
+		log.Printf("Created user %s", user.Username)
+	}
+	if _, err := application.UserService.UpdateUserRole(ctx, user.ID, models.RoleAdmin); err != nil {
+		log.Fatalf("Failed to grant admin role: %v", err)
+	}
+	log.Printf("User %s is now %s", user.Username, models.RoleAdmin)
+}
@@ -0,0 +1,125 @@
+// Imports a food composition database into the ingredient catalog.
+//
+// USDA FoodData Central JSON download or directory of its CSV download:
+//
+//	go run ./cmd/foodimport -file FoodData_Central_sr_legacy_food_json_2018-04.json
+//	go run ./cmd/foodimport -file FoodData_Central_foundation_food_csv_2024-04-18
+//
+// Generic CSV with name and calories (kcal) or energy_kj columns, nutrients per 100 g, the source defaults
+// to the file name, a column named just energy is rejected as its unit is unknown:
+//
+//	go run ./cmd/foodimport -file nutrients.csv -source nutrients
+//
+// Importing again updates ingredients of the same source and record ID. Records whose name is used by
+// another ingredient are reported as conflicts, -adopt links them to ingredients added by hand instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"foodgenie/internal/app"
+	"foodgenie/internal/config"
+	"foodgenie/internal/database"
+	"foodgenie/internal/foodimport"
+	"foodgenie/internal/services"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	file := flag.String("file", "", "JSON or CSV file, or directory of FoodData Central CSV download (required)")
+	format := flag.String("format", "", "fdc-json, fdc-csv or csv, detected from the file when empty")
+	source := flag.String("source", "", "source stored with ingredients, usda-fdc for FoodData Central, file name for CSV by default")
+	dryRun := flag.Bool("dry-run", false, "report changes without saving them")
+	adopt := flag.Bool("adopt", false, "link ingredients added by hand with the same name instead of reporting conflicts")
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = detectFormat(*file)
+	}
+
+	records, rowErrors, defaultSource, err := readRecords(*file, *format)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *file, err)
+	}
+	for _, rowErr := range rowErrors {
+		log.Printf("Skipped %v", rowErr)
+	}
+	if *source == "" {
+		*source = defaultSource
+	}
+	log.Printf("Read %d records from %s, skipped %d", len(records), *file, len(rowErrors))
+
+	cfg, err := config.LoadConfig(configFlags)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := database.InitDatabase(cfg.DB)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	application := app.Init(db, &cfg.App)
+
+	report, err := application.IngredientService.ImportIngredients(context.Background(), *source, records, services.IngredientImportOptions{
+		DryRun: *dryRun,
+		Adopt:  *adopt,
+	})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	for _, conflict := range report.Conflicts {
+		if conflict.ExistingID != nil {
+			log.Printf("Conflict %s %q: %s (%s)", conflict.SourceID, conflict.Name, conflict.Reason, conflict.ExistingID)
+			continue
+		}
+		log.Printf("Conflict %s %q: %s", conflict.SourceID, conflict.Name, conflict.Reason)
+	}
+	for _, failure := range report.Failed {
+		log.Printf("Failed %s %q: %s", failure.SourceID, failure.Name, failure.Reason)
+	}
+	prefix := ""
+	if report.DryRun {
+		prefix = "Dry run: "
+	}
+	log.Printf("%s%s created %d, updated %d, unchanged %d, conflicts %d, failed %d, recipe recalculations queued %d",
+		prefix, report.Source, report.Created, report.Updated, report.Unchanged, len(report.Conflicts), len(report.Failed), report.Recalculations)
+}
+
+func detectFormat(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return "fdc-csv"
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return "fdc-json"
+	}
+	return "csv"
+}
+
+// records of the file together with the source they are stored under by default
+func readRecords(path string, format string) ([]foodimport.Record, []foodimport.RowError, string, error) {
+	if format == "fdc-csv" {
+		records, rowErrors, err := foodimport.ReadFDCCSV(path)
+		return records, rowErrors, foodimport.FDCSource, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer file.Close()
+	switch format {
+	case "fdc-json":
+		records, rowErrors, err := foodimport.ReadFDCJSON(file)
+		return records, rowErrors, foodimport.FDCSource, err
+	case "csv":
+		records, rowErrors, err := foodimport.ReadCSV(file)
+		return records, rowErrors, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), err
+	}
+	return nil, nil, "", fmt.Errorf("unknown format %s, use fdc-json, fdc-csv or csv", format)
+}
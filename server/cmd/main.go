@@ -1,34 +1,59 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"foodgenie/internal/app"
 	"foodgenie/internal/config"
 	"foodgenie/internal/database"
 	"foodgenie/internal/handlers"
-	"foodgenie/seeds"
+	"foodgenie/internal/jobs"
+	"foodgenie/internal/logging"
+	"foodgenie/internal/models"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
 	"reflect"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
 )
 
 func main() {
-	cfg, err := config.LoadConfig()
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.LoadConfig(configFlags)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	// log package writes through the logger as well
+	slog.SetDefault(logger)
+	slog.Info("effective config", "settings", cfg.Redacted())
 	db, err := database.InitDatabase(cfg.DB)
 	if err != nil {
-		panic("Failed to initialize database")
+		slog.Error("failed to initialize database", "error", err)
+		os.Exit(1)
 	}
 
 	application := app.Init(db, &cfg.App)
-	seeds.Seed(application) // Re-enabled with graceful duplicate handling
-	router := gin.Default()
+	// cancelled by SIGINT or SIGTERM, stops background jobs and starts the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var workers jobs.Runner
+	workers.Go(ctx, "account purge", cfg.App.Account.PurgeInterval, application.AccountService.PurgeDeletedAccounts)
+	workers.Go(ctx, "recipe recalculation", cfg.App.Catalog.RecalculationInterval, application.IngredientService.ProcessRecalculations)
+	router := gin.New()
 
 	//chat gpt ----->
 	//TODO: ogarnac o co tu chodzi
@@ -44,42 +69,115 @@ func main() {
 			}
 			return name
 		})
-		log.Println("Validator configured successfully to use 'validate' tag.")
+		slog.Debug("validator configured to use validate tag")
 	}
 	// <----- koniec gpt
-	router.Use(logRequestMiddleware())
+	router.Use(handlers.RequestID(), handlers.LogRequests(), gin.Recovery(), handlers.Localize())
 	userHandler := handlers.NewUserHandler(application)
 	mealHandler := handlers.NewMealHandler(application)
 	ingredientHandler := handlers.NewIngredientHandler(application)
 	recipeHandler := handlers.NewRecipeHandler(application)
+	twoFactorHandler := handlers.NewTwoFactorHandler(application)
+	oidcHandler := handlers.NewOIDCHandler(application)
 	router.POST("/api/auth/register", userHandler.Register)
 	router.POST("/api/auth/login", userHandler.Login)
 	router.POST("/api/auth/refresh", userHandler.RefreshToken)
-	router.POST("/api/ingredient", ingredientHandler.CreateIngredient)
-	router.POST("/api/recipe", recipeHandler.CreateRecipe)
-	router.GET("/api/recipe/:name", recipeHandler.GetRecipeByName)
+	router.POST("/api/auth/login/2fa", twoFactorHandler.CompleteLogin)
+	router.POST("/api/users/email/confirm", userHandler.ConfirmEmailChange)
+	router.GET("/api/auth/oidc/:provider/authorize", oidcHandler.Authorize)
+	router.GET("/api/auth/oidc/:provider/callback", oidcHandler.Callback)
+	router.POST("/api/auth/oidc/:provider/callback", oidcHandler.Callback)
+	// signed in users also see their own private recipes
+	public := router.Group("/api", userHandler.OptionalAuth())
+	public.GET("/recipe/:name", recipeHandler.GetRecipeByName)
+	public.GET("/recipes", recipeHandler.ListRecipes)
+	public.GET("/recipes/:id", recipeHandler.GetRecipeByID)
+	public.GET("/recipes/:id/versions", recipeHandler.GetRecipeVersions)
+	public.POST("/recipes/:id/scale", recipeHandler.ScaleRecipe)
+	public.POST("/recipes/:id/substitute", recipeHandler.SubstituteIngredient)
+	public.GET("/ingredients", ingredientHandler.ListIngredients)
+	public.GET("/ingredients/:id", ingredientHandler.GetIngredientByID)
 	authorized := router.Group("/api", userHandler.AuthCheck())
 	authorized.GET("/users/me", userHandler.GetMe)
+	authorized.PATCH("/users/me", userHandler.UpdateMe)
+	authorized.POST("/users/me/password", userHandler.ChangePassword)
+	authorized.POST("/users/me/email", userHandler.RequestEmailChange)
+	authorized.DELETE("/users/me", userHandler.DeleteMe)
+	authorized.POST("/users/me/deletion/cancel", userHandler.CancelDeletion)
+	authorized.GET("/users/me/export", userHandler.ExportMe)
+	authorized.POST("/auth/2fa/enroll", twoFactorHandler.Enroll)
+	authorized.POST("/auth/2fa/verify", twoFactorHandler.Activate)
+	authorized.POST("/auth/2fa/disable", twoFactorHandler.Disable)
+	authorized.POST("/recipes/import", recipeHandler.ImportRecipe)
+	authorized.POST("/users/me/recipes", recipeHandler.CreateMyRecipe)
+	authorized.PUT("/users/me/recipes/:id", recipeHandler.ReplaceMyRecipe)
+	authorized.PATCH("/users/me/recipes/:id", recipeHandler.PatchMyRecipe)
+	authorized.DELETE("/users/me/recipes/:id", recipeHandler.DeleteMyRecipe)
+	authorized.POST("/meals", mealHandler.CreateMeal)
 	authorized.POST("/meal/image", mealHandler.LogMealFromImage)
 	authorized.GET("/meals", mealHandler.GetMealsForUser)
 	authorized.GET("/meals/:id", mealHandler.GetMealDetails)
 	authorized.DELETE("/meals/:id", mealHandler.DeleteMeal)
+	catalog := authorized.Group("", userHandler.RequirePermission(models.PermissionCatalogWrite))
+	catalog.POST("/ingredient", ingredientHandler.CreateIngredient)
+	catalog.PUT("/ingredients/:id", ingredientHandler.ReplaceIngredient)
+	catalog.PATCH("/ingredients/:id", ingredientHandler.PatchIngredient)
+	catalog.DELETE("/ingredients/:id", ingredientHandler.DeleteIngredient)
+	catalog.POST("/ingredients/:id/aliases", ingredientHandler.AddIngredientAlias)
+	catalog.DELETE("/ingredients/:id/aliases/:aliasId", ingredientHandler.DeleteIngredientAlias)
+	catalog.GET("/ingredients/recalculations/:id", ingredientHandler.GetRecalculation)
+	catalog.POST("/recipe", recipeHandler.CreateRecipe)
+	catalog.PUT("/recipes/:id", recipeHandler.ReplaceRecipe)
+	catalog.PATCH("/recipes/:id", recipeHandler.PatchRecipe)
+	catalog.DELETE("/recipes/:id", recipeHandler.DeleteRecipe)
+	admin := authorized.Group("/admin", userHandler.RequirePermission(models.PermissionUsersManage))
+	admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
 	// router.GET("")
-	address := cfg.Server.Host + ":" + cfg.Server.Port
-	err = router.Run(address)
-	if err != nil {
-		log.Fatal("failed to initialize server")
+	server := &http.Server{
+		Addr:              cfg.Server.Host + ":" + cfg.Server.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "address", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+	failed := false
+	select {
+	case err := <-serverErr:
+		slog.Error("server failed", "error", err)
+		failed = true
+	case <-ctx.Done():
+		slog.Info("shutting down, waiting for in-flight requests and background jobs")
+	}
+	// second signal terminates immediately
+	stop()
+	shutdown(server, &workers, db, cfg.Server.ShutdownTimeout)
+	if failed {
+		os.Exit(1)
 	}
 }
-func logRequestMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		fmt.Println("Method:", c.Request.Method)
-		fmt.Println("URL:", c.Request.URL)
-		fmt.Println("Headers:")
-		for key, values := range c.Request.Header {
-			fmt.Printf("%s: %s\n", key, values)
-		}
 
-		c.Next()
+// stops accepting requests and waits for handlers, e.g. meal image analysis, and jobs until the timeout,
+// connections still open then are closed, the database pool goes last
+func shutdown(server *http.Server, workers *jobs.Runner, db *gorm.DB, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("requests didn't finish in time, closing connections", "timeout", timeout, "error", err)
+		server.Close()
+	}
+	if !workers.Wait(ctx) {
+		slog.Warn("background jobs didn't finish in time", "timeout", timeout)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("failed to close database", "error", err)
+		}
 	}
+	slog.Info("shutdown complete")
 }
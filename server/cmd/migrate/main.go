@@ -0,0 +1,96 @@
+// Applies and rolls back versioned SQL migrations embedded from internal/database/migrations.
+//
+//	go run ./cmd/migrate up          apply all pending migrations
+//	go run ./cmd/migrate down [n]    roll back the newest n migrations, one by default
+//	go run ./cmd/migrate to 3        migrate up or down to version 3, 0 rolls back everything
+//	go run ./cmd/migrate status      list migrations and when they were applied
+//
+// The server refuses to start while any migration is pending.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"foodgenie/internal/config"
+	"foodgenie/internal/database"
+	"log"
+	"os"
+	"strconv"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate up | down [n] | to <version> | status")
+	}
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(configFlags)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := database.Open(cfg.DB)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+
+	var migrations []database.Migration
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		migrations, err = migrator.Up(ctx)
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("Invalid number of migrations %q", args[1])
+			}
+		}
+		migrations, err = migrator.Down(ctx, steps)
+	case args[0] == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseUint(args[1], 10, 32)
+		if parseErr != nil {
+			log.Fatalf("Invalid version %q", args[1])
+		}
+		migrations, err = migrator.To(ctx, uint(version))
+	case args[0] == "status" && len(args) == 1:
+		printStatus(ctx, migrator)
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	for _, migration := range migrations {
+		log.Printf("Ran %d_%s", migration.Version, migration.Name)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if len(migrations) == 0 {
+		log.Println("Nothing to migrate")
+	}
+}
+
+func printStatus(ctx context.Context, migrator *database.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d_%s  %s\n", status.Version, status.Name, applied)
+	}
+}
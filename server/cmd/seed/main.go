@@ -0,0 +1,85 @@
+// Seeds the ingredient catalog and global recipes from the files embedded in the seeds package.
+//
+//	go run ./cmd/seed
+//	go run ./cmd/seed -dry-run
+//	go run ./cmd/seed -only recipes -force
+//
+// Items are matched by name, missing ones are created and changed ones updated. Every applied file is recorded
+// in the seed_versions table and skipped until it changes, -force compares it with the catalog again.
+package main
+
+import (
+	"context"
+	"flag"
+	"foodgenie/internal/app"
+	"foodgenie/internal/config"
+	"foodgenie/internal/database"
+	"foodgenie/seeds"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "print changes without saving them")
+	force := flag.Bool("force", false, "seed datasets already applied in their current version")
+	only := flag.String("only", "", "comma separated datasets to seed, ingredients and recipes by default")
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	options := seeds.Options{DryRun: *dryRun, Force: *force}
+	if *only != "" {
+		for _, dataset := range strings.Split(*only, ",") {
+			options.Only = append(options.Only, strings.TrimSpace(dataset))
+		}
+	}
+
+	cfg, err := config.LoadConfig(configFlags)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := database.InitDatabase(cfg.DB)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	application := app.Init(db, &cfg.App)
+
+	reports, err := seeds.NewSeeder(application, options).Run(context.Background())
+	failed := false
+	for _, report := range reports {
+		printReport(report, *dryRun)
+		failed = failed || report.Count(seeds.ActionFailed) > 0
+	}
+	if err != nil {
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printReport(report seeds.Report, dryRun bool) {
+	if report.UpToDate {
+		log.Printf("%s: up to date, version %d", report.Dataset, report.Version)
+		return
+	}
+	for _, change := range report.Changes {
+		switch change.Action {
+		case seeds.ActionCreate:
+			log.Printf("+ %s", change.Name)
+		case seeds.ActionUpdate:
+			log.Printf("~ %s", change.Name)
+			for _, line := range change.Diff {
+				log.Printf("    %s", line)
+			}
+		case seeds.ActionFailed:
+			log.Printf("! %s: %v", change.Name, change.Err)
+		}
+	}
+	prefix := ""
+	if dryRun {
+		prefix = "Dry run: "
+	}
+	log.Printf("%s%s created %d, updated %d, unchanged %d, failed %d, version %d",
+		prefix, report.Dataset, report.Count(seeds.ActionCreate), report.Count(seeds.ActionUpdate), report.Unchanged,
+		report.Count(seeds.ActionFailed), report.Version)
+}
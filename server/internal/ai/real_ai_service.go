@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/logging"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"time"
@@ -56,13 +58,19 @@ func (s *realAIService) AnalyzeMealImage(ctx context.Context, image io.Reader) (
 	}
 
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	// lets logs of the recognition service be matched with the request
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
 
 	// Call the food recognition service
+	start := time.Now()
 	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call AI service: %w", err)
 	}
 	defer resp.Body.Close()
+	slog.DebugContext(ctx, "food recognition responded", "status", resp.StatusCode, "duration_ms", time.Since(start).Milliseconds())
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
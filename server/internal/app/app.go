@@ -3,6 +3,8 @@ package app
 import (
 	"foodgenie/internal/ai"
 	"foodgenie/internal/config"
+	"foodgenie/internal/mail"
+	"foodgenie/internal/recipeimport"
 	"foodgenie/internal/repositories"
 	"foodgenie/internal/services"
 
@@ -10,29 +12,54 @@ import (
 )
 
 type App struct {
-	UserService       services.UserService
-	SecurityService   services.SecurityService
-	IngredientService services.IngredientService
-	RecipeService     services.RecipeService
-	MealService       services.MealService
+	UserService         services.UserService
+	SecurityService     services.SecurityService
+	TwoFactorService    services.TwoFactorService
+	OIDCService         services.OIDCService
+	AccountService      services.AccountService
+	IngredientService   services.IngredientService
+	RecipeService       services.RecipeService
+	RecipeImportService services.RecipeImportService
+	MealService         services.MealService
+	SeedService         services.SeedService
 }
 
 func Init(db *gorm.DB, cfg *config.AppConfig) *App {
 	userRepository := repositories.NewUserRepository(db)
-	securityService := services.NewSecurityService(cfg.JWT)
-	userService := services.NewUserService(userRepository, securityService)
+	securityService := services.NewSecurityService(cfg.JWT, cfg.TwoFactor)
+	sessionRepository := repositories.NewSessionRepository(db)
+	var mailer mail.Mailer
+	if cfg.Mail.SMTPHost != "" {
+		mailer = mail.NewSMTPMailer(cfg.Mail)
+	} else {
+		mailer = mail.NewLogMailer()
+	}
+	userService := services.NewUserService(userRepository, sessionRepository, securityService, mailer, cfg)
+	twoFactorRepository := repositories.NewTwoFactorRepository(db)
+	twoFactorService := services.NewTwoFactorService(userRepository, twoFactorRepository, sessionRepository, securityService, cfg.TwoFactor, cfg.Account)
+	externalIdentityRepository := repositories.NewExternalIdentityRepository(db)
+	oidcService := services.NewOIDCService(cfg.OIDC, userRepository, externalIdentityRepository, securityService)
 	ingredientRepository := repositories.NewIngredientRepository(db)
-	ingredientService := services.NewIngredientService(ingredientRepository)
 	recipeRepository := repositories.NewRecipeRepository(db)
+	recipeRecalculationRepository := repositories.NewRecipeRecalculationRepository(db)
+	ingredientService := services.NewIngredientService(ingredientRepository, recipeRepository, recipeRecalculationRepository, cfg.Catalog)
 	recipeService := services.NewRecipeService(recipeRepository, ingredientRepository)
+	recipeImportService := services.NewRecipeImportService(ingredientRepository, recipeimport.NewHTTPFetcher(nil))
 	mealRepository := repositories.NewMealRepository(db)
 	aiService := ai.NewRealAIService()
-	mealService := services.NewMealService(mealRepository, recipeRepository, aiService)
+	mealService := services.NewMealService(mealRepository, recipeRepository, userRepository, aiService)
+	seedService := services.NewSeedService(repositories.NewSeedVersionRepository(db))
+	accountService := services.NewAccountService(userRepository, mealRepository, recipeRepository, sessionRepository, externalIdentityRepository, securityService, cfg.Account)
 	return &App{
-		UserService:       userService,
-		SecurityService:   securityService,
-		IngredientService: ingredientService,
-		RecipeService:     recipeService,
-		MealService:       mealService,
+		UserService:         userService,
+		SecurityService:     securityService,
+		TwoFactorService:    twoFactorService,
+		OIDCService:         oidcService,
+		AccountService:      accountService,
+		IngredientService:   ingredientService,
+		RecipeService:       recipeService,
+		RecipeImportService: recipeImportService,
+		MealService:         mealService,
+		SeedService:         seedService,
 	}
 }
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	accessSecret  = "access-secret-with-at-least-32-characters"
+	refreshSecret = "refresh-secret-with-at-least-32-characters"
+)
+
+// empty variables are treated as unset, so the environment of the machine running tests doesn't leak in
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		if key, _, found := strings.Cut(entry, "="); found && key != "" {
+			t.Setenv(key, "")
+		}
+	}
+	t.Setenv("ENV_FILE", filepath.Join(t.TempDir(), "missing.env"))
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLayerPrecedence(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SERVER_PORT", "3")
+	t.Setenv("LOG_LEVEL", "error")
+	flags := &Flags{
+		File:       writeFile(t, "config.yaml", "server:\n  port: 1\nlog:\n  level: debug\nsmtp:\n  host: file.example\nmail:\n  from: file@example.com\n"),
+		DotenvFile: writeFile(t, "app.env", "SERVER_PORT=2\nLOG_LEVEL=warn\nSMTP_HOST=dotenv.example\n"),
+		Overrides:  overrides{"SERVER_PORT": "4"},
+	}
+	s, err := loadSettings(flags)
+	if err != nil {
+		t.Fatalf("loadSettings: %v", err)
+	}
+	tests := []struct {
+		key    string
+		value  string
+		source string
+	}{
+		{"DB_TIMEZONE", "UTC", sourceDefault},
+		{"MAIL_FROM", "file@example.com", sourceFile},
+		{"SMTP_HOST", "dotenv.example", sourceDotenv},
+		{"LOG_LEVEL", "error", sourceEnv},
+		{"SERVER_PORT", "4", sourceFlag},
+	}
+	for _, tt := range tests {
+		if got := s.values[tt.key]; got.value != tt.value || got.source != tt.source {
+			t.Fatalf("%s = %q (%s), want %q (%s)", tt.key, got.value, got.source, tt.value, tt.source)
+		}
+	}
+}
+
+func TestEmptyValueDoesNotOverrideLowerLayer(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SERVER_PORT", "")
+	s, err := loadSettings(&Flags{DotenvFile: writeFile(t, "app.env", "SERVER_PORT=9090\n")})
+	if err != nil {
+		t.Fatalf("loadSettings: %v", err)
+	}
+	if got := s.values["SERVER_PORT"]; got.value != "9090" || got.source != sourceDotenv {
+		t.Fatalf("SERVER_PORT = %q (%s), want 9090 from .env", got.value, got.source)
+	}
+}
+
+func TestMissingDotenvFile(t *testing.T) {
+	clearEnv(t)
+	if _, err := loadSettings(nil); err != nil {
+		t.Fatalf("missing default .env: %v, want it to be optional", err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.env")
+	if _, err := loadSettings(&Flags{DotenvFile: missing}); err == nil {
+		t.Fatal("missing -env-file: want error, the file was named explicitly")
+	}
+}
+
+func TestLoadConfigReportsSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "missing",
+			env:  map[string]string{"DATABASE_URL": "postgres://app@db/foodgenie"},
+			want: []string{"ACCESS_TOKEN_SECRET is required", "REFRESH_TOKEN_SECRET is required"},
+		},
+		{
+			name: "weak",
+			env: map[string]string{
+				"DATABASE_URL":              "postgres://app@db/foodgenie",
+				"ACCESS_TOKEN_SECRET":       "short",
+				"REFRESH_TOKEN_SECRET":      "also-short",
+				"TWO_FACTOR_ENCRYPTION_KEY": "tiny",
+			},
+			want: []string{
+				"ACCESS_TOKEN_SECRET must have at least 32 characters",
+				"REFRESH_TOKEN_SECRET must have at least 32 characters",
+				"TWO_FACTOR_ENCRYPTION_KEY must have at least 32 characters",
+			},
+		},
+		{
+			name: "shared",
+			env:  map[string]string{"DATABASE_URL": "postgres://app@db/foodgenie", "ACCESS_TOKEN_SECRET": accessSecret, "REFRESH_TOKEN_SECRET": accessSecret},
+			want: []string{"ACCESS_TOKEN_SECRET and REFRESH_TOKEN_SECRET must differ"},
+		},
+		{
+			name: "database settings without url",
+			env:  map[string]string{"ACCESS_TOKEN_SECRET": accessSecret, "REFRESH_TOKEN_SECRET": refreshSecret},
+			want: []string{"DB_HOST is required", "DB_USER is required", "DB_NAME is required"},
+		},
+		{
+			name: "key=value connection string",
+			env:  map[string]string{"DATABASE_URL": "host=db user=app password=secret", "ACCESS_TOKEN_SECRET": accessSecret, "REFRESH_TOKEN_SECRET": refreshSecret},
+			want: []string{"DATABASE_URL is invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := LoadConfig(nil)
+			if err == nil {
+				t.Fatal("LoadConfig: want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("error %q doesn't report %q", err, want)
+				}
+			}
+			// values are never part of the error, they may be secrets
+			for key, value := range tt.env {
+				if strings.Contains(err.Error(), value) {
+					t.Fatalf("error %q leaks value of %s", err, key)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", "postgres://app:url-password@db:5432/foodgenie?sslmode=disable&password=param-password")
+	t.Setenv("ACCESS_TOKEN_SECRET", accessSecret)
+	t.Setenv("REFRESH_TOKEN_SECRET", refreshSecret)
+	t.Setenv("SMTP_PASSWORD", "smtp-password")
+	t.Setenv("OIDC_GOOGLE_CLIENT_ID", "client-id")
+	t.Setenv("OIDC_GOOGLE_CLIENT_SECRET", "client-secret")
+	cfg, err := LoadConfig(&Flags{Overrides: overrides{"SERVER_PORT": "9090"}})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	redacted := cfg.Redacted()
+	for _, line := range []string{
+		"ACCESS_TOKEN_SECRET=*** (env)",
+		"REFRESH_TOKEN_SECRET=*** (env)",
+		"SMTP_PASSWORD=*** (env)",
+		"OIDC_GOOGLE_CLIENT_SECRET=*** (env)",
+		"OIDC_GOOGLE_CLIENT_ID=client-id (env)",
+		"DATABASE_URL=postgres://app:xxxxx@db:5432/foodgenie?password=xxxxx&sslmode=disable (env)",
+		"SERVER_PORT=9090 (flag)",
+		"LOG_LEVEL=info (default)",
+	} {
+		if !strings.Contains(redacted, line+"\n") && !strings.HasSuffix(redacted, line) {
+			t.Fatalf("Redacted() is missing %q:\n%s", line, redacted)
+		}
+	}
+	for _, secret := range []string{accessSecret, refreshSecret, "smtp-password", "client-secret", "url-password", "param-password"} {
+		if strings.Contains(redacted, secret) {
+			t.Fatalf("Redacted() leaks %q:\n%s", secret, redacted)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"SERVER_PORT", "8080", "8080"},
+		{"TWO_FACTOR_ENCRYPTION_KEY", "key", "***"},
+		{"DB_PASSWORD", "password", "***"},
+		{"DATABASE_URL", "postgres://app@db/foodgenie", "postgres://app@db/foodgenie"},
+		{"DATABASE_URL", "postgresql://app:pw@db/foodgenie", "postgresql://app:xxxxx@db/foodgenie"},
+		{"DATABASE_URL", "postgres:///foodgenie?host=/var/run/postgresql&password=pw", "postgres:///foodgenie?host=%2Fvar%2Frun%2Fpostgresql&password=xxxxx"},
+		{"DATABASE_URL", "host=db user=app password=pw", "***"},
+		{"DATABASE_URL", "mysql://app:pw@db/foodgenie", "***"},
+	}
+	for _, tt := range tests {
+		if got := redact(tt.key, tt.value); got != tt.want {
+			t.Fatalf("redact(%s, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
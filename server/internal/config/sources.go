@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// names of the layers a setting can come from, later ones win
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceDotenv  = ".env"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+var (
+	ErrInvalidConfigFile = errors.New("config file must be .yaml, .yml or .toml")
+	ErrInvalidOverride   = errors.New("override must be KEY=value")
+)
+
+// values of settings missing in all other layers
+var defaults = map[string]string{
+	"DB_PORT":                         "5432",
+	"DB_TIMEZONE":                     "UTC",
+	"DB_MAX_OPEN_CONNS":               "25",
+	"DB_MAX_IDLE_CONNS":               "10",
+	"DB_CONN_MAX_LIFETIME":            "30m",
+	"DB_CONN_MAX_IDLE_TIME":           "5m",
+	"DB_STATEMENT_TIMEOUT":            "0s",
+	"DB_CONNECT_TIMEOUT":              "30s",
+	"ACCESS_TOKEN_DURATION":           "15m",
+	"REFRESH_TOKEN_DURATION":          "168h",
+	"TWO_FACTOR_ISSUER":               "FoodGenie",
+	"TWO_FACTOR_CHALLENGE_DURATION":   "5m",
+	"TWO_FACTOR_MAX_ATTEMPTS":         "5",
+	"SMTP_PORT":                       "587",
+	"MAIL_FROM":                       "FoodGenie <no-reply@foodgenie.app>",
+	"ACCOUNT_DELETION_GRACE_PERIOD":   "720h",
+	"ACCOUNT_REAUTHENTICATION_WINDOW": "10m",
+	"RECIPE_RECALCULATION_INTERVAL":   "30s",
+	"RECIPE_RECALCULATION_LEASE":      "10m",
+	"SERVER_PORT":                     "8080",
+	"SERVER_READ_HEADER_TIMEOUT":      "10s",
+	"SERVER_READ_TIMEOUT":             "60s",
+	"SERVER_WRITE_TIMEOUT":            "150s",
+	"SERVER_IDLE_TIMEOUT":             "120s",
+	"SERVER_SHUTDOWN_TIMEOUT":         "30s",
+	"LOG_LEVEL":                       "info",
+	"LOG_FORMAT":                      "text",
+}
+
+// command line layer, commands register it before flag.Parse
+type Flags struct {
+	// YAML or TOML file, CONFIG_FILE environment variable when empty
+	File string
+	// ENV_FILE environment variable when empty, .env by default
+	DotenvFile string
+	Overrides  overrides
+}
+
+// repeatable -set KEY=value
+type overrides map[string]string
+
+func (o overrides) String() string {
+	return fmt.Sprint(map[string]string(o))
+}
+func (o overrides) Set(value string) error {
+	key, raw, found := strings.Cut(value, "=")
+	if !found || strings.TrimSpace(key) == "" {
+		return ErrInvalidOverride
+	}
+	o[strings.ToUpper(strings.TrimSpace(key))] = raw
+	return nil
+}
+
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	flags := &Flags{Overrides: overrides{}}
+	fs.StringVar(&flags.File, "config", "", "YAML or TOML config file, CONFIG_FILE by default")
+	fs.StringVar(&flags.DotenvFile, "env-file", "", "dotenv file, ENV_FILE or .env by default, optional")
+	fs.Var(flags.Overrides, "set", "setting as KEY=value, e.g. -set SERVER_PORT=9090, repeatable")
+	return flags
+}
+
+type setting struct {
+	value  string
+	source string
+}
+
+// settings keyed by environment variable names merged from all layers
+type settings struct {
+	values map[string]setting
+	// keys the config was built from, only they are printed
+	read map[string]bool
+	// problems found while reading typed values, reported together by validation
+	errs []error
+}
+
+func loadSettings(flags *Flags) (*settings, error) {
+	if flags == nil {
+		flags = &Flags{}
+	}
+	s := &settings{values: make(map[string]setting), read: make(map[string]bool)}
+	s.merge(defaults, sourceDefault)
+
+	file := flags.File
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file != "" {
+		values, err := readConfigFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", file, err)
+		}
+		s.merge(values, sourceFile)
+	}
+
+	dotenvFile := flags.DotenvFile
+	if dotenvFile == "" {
+		dotenvFile = getEnvDefault("ENV_FILE", ".env")
+	}
+	dotenv, err := godotenv.Read(dotenvFile)
+	// containers get real environment variables instead, only explicitly named files are required
+	if err != nil && (!errors.Is(err, fs.ErrNotExist) || flags.DotenvFile != "") {
+		return nil, fmt.Errorf("failed to read %s: %w", dotenvFile, err)
+	}
+	s.merge(dotenv, sourceDotenv)
+
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if key, value, found := strings.Cut(entry, "="); found {
+			env[key] = value
+		}
+	}
+	s.merge(env, sourceEnv)
+	s.merge(flags.Overrides, sourceFlag)
+	return s, nil
+}
+
+// empty values don't override lower layers, the same as unset variables
+func (s *settings) merge(values map[string]string, source string) {
+	for key, value := range values {
+		if value != "" {
+			s.values[key] = setting{value: value, source: source}
+		}
+	}
+}
+
+// nested keys of the file are joined with underscores, db: {host: x} sets DB_HOST
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, ErrInvalidConfigFile
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	flattenConfig("", tree, values)
+	return values, nil
+}
+func flattenConfig(prefix string, tree map[string]any, values map[string]string) {
+	for key, value := range tree {
+		key = strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			flattenConfig(key, value, values)
+		case nil:
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+func (s *settings) String(key string) string {
+	s.read[key] = true
+	return s.values[key].value
+}
+func (s *settings) Int(key string) int {
+	raw := s.String(key)
+	if raw == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(raw)
+	if err != nil || number < 0 {
+		s.errs = append(s.errs, fmt.Errorf("%s must be a non-negative integer, got %q", key, raw))
+	}
+	return number
+}
+func (s *settings) Duration(key string) time.Duration {
+	raw := s.String(key)
+	if raw == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration < 0 {
+		s.errs = append(s.errs, fmt.Errorf("%s must be a duration like 15m or 24h, got %q", key, raw))
+	}
+	return duration
+}
+
+// read keys in alphabetical order
+func (s *settings) keys() []string {
+	keys := make([]string, 0, len(s.read))
+	for key := range s.read {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func getEnvDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// HMAC keys shorter than the SHA-256 output weaken the token signature
+const minSecretLength = 32
+
+// parts of keys whose values are never printed
+var secretKeyParts = []string{"SECRET", "PASSWORD", "KEY"}
+
+// all problems are reported at once so a deployment can be fixed in one go
+func (c *Config) validate() error {
+	errs := c.settings.errs
+	required := []string{"ACCESS_TOKEN_SECRET", "REFRESH_TOKEN_SECRET"}
+	if c.DB.URL == "" {
+		required = append(required, "DB_HOST", "DB_USER", "DB_NAME")
+	} else if _, err := parseDatabaseURL(c.DB.URL); err != nil {
+		errs = append(errs, fmt.Errorf("DATABASE_URL is invalid: %w", err))
+	}
+	for _, key := range required {
+		if c.settings.String(key) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", key))
+		}
+	}
+	for _, key := range []string{"ACCESS_TOKEN_SECRET", "REFRESH_TOKEN_SECRET", "TWO_FACTOR_ENCRYPTION_KEY"} {
+		if value := c.settings.String(key); value != "" && len(value) < minSecretLength {
+			errs = append(errs, fmt.Errorf("%s must have at least %d characters", key, minSecretLength))
+		}
+	}
+	if c.App.JWT.AccessTokenSecret != "" && c.App.JWT.AccessTokenSecret == c.App.JWT.RefreshTokenSecret {
+		errs = append(errs, errors.New("ACCESS_TOKEN_SECRET and REFRESH_TOKEN_SECRET must differ"))
+	}
+	if c.App.JWT.AccessTokenDuration == 0 || c.App.JWT.RefreshTokenDuration == 0 {
+		errs = append(errs, errors.New("ACCESS_TOKEN_DURATION and REFRESH_TOKEN_DURATION must be positive"))
+	}
+	if c.App.Catalog.RecalculationInterval == 0 || c.App.Catalog.RecalculationLease == 0 {
+		errs = append(errs, errors.New("RECIPE_RECALCULATION_INTERVAL and RECIPE_RECALCULATION_LEASE must be positive"))
+	}
+	// confirmation links in emails must be absolute
+	if c.App.Mail.PublicBaseURL != "" {
+		if base, err := url.Parse(c.App.Mail.PublicBaseURL); err != nil || base.Scheme == "" || base.Host == "" {
+			errs = append(errs, errors.New("PUBLIC_BASE_URL must be an absolute URL like https://foodgenie.app"))
+		}
+	} else if c.App.Mail.SMTPHost != "" {
+		errs = append(errs, errors.New("PUBLIC_BASE_URL is required when SMTP_HOST is set"))
+	}
+	if c.App.Account.ReauthenticationWindow <= 0 {
+		errs = append(errs, errors.New("ACCOUNT_REAUTHENTICATION_WINDOW must be positive"))
+	}
+	if c.App.TwoFactor.MaxAttempts == 0 {
+		errs = append(errs, errors.New("TWO_FACTOR_MAX_ATTEMPTS must be positive"))
+	}
+	if c.DB.MaxIdleConns > c.DB.MaxOpenConns && c.DB.MaxOpenConns > 0 {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS cannot exceed DB_MAX_OPEN_CONNS"))
+	}
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.Log.Level)) {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	if !slices.Contains([]string{"text", "json"}, strings.ToLower(c.Log.Format)) {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be text or json, got %q", c.Log.Format))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+	return nil
+}
+
+// effective settings one per line with the layer they come from, secrets are masked
+func (c *Config) Redacted() string {
+	var lines []string
+	for _, key := range c.settings.keys() {
+		setting, ok := c.settings.values[key]
+		if !ok {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s (%s)", key, redact(key, setting.value), setting.source))
+	}
+	return strings.Join(lines, "\n")
+}
+func redact(key string, value string) string {
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return "***"
+		}
+	}
+	if key == "DATABASE_URL" {
+		dsnURL, err := parseDatabaseURL(value)
+		if err != nil {
+			return "***"
+		}
+		// password may also be passed as a parameter
+		if query := dsnURL.Query(); query.Has("password") {
+			query.Set("password", "xxxxx")
+			dsnURL.RawQuery = query.Encode()
+		}
+		return dsnURL.Redacted()
+	}
+	return value
+}
+
+// key=value connection strings parse as relative URLs, only postgres:// URLs with a host are accepted,
+// the host of a unix socket can be passed as the host parameter
+func parseDatabaseURL(value string) (*url.URL, error) {
+	dsnURL, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if dsnURL.Scheme != "postgres" && dsnURL.Scheme != "postgresql" {
+		return nil, errors.New("scheme must be postgres or postgresql")
+	}
+	if dsnURL.Host == "" && dsnURL.Query().Get("host") == "" {
+		return nil, errors.New("host is required")
+	}
+	return dsnURL, nil
+}
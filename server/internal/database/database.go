@@ -1,45 +1,154 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"foodgenie/internal/config"
-	"foodgenie/internal/models"
-	"log"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+const (
+	initialConnectDelay = 500 * time.Millisecond
+	maxConnectDelay     = 5 * time.Second
+)
+
+// opens the database and refuses schemas with pending migrations
 func InitDatabase(cfg config.DBConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Europe/Warsaw",
-		cfg.Host,
-		cfg.User,
-		cfg.Password,
-		cfg.Name,
-		cfg.Port,
-		cfg.SSLMode,
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := Open(cfg)
 	if err != nil {
-		fmt.Println("Failed to open connection")
 		return nil, err
 	}
-	log.Println("Database connected successfully")
-
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Ingredient{},
-		&models.Recipe{},
-		&models.RecipeIngredientUsage{},
-		&models.Meal{},
-	)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.CheckSchema(context.Background()); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
 
+// opens the database without checking its schema, used by the migrate command,
+// postgres not accepting connections yet is retried with backoff until cfg.ConnectTimeout passes,
+// other errors like a wrong password or unknown database are returned at once
+func Open(cfg config.DBConfig) (*gorm.DB, error) {
+	dsn, err := buildDSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(cfg.ConnectTimeout)
+	delay := initialConnectDelay
+	var db *gorm.DB
+	for attempt := 1; ; attempt++ {
+		// unique violations are reported as gorm.ErrDuplicatedKey
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true, Logger: newGormLogger()})
+		if err == nil {
+			break
+		}
+		closeDatabase(db)
+		if !isTransientConnectError(err) {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("database not reachable, retrying", "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay = min(delay*2, maxConnectDelay)
+	}
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Printf("Failed to automigrate models: %v", err)
 		return nil, err
 	}
-	log.Println("Database migration successful")
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	slog.Info("database connected")
 
 	return db, nil
 }
+
+// time zone and statement timeout are added to DATABASE_URL unless it sets them itself
+func buildDSN(cfg config.DBConfig) (string, error) {
+	params := map[string]string{}
+	if cfg.TimeZone != "" {
+		params["TimeZone"] = cfg.TimeZone
+	}
+	if cfg.StatementTimeout > 0 {
+		// postgres reads the setting in milliseconds
+		params["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.URL != "" {
+		dsnURL, err := url.Parse(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+		query := dsnURL.Query()
+		for key, value := range params {
+			if !query.Has(key) {
+				query.Set(key, value)
+			}
+		}
+		dsnURL.RawQuery = query.Encode()
+		return dsnURL.String(), nil
+	}
+	params["host"] = cfg.Host
+	params["port"] = cfg.Port
+	params["user"] = cfg.User
+	params["password"] = cfg.Password
+	params["dbname"] = cfg.Name
+	params["sslmode"] = cfg.SSLMode
+	var parts []string
+	for key, value := range params {
+		if value != "" {
+			parts = append(parts, key+"="+quoteDSNValue(value))
+		}
+	}
+	slices.Sort(parts)
+	return strings.Join(parts, " "), nil
+}
+
+// values with spaces or quotes, e.g. passwords, have to be quoted in key=value connection strings
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// refused connections and timeouts while postgres is starting are worth retrying
+func isTransientConnectError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// the database system is starting up
+		return pgErr.Code == "57P03"
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// gorm returns the connection pool even when the first ping fails
+func closeDatabase(db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
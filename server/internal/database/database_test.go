@@ -0,0 +1,31 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTransientConnectError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{&net.OpError{Op: "dial", Err: &net.DNSError{IsTimeout: true}}, true},
+		{fmt.Errorf("ping: %w", &pgconn.PgError{Code: "57P03"}), true},
+		// wrong password and unknown database
+		{&pgconn.PgError{Code: "28P01"}, false},
+		{&pgconn.PgError{Code: "3D000"}, false},
+		{errors.New("cannot parse `postgres://`: failed to parse as URL"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientConnectError(tt.err); got != tt.want {
+			t.Errorf("isTransientConnectError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"foodgenie/internal/logging"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGormLoggerKeepsRequestID(t *testing.T) {
+	var out bytes.Buffer
+	log, err := logging.New(&out, "info", logging.FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(log)
+	defer slog.SetDefault(previous)
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	query := func() (string, int64) { return "SELECT * FROM users WHERE email = $1", 0 }
+	newGormLogger().Trace(ctx, time.Now(), query, errors.New("connection reset"))
+	newGormLogger().Trace(ctx, time.Now().Add(-time.Second), query, nil)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d records, want failed and slow query: %s", len(lines), out.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "request_id=req-1") || !strings.Contains(line, "$1") {
+			t.Errorf("record %q should carry the request ID and the query with placeholders", line)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// key of the postgres advisory lock held while a migration runs, so instances started together don't race
+const migrationLockID = 7246193
+
+var (
+	ErrSchemaOutdated   = errors.New("database schema is not up to date, run go run ./cmd/migrate up")
+	ErrUnknownMigration = errors.New("unknown migration version")
+)
+
+// files are named 0002_add_meal_notes.up.sql and 0002_add_meal_notes.down.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// versioned schema change, every migration runs in its own transaction
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migration together with the time it was applied, nil when pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// every version needs both up and down file, migrations are ordered by version
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d needs up and down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return int(a.Version) - int(b.Version)
+	})
+	return migrations, nil
+}
+
+// version of the newest migration known to the binary
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// all known migrations, applied ones have AppliedAt set
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// fails with ErrSchemaOutdated while any migration of the binary is pending
+func (m *Migrator) CheckSchema(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []uint
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w, pending migrations %v", ErrSchemaOutdated, pending)
+	}
+	return nil
+}
+
+// applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// rolls back given number of the newest applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var versions []uint
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			versions = append(versions, migration.Version)
+		}
+	}
+	if steps > len(versions) {
+		steps = len(versions)
+	}
+	var target uint
+	if steps < len(versions) {
+		target = versions[len(versions)-steps-1]
+	}
+	return m.To(ctx, target)
+}
+
+// applies pending migrations up to the version and rolls back applied ones above it, 0 rolls back all,
+// returns migrations that were run in order
+func (m *Migrator) To(ctx context.Context, version uint) ([]Migration, error) {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+	}
+	if err := m.db.WithContext(ctx).Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version bigint PRIMARY KEY,
+    name text NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT now()
+)`).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	var run []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		ran, err := m.run(ctx, migration, true)
+		if err != nil {
+			return run, err
+		}
+		if ran {
+			run = append(run, migration)
+		}
+	}
+	for i := len(m.migrations) - 1; i >= 0 && m.migrations[i].Version > version; i-- {
+		ran, err := m.run(ctx, m.migrations[i], false)
+		if err != nil {
+			return run, err
+		}
+		if ran {
+			run = append(run, m.migrations[i])
+		}
+	}
+	return run, nil
+}
+
+// runs migration in given direction unless it is already there, the state is checked again under the lock
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) (bool, error) {
+	ran := false
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&schemaMigration{}).Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+			return err
+		}
+		if (count > 0) == up {
+			return nil
+		}
+		if up {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		}
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+		ran = true
+		return tx.Where("version = ?", migration.Version).Delete(&schemaMigration{}).Error
+	})
+	if err != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		return false, fmt.Errorf("migration %d_%s %s failed: %w", migration.Version, migration.Name, direction, err)
+	}
+	return ran, nil
+}
+
+// applied versions, empty before the first migration
+func (m *Migrator) applied(ctx context.Context) (map[uint]time.Time, error) {
+	applied := make(map[uint]time.Time)
+	if !m.db.WithContext(ctx).Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+	var rows []schemaMigration
+	if err := m.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "baseline" {
+		t.Fatalf("first migration should be the baseline, got %+v", migrations)
+	}
+	for i, migration := range migrations {
+		if migration.Version != uint(i+1) {
+			t.Errorf("migration %s has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		// existing databases run the baseline and the migrations after it against tables that may already be there
+		if strings.Contains(migration.Up, "CREATE TABLE ") && !strings.Contains(migration.Up, "CREATE TABLE IF NOT EXISTS") {
+			t.Errorf("migration %d_%s creates a table without IF NOT EXISTS", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestLoadMigrationsInvalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/0001_baseline.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"invalid name": {
+			"migrations/baseline.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"version 0": {
+			"migrations/0000_baseline.up.sql":   {Data: []byte("SELECT 1;")},
+			"migrations/0000_baseline.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"different names": {
+			"migrations/0001_baseline.up.sql":  {Data: []byte("SELECT 1;")},
+			"migrations/0001_initial.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadMigrations(fsys); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+// models as they were before migrations were introduced, the schema was created by AutoMigrate from them
+type baselineModel struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+type baselineUser struct {
+	baselineModel
+	Username    string    `gorm:"size:20;not null;uniqueIndex"`
+	Email       string    `gorm:"uniqueIndex;not null"`
+	Password    string    `gorm:"not null"`
+	FirstName   string    `gorm:"not null"`
+	LastName    string    `gorm:"not null"`
+	DateOfBirth time.Time `gorm:"not null"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineIngredient struct {
+	baselineModel
+	Name            string  `gorm:"not null;uniqueIndex"`
+	CaloriesPerGram float64 `gorm:"not null;default:0"`
+}
+
+func (baselineIngredient) TableName() string { return "ingredients" }
+
+type baselineRecipe struct {
+	baselineModel
+	Name             string                          `gorm:"not null;uniqueIndex"`
+	IngredientUsages []baselineRecipeIngredientUsage `gorm:"foreignKey:RecipeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Weight           uint                            `gorm:"not null;default:0"`
+	Calories         uint                            `gorm:"not null;default:0"`
+	Volume           float64                         `gorm:"not null;default:0"`
+}
+
+func (baselineRecipe) TableName() string { return "recipes" }
+
+type baselineRecipeIngredientUsage struct {
+	baselineModel
+	RecipeID     uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_ingredient_usage"`
+	IngredientID uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_ingredient_usage"`
+	Weight       uint               `gorm:"not null"`
+	Ingredient   baselineIngredient `gorm:"foreignKey:IngredientID"`
+}
+
+func (baselineRecipeIngredientUsage) TableName() string { return "recipe_ingredient_usages" }
+
+type baselineMeal struct {
+	baselineModel
+	UserID   uuid.UUID      `gorm:"type:uuid;not null;index"`
+	RecipeID uuid.UUID      `gorm:"type:uuid;not null;index"`
+	Recipe   baselineRecipe `gorm:"foreignKey:RecipeID"`
+	Weight   uint           `gorm:"not null"`
+}
+
+func (baselineMeal) TableName() string { return "meals" }
+
+// opens TEST_DATABASE_URL with a fresh schema first in search_path, dropped when the test ends
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	schema := "migrate_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := admin.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		t.Fatalf("failed to create extension: %v", err)
+	}
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	dsnURL, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("TEST_DATABASE_URL should be an URL: %v", err)
+	}
+	query := dsnURL.Query()
+	query.Set("search_path", schema+",public")
+	dsnURL.RawQuery = query.Encode()
+	db, err := gorm.Open(postgres.Open(dsnURL.String()), config)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestMigrateUpgradesBaselineSchema(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+
+	if err := db.AutoMigrate(&baselineUser{}, &baselineIngredient{}, &baselineRecipe{}, &baselineRecipeIngredientUsage{}, &baselineMeal{}); err != nil {
+		t.Fatalf("baseline AutoMigrate: %v", err)
+	}
+	user := baselineUser{Username: "anna", Email: "anna@example.com", Password: "hash", FirstName: "Anna", LastName: "Nowak", DateOfBirth: time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ingredient := baselineIngredient{Name: "flour", CaloriesPerGram: 3.64}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&ingredient).Error; err != nil {
+		t.Fatal(err)
+	}
+	recipe := baselineRecipe{Name: "bread", Weight: 500, Calories: 1820, IngredientUsages: []baselineRecipeIngredientUsage{{IngredientID: ingredient.ID, Weight: 500}}}
+	if err := db.Create(&recipe).Error; err != nil {
+		t.Fatal(err)
+	}
+	meal := baselineMeal{UserID: user.ID, RecipeID: recipe.ID, Weight: 120}
+	if err := db.Create(&meal).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.CheckSchema(ctx); err == nil {
+		t.Fatal("baseline schema should be reported as outdated")
+	}
+	run, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(run) != int(migrator.Latest()) {
+		t.Fatalf("ran %d migrations, want %d", len(run), migrator.Latest())
+	}
+	if err := migrator.CheckSchema(ctx); err != nil {
+		t.Fatalf("CheckSchema after Up: %v", err)
+	}
+
+	for _, column := range []struct{ table, name string }{
+		{"users", "role"},
+		{"users", "two_factor_enabled"},
+		{"users", "two_factor_last_step"},
+		{"users", "has_password"},
+		{"recipes", "owner_id"},
+		{"recipes", "raw_weight"},
+		{"recipe_ingredient_usages", "quantity"},
+		{"meals", "recipe_version_id"},
+		{"ingredients", "allergens"},
+		{"ingredients", "source_id"},
+		{"recipe_recalculations", "started_at"},
+	} {
+		if !db.Migrator().HasColumn(column.table, column.name) {
+			t.Errorf("column %s.%s is missing", column.table, column.name)
+		}
+	}
+	for _, index := range []struct{ table, name string }{
+		{"recipes", "idx_recipes_name_global"},
+		{"ingredients", "idx_ingredients_name_trgm"},
+		{"ingredient_aliases", "idx_ingredient_aliases_locale_lower_name"},
+		{"ingredients", "idx_ingredients_name_active"},
+	} {
+		if !db.Migrator().HasIndex(index.table, index.name) {
+			t.Errorf("index %s is missing", index.name)
+		}
+	}
+	if db.Migrator().HasIndex("recipes", "idx_recipes_name") {
+		t.Error("legacy index idx_recipes_name should be dropped")
+	}
+
+	var role string
+	if err := db.Raw("SELECT role FROM users WHERE id = ?", user.ID).Scan(&role).Error; err != nil || role != "user" {
+		t.Errorf("existing user role = %q, %v, want user", role, err)
+	}
+	var rawWeight uint
+	if err := db.Raw("SELECT raw_weight FROM recipes WHERE id = ?", recipe.ID).Scan(&rawWeight).Error; err != nil || rawWeight != 500 {
+		t.Errorf("raw_weight = %d, %v, want backfilled 500", rawWeight, err)
+	}
+	var usage struct {
+		Quantity float64
+		Unit     string
+	}
+	if err := db.Raw("SELECT quantity, unit FROM recipe_ingredient_usages WHERE recipe_id = ?", recipe.ID).Scan(&usage).Error; err != nil || usage.Quantity != 500 || usage.Unit != "g" {
+		t.Errorf("usage = %+v, %v, want 500 g", usage, err)
+	}
+	var pinned struct {
+		Number      uint
+		Ingredients int64
+	}
+	err = db.Raw(`SELECT v.number, (SELECT COUNT(*) FROM recipe_version_ingredients i WHERE i.recipe_version_id = v.id) AS ingredients
+FROM meals m JOIN recipe_versions v ON v.id = m.recipe_version_id JOIN recipes r ON r.current_version_id = v.id
+WHERE m.id = ?`, meal.ID).Scan(&pinned).Error
+	if err != nil || pinned.Number != 1 || pinned.Ingredients != 1 {
+		t.Errorf("meal version = %+v, %v, want backfilled version 1 with 1 ingredient", pinned, err)
+	}
+	var mealQuantity float64
+	if err := db.Raw("SELECT quantity FROM meals WHERE id = ?", meal.ID).Scan(&mealQuantity).Error; err != nil || mealQuantity != 120 {
+		t.Errorf("meal quantity = %v, %v, want backfilled 120", mealQuantity, err)
+	}
+
+	// rolling back to the baseline has to leave the data usable by the old binary
+	if _, err := migrator.To(ctx, 1); err != nil {
+		t.Fatalf("To(1): %v", err)
+	}
+	if db.Migrator().HasColumn("users", "role") {
+		t.Error("column users.role should be dropped by the down migrations")
+	}
+	if !db.Migrator().HasIndex("recipes", "idx_recipes_name") {
+		t.Error("index idx_recipes_name should be restored")
+	}
+	var meals int64
+	if err := db.Table("meals").Count(&meals).Error; err != nil || meals != 1 {
+		t.Errorf("meals = %d, %v, want 1", meals, err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up after rollback: %v", err)
+	}
+}
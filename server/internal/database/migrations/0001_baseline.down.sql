@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS meals;
+DROP TABLE IF EXISTS recipe_ingredient_usages;
+DROP TABLE IF EXISTS recipes;
+DROP TABLE IF EXISTS ingredients;
+DROP TABLE IF EXISTS users;
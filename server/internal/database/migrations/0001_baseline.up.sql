@@ -0,0 +1,77 @@
+-- schema created by gorm AutoMigrate before migrations were introduced, existing databases already have it
+-- and later migrations bring them to the current schema
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username varchar(20) NOT NULL,
+    email text NOT NULL,
+    password text NOT NULL,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    date_of_birth timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS ingredients (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    calories_per_gram decimal NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredients_name ON ingredients (name);
+CREATE INDEX IF NOT EXISTS idx_ingredients_deleted_at ON ingredients (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recipes (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    weight bigint NOT NULL DEFAULT 0,
+    calories bigint NOT NULL DEFAULT 0,
+    volume decimal NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipes_name ON recipes (name);
+CREATE INDEX IF NOT EXISTS idx_recipes_deleted_at ON recipes (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recipe_ingredient_usages (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    recipe_id uuid NOT NULL,
+    ingredient_id uuid NOT NULL,
+    weight bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_recipe_ingredient_usages_ingredient FOREIGN KEY (ingredient_id) REFERENCES ingredients(id),
+    CONSTRAINT fk_recipes_ingredient_usages FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipe_ingredient_usage ON recipe_ingredient_usages (recipe_id,ingredient_id);
+CREATE INDEX IF NOT EXISTS idx_recipe_ingredient_usages_deleted_at ON recipe_ingredient_usages (deleted_at);
+
+CREATE TABLE IF NOT EXISTS meals (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    recipe_id uuid NOT NULL,
+    weight bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_meals_recipe FOREIGN KEY (recipe_id) REFERENCES recipes(id)
+);
+CREATE INDEX IF NOT EXISTS idx_meals_user_id ON meals (user_id);
+CREATE INDEX IF NOT EXISTS idx_meals_deleted_at ON meals (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_meals_recipe_id ON meals (recipe_id);
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS email_change_requests;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS o_id_c_login_states;
+DROP TABLE IF EXISTS external_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS role,
+    DROP COLUMN IF EXISTS pref_language,
+    DROP COLUMN IF EXISTS pref_unit_system,
+    DROP COLUMN IF EXISTS pref_daily_calorie_goal,
+    DROP COLUMN IF EXISTS pref_allergies,
+    DROP COLUMN IF EXISTS deletion_scheduled_at,
+    DROP COLUMN IF EXISTS two_factor_secret,
+    DROP COLUMN IF EXISTS two_factor_enabled;
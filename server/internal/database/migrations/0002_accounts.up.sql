@@ -0,0 +1,87 @@
+-- roles, two-factor authentication, OpenID Connect identities, sessions, email change and account deletion
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role varchar(20) NOT NULL DEFAULT 'user',
+    ADD COLUMN IF NOT EXISTS pref_language varchar(10) NOT NULL DEFAULT 'en',
+    ADD COLUMN IF NOT EXISTS pref_unit_system varchar(10) NOT NULL DEFAULT 'metric',
+    ADD COLUMN IF NOT EXISTS pref_daily_calorie_goal bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS pref_allergies jsonb NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS deletion_scheduled_at timestamptz,
+    ADD COLUMN IF NOT EXISTS two_factor_secret text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS two_factor_enabled boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users (deletion_scheduled_at);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_deleted_at ON recovery_codes (deleted_at);
+
+CREATE TABLE IF NOT EXISTS external_identities (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_external_identities_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_external_identity_subject ON external_identities (provider,subject);
+CREATE INDEX IF NOT EXISTS idx_external_identities_user_id ON external_identities (user_id);
+CREATE INDEX IF NOT EXISTS idx_external_identities_deleted_at ON external_identities (deleted_at);
+
+CREATE TABLE IF NOT EXISTS o_id_c_login_states (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    state text NOT NULL,
+    provider text NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_o_id_c_login_states_deleted_at ON o_id_c_login_states (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_o_id_c_login_states_expires_at ON o_id_c_login_states (expires_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_o_id_c_login_states_state ON o_id_c_login_states (state);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_revoked_at ON sessions (revoked_at);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_deleted_at ON sessions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS email_change_requests (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    new_email text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_change_requests_token_hash ON email_change_requests (token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_change_requests_user_id ON email_change_requests (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_change_requests_deleted_at ON email_change_requests (deleted_at);
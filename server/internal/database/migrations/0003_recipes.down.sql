@@ -0,0 +1,25 @@
+DROP INDEX IF EXISTS idx_meals_recipe_version_id;
+ALTER TABLE meals
+    DROP COLUMN IF EXISTS recipe_version_id,
+    DROP COLUMN IF EXISTS unit,
+    DROP COLUMN IF EXISTS quantity;
+DROP TABLE IF EXISTS recipe_version_ingredients;
+DROP TABLE IF EXISTS recipe_versions;
+DROP TABLE IF EXISTS recipe_portions;
+ALTER TABLE recipe_ingredient_usages
+    DROP COLUMN IF EXISTS yield_factor,
+    DROP COLUMN IF EXISTS unit,
+    DROP COLUMN IF EXISTS quantity;
+DROP INDEX IF EXISTS idx_recipes_owner_id;
+DROP INDEX IF EXISTS idx_recipes_owner_name;
+DROP INDEX IF EXISTS idx_recipes_name_global;
+-- fails when recipes of different owners share a name, they have to be renamed first
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipes_name ON recipes (name);
+ALTER TABLE recipes
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS current_version_id,
+    DROP COLUMN IF EXISTS servings,
+    DROP COLUMN IF EXISTS cooked_weight,
+    DROP COLUMN IF EXISTS raw_weight,
+    DROP COLUMN IF EXISTS visibility,
+    DROP COLUMN IF EXISTS owner_id;
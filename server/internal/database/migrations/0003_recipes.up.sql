@@ -0,0 +1,88 @@
+-- owned recipes, versions pinned by meals, units, servings, portions and cooked weight
+
+ALTER TABLE recipes
+    ADD COLUMN IF NOT EXISTS owner_id uuid,
+    ADD COLUMN IF NOT EXISTS visibility varchar(20) NOT NULL DEFAULT 'public',
+    ADD COLUMN IF NOT EXISTS raw_weight bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cooked_weight bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS servings bigint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS current_version_id uuid,
+    ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 0;
+-- recipe names used to be unique including deleted and private recipes
+DROP INDEX IF EXISTS idx_recipes_name;
+DROP INDEX IF EXISTS idx_recipes_name_active;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipes_name_global ON recipes (name) WHERE deleted_at IS NULL AND owner_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipes_owner_name ON recipes (owner_id,name) WHERE deleted_at IS NULL AND owner_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_recipes_owner_id ON recipes (owner_id);
+-- recipes saved before cooking yield weigh the same raw and finished
+UPDATE recipes SET raw_weight = weight WHERE raw_weight = 0;
+
+ALTER TABLE recipe_ingredient_usages
+    ADD COLUMN IF NOT EXISTS quantity decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS unit varchar(10) NOT NULL DEFAULT 'g',
+    ADD COLUMN IF NOT EXISTS yield_factor decimal NOT NULL DEFAULT 1;
+-- usages saved before units were entered in grams
+UPDATE recipe_ingredient_usages SET quantity = weight WHERE quantity = 0;
+
+CREATE TABLE IF NOT EXISTS recipe_portions (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    recipe_id uuid NOT NULL,
+    name varchar(50) NOT NULL,
+    weight bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_recipes_portions FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipe_portion_name ON recipe_portions (recipe_id,name);
+CREATE INDEX IF NOT EXISTS idx_recipe_portions_deleted_at ON recipe_portions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recipe_versions (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    recipe_id uuid NOT NULL,
+    number bigint NOT NULL,
+    name text NOT NULL,
+    weight bigint NOT NULL,
+    raw_weight bigint NOT NULL DEFAULT 0,
+    calories bigint NOT NULL,
+    volume decimal NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recipe_version_number ON recipe_versions (recipe_id,number);
+CREATE INDEX IF NOT EXISTS idx_recipe_versions_deleted_at ON recipe_versions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recipe_version_ingredients (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    recipe_version_id uuid NOT NULL,
+    ingredient_id uuid NOT NULL,
+    name text NOT NULL,
+    weight bigint NOT NULL,
+    quantity decimal NOT NULL DEFAULT 0,
+    unit varchar(10) NOT NULL DEFAULT 'g',
+    calories_per_gram decimal NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_recipe_versions_ingredients FOREIGN KEY (recipe_version_id) REFERENCES recipe_versions(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_recipe_version_ingredients_recipe_version_id ON recipe_version_ingredients (recipe_version_id);
+CREATE INDEX IF NOT EXISTS idx_recipe_version_ingredients_deleted_at ON recipe_version_ingredients (deleted_at);
+
+ALTER TABLE meals
+    ADD COLUMN IF NOT EXISTS quantity decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS unit varchar(50) NOT NULL DEFAULT 'g',
+    ADD COLUMN IF NOT EXISTS recipe_version_id uuid;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_meals_recipe_version') THEN
+        ALTER TABLE meals ADD CONSTRAINT fk_meals_recipe_version FOREIGN KEY (recipe_version_id) REFERENCES recipe_versions(id);
+    END IF;
+END $$;
+CREATE INDEX IF NOT EXISTS idx_meals_recipe_version_id ON meals (recipe_version_id);
+-- meals logged before units were entered in grams
+UPDATE meals SET quantity = weight WHERE quantity = 0;
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS recipe_recalculation_changes;
+DROP TABLE IF EXISTS recipe_recalculations;
+DROP TABLE IF EXISTS ingredient_aliases;
+DROP INDEX IF EXISTS idx_ingredients_source;
+DROP INDEX IF EXISTS idx_ingredients_name_active;
+-- fails when a deleted ingredient shares its name with an active one
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredients_name ON ingredients (name);
+ALTER TABLE ingredients
+    DROP COLUMN IF EXISTS source_id,
+    DROP COLUMN IF EXISTS source,
+    DROP COLUMN IF EXISTS diet_tags,
+    DROP COLUMN IF EXISTS allergens,
+    DROP COLUMN IF EXISTS yield_factor,
+    DROP COLUMN IF EXISTS piece_weight,
+    DROP COLUMN IF EXISTS density,
+    DROP COLUMN IF EXISTS sodium_per_gram,
+    DROP COLUMN IF EXISTS sugar_per_gram,
+    DROP COLUMN IF EXISTS fiber_per_gram,
+    DROP COLUMN IF EXISTS carbohydrates_per_gram,
+    DROP COLUMN IF EXISTS fat_per_gram,
+    DROP COLUMN IF EXISTS protein_per_gram;
@@ -0,0 +1,72 @@
+-- measures, nutrients, allergens, imported sources, aliases and recipe recalculations
+
+ALTER TABLE ingredients
+    ADD COLUMN IF NOT EXISTS protein_per_gram decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS fat_per_gram decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS carbohydrates_per_gram decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS fiber_per_gram decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS sugar_per_gram decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS sodium_per_gram decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS density decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS piece_weight decimal NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS yield_factor decimal NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS allergens jsonb NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS diet_tags jsonb NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS source varchar(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source_id varchar(100) NOT NULL DEFAULT '';
+-- ingredient name used to be unique including deleted rows
+DROP INDEX IF EXISTS idx_ingredients_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredients_name_active ON ingredients (name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredients_source ON ingredients (source,source_id) WHERE source_id <> '' AND deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS ingredient_aliases (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    ingredient_id uuid NOT NULL,
+    name varchar(100) NOT NULL,
+    locale varchar(35) NOT NULL,
+    preferred boolean NOT NULL DEFAULT false,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_ingredients_aliases FOREIGN KEY (ingredient_id) REFERENCES ingredients(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_aliases_locale_name ON ingredient_aliases (name,locale) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_ingredient_aliases_ingredient_id ON ingredient_aliases (ingredient_id);
+CREATE INDEX IF NOT EXISTS idx_ingredient_aliases_deleted_at ON ingredient_aliases (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recipe_recalculations (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    ingredient_id uuid NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    previous_yield_factor decimal NOT NULL DEFAULT 1,
+    error text,
+    finished_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_recipe_recalculations_status ON recipe_recalculations (status);
+CREATE INDEX IF NOT EXISTS idx_recipe_recalculations_ingredient_id ON recipe_recalculations (ingredient_id);
+CREATE INDEX IF NOT EXISTS idx_recipe_recalculations_deleted_at ON recipe_recalculations (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recipe_recalculation_changes (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    recalculation_id uuid NOT NULL,
+    recipe_id uuid NOT NULL,
+    recipe_name text NOT NULL,
+    previous_calories bigint NOT NULL,
+    calories bigint NOT NULL,
+    previous_weight bigint NOT NULL,
+    weight bigint NOT NULL,
+    version bigint NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_recipe_recalculations_changes FOREIGN KEY (recalculation_id) REFERENCES recipe_recalculations(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_recipe_recalculation_changes_recipe_id ON recipe_recalculation_changes (recipe_id);
+CREATE INDEX IF NOT EXISTS idx_recipe_recalculation_changes_recalculation_id ON recipe_recalculation_changes (recalculation_id);
+CREATE INDEX IF NOT EXISTS idx_recipe_recalculation_changes_deleted_at ON recipe_recalculation_changes (deleted_at);
@@ -0,0 +1,17 @@
+-- ledger of applied seed files
+
+CREATE TABLE IF NOT EXISTS seed_versions (
+    id uuid DEFAULT uuid_generate_v4(),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    dataset varchar(50) NOT NULL,
+    version bigint NOT NULL,
+    checksum varchar(64) NOT NULL,
+    created bigint NOT NULL,
+    updated bigint NOT NULL,
+    unchanged bigint NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_seed_versions_dataset_version ON seed_versions (dataset,version);
+CREATE INDEX IF NOT EXISTS idx_seed_versions_deleted_at ON seed_versions (deleted_at);
@@ -0,0 +1 @@
+ALTER TABLE ingredients DROP COLUMN IF EXISTS allergens_tagged;
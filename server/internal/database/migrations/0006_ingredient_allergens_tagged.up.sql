@@ -0,0 +1,3 @@
+-- empty allergens used to mean both none and not reviewed, only ingredients listing some were reviewed for sure
+ALTER TABLE ingredients ADD COLUMN IF NOT EXISTS allergens_tagged boolean NOT NULL DEFAULT false;
+UPDATE ingredients SET allergens_tagged = true WHERE allergens <> '[]'::jsonb;
@@ -0,0 +1 @@
+-- backfilled versions are valid snapshots, rolling back keeps them and the meals pinned to them
@@ -0,0 +1,25 @@
+-- recipes saved before versioning get their current state as the first version, versions are created only
+-- when recipes are written from now on
+WITH created AS (
+    INSERT INTO recipe_versions (id, created_at, updated_at, recipe_id, number, name, weight, raw_weight, calories, volume)
+    SELECT uuid_generate_v4(), now(), now(), r.id,
+        COALESCE((SELECT MAX(v.number) FROM recipe_versions v WHERE v.recipe_id = r.id), 0) + 1,
+        r.name, r.weight, r.raw_weight, r.calories, r.volume
+    FROM recipes r
+    WHERE r.current_version_id IS NULL
+    RETURNING id, recipe_id, number
+), snapshot AS (
+    INSERT INTO recipe_version_ingredients (id, created_at, updated_at, recipe_version_id, ingredient_id, name, weight, quantity, unit, calories_per_gram)
+    SELECT uuid_generate_v4(), now(), now(), c.id, u.ingredient_id, i.name, u.weight, u.quantity, u.unit, i.calories_per_gram
+    FROM created c
+    JOIN recipe_ingredient_usages u ON u.recipe_id = c.recipe_id AND u.deleted_at IS NULL
+    JOIN ingredients i ON i.id = u.ingredient_id
+)
+UPDATE recipes r SET current_version_id = c.id, version = c.number
+FROM created c
+WHERE r.id = c.recipe_id;
+
+-- meals logged before versioning showed the current recipe, they keep showing the state it had at the upgrade
+UPDATE meals m SET recipe_version_id = r.current_version_id
+FROM recipes r
+WHERE m.recipe_id = r.id AND m.recipe_version_id IS NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE recipe_recalculation_changes DROP COLUMN IF EXISTS error;
+ALTER TABLE recipe_recalculations DROP COLUMN IF EXISTS started_at;
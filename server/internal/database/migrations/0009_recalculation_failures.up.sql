@@ -0,0 +1,2 @@
+ALTER TABLE recipe_recalculations ADD COLUMN IF NOT EXISTS started_at timestamptz;
+ALTER TABLE recipe_recalculation_changes ADD COLUMN IF NOT EXISTS error text;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ingredient_aliases_name_trgm;
+DROP INDEX IF EXISTS idx_ingredients_name_trgm;
@@ -0,0 +1,4 @@
+-- ingredient search matches substrings and similar words, trigram indexes keep it fast for imported catalogs
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_ingredients_name_trgm ON ingredients USING gin (LOWER(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_ingredient_aliases_name_trgm ON ingredient_aliases USING gin (LOWER(name) gin_trgm_ops);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ingredient_aliases_locale_lower_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_aliases_locale_name ON ingredient_aliases (name,locale) WHERE deleted_at IS NULL;
@@ -0,0 +1,9 @@
+-- aliases are unique per locale ignoring case, of duplicates differing only in case the oldest is kept
+UPDATE ingredient_aliases AS duplicate SET deleted_at = now()
+WHERE duplicate.deleted_at IS NULL AND EXISTS (
+    SELECT 1 FROM ingredient_aliases AS kept
+    WHERE kept.deleted_at IS NULL AND kept.locale = duplicate.locale AND LOWER(kept.name) = LOWER(duplicate.name)
+      AND (kept.created_at, kept.id) < (duplicate.created_at, duplicate.id)
+);
+DROP INDEX IF EXISTS idx_ingredient_aliases_locale_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_aliases_locale_lower_name ON ingredient_aliases (LOWER(name),locale) WHERE deleted_at IS NULL;
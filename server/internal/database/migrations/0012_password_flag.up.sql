@@ -0,0 +1,9 @@
+-- accounts created by signing in with a provider got a random password nobody knows,
+-- they are recognized by the identity linked right after the user was created
+ALTER TABLE users ADD COLUMN IF NOT EXISTS has_password boolean NOT NULL DEFAULT true;
+UPDATE users SET has_password = false
+WHERE EXISTS (
+    SELECT 1 FROM external_identities
+    WHERE external_identities.user_id = users.id
+      AND external_identities.created_at BETWEEN users.created_at AND users.created_at + interval '1 minute'
+);
@@ -0,0 +1,40 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// password is not needed by accounts without one which signed in recently
+type DeleteAccountRequestDTO struct {
+	Password string `json:"password"`
+}
+type AccountDeletionResponseDTO struct {
+	DeletionScheduledAt time.Time `json:"deletionScheduledAt"`
+}
+
+// --- Data export DTOs ---
+type ExportProfileDTO struct {
+	UserResponseDTO
+	TwoFactorEnabled bool      `json:"twoFactorEnabled"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	ExportedAt       time.Time `json:"exportedAt"`
+}
+type ExportSessionDTO struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+type ExportLinkedAccountDTO struct {
+	Provider string    `json:"provider"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linkedAt"`
+}
+
+// recipe owned by the user with its whole history
+type ExportRecipeDTO struct {
+	RecipeDetailResponseDTO
+	Versions []RecipeVersionDTO
+}
@@ -0,0 +1,126 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// name search is used for autocomplete, best matches come first
+type IngredientListQueryDTO struct {
+	Query    string `form:"q"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"pageSize"`
+}
+type PaginatedIngredientsResponseDTO struct {
+	Ingredients []IngredientDTO `json:"ingredients"`
+	TotalCount  int64           `json:"totalCount"`
+	Page        int             `json:"page"`
+	PageSize    int             `json:"pageSize"`
+}
+
+// fields missing in the request are left unchanged
+type PatchIngredientRequestDTO struct {
+	Name            *string  `json:"name" validate:"omitempty,min=1"`
+	CaloriesPerGram *float64 `json:"caloriesPerGram" validate:"omitempty,gte=0"`
+	// grams per gram, sodium in milligrams per gram
+	ProteinPerGram       *float64 `json:"proteinPerGram" validate:"omitempty,gte=0"`
+	FatPerGram           *float64 `json:"fatPerGram" validate:"omitempty,gte=0"`
+	CarbohydratesPerGram *float64 `json:"carbohydratesPerGram" validate:"omitempty,gte=0"`
+	FiberPerGram         *float64 `json:"fiberPerGram" validate:"omitempty,gte=0"`
+	SugarPerGram         *float64 `json:"sugarPerGram" validate:"omitempty,gte=0"`
+	SodiumPerGram        *float64 `json:"sodiumPerGram" validate:"omitempty,gte=0"`
+	Density              *float64 `json:"density" validate:"omitempty,gte=0"`
+	PieceWeight          *float64 `json:"pieceWeight" validate:"omitempty,gte=0"`
+	YieldFactor          *float64 `json:"yieldFactor" validate:"omitempty,gt=0"`
+	// empty list clears the tags
+	Allergens []string `json:"allergens"`
+	DietTags  []string `json:"dietTags"`
+}
+
+type IngredientDTO struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// name in the language from Accept-Language header, the catalog name when there is no translation
+	LocalizedName   string  `json:"localizedName"`
+	CaloriesPerGram float64 `json:"caloriesPerGram"`
+	// grams per gram, 0 when unknown
+	ProteinPerGram       float64 `json:"proteinPerGram"`
+	FatPerGram           float64 `json:"fatPerGram"`
+	CarbohydratesPerGram float64 `json:"carbohydratesPerGram"`
+	FiberPerGram         float64 `json:"fiberPerGram"`
+	SugarPerGram         float64 `json:"sugarPerGram"`
+	// milligrams per gram
+	SodiumPerGram float64  `json:"sodiumPerGram"`
+	Density       float64  `json:"density"`
+	PieceWeight   float64  `json:"pieceWeight"`
+	YieldFactor   float64  `json:"yieldFactor"`
+	Allergens     []string `json:"allergens"`
+	// false when empty allergens mean unknown
+	AllergensTagged bool                 `json:"allergensTagged"`
+	DietTags        []string             `json:"dietTags"`
+	Source          string               `json:"source,omitempty"`
+	SourceID        string               `json:"sourceId,omitempty"`
+	Aliases         []IngredientAliasDTO `json:"aliases"`
+}
+
+// recipes can use alias instead of ingredient name, preferred alias is shown to users of the locale
+type IngredientAliasRequestDTO struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// language tag like pl or en-GB, defaults to en
+	Locale    string `json:"locale" validate:"omitempty,max=35"`
+	Preferred bool   `json:"preferred"`
+}
+type IngredientAliasDTO struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Locale    string    `json:"locale"`
+	Preferred bool      `json:"preferred"`
+}
+
+// recalculation is nil when the change doesn't affect recipe totals
+type IngredientUpdateResponseDTO struct {
+	Ingredient    *IngredientDTO          `json:"ingredient"`
+	Recalculation *RecipeRecalculationDTO `json:"recalculation,omitempty"`
+}
+
+type RecipeRecalculationDTO struct {
+	ID           uuid.UUID                      `json:"id"`
+	IngredientID uuid.UUID                      `json:"ingredientId"`
+	Status       string                         `json:"status"`
+	Error        string                         `json:"error,omitempty"`
+	CreatedAt    time.Time                      `json:"createdAt"`
+	FinishedAt   *time.Time                     `json:"finishedAt,omitempty"`
+	Changes      []RecipeRecalculationChangeDTO `json:"changes"`
+}
+type RecipeRecalculationChangeDTO struct {
+	RecipeID         uuid.UUID `json:"recipeId"`
+	RecipeName       string    `json:"recipeName"`
+	PreviousCalories uint      `json:"previousCalories"`
+	Calories         uint      `json:"calories"`
+	PreviousWeight   uint      `json:"previousWeight"`
+	Weight           uint      `json:"weight"`
+	Version          uint      `json:"version"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// outcome of importing a food composition dataset into the catalog
+type IngredientImportReportDTO struct {
+	Source    string `json:"source"`
+	DryRun    bool   `json:"dryRun"`
+	Created   int    `json:"created"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	// updates changing calories, density, piece weight or yield factor recalculate recipes in background
+	Recalculations int                        `json:"recalculations"`
+	Conflicts      []IngredientImportIssueDTO `json:"conflicts"`
+	Failed         []IngredientImportIssueDTO `json:"failed"`
+}
+type IngredientImportIssueDTO struct {
+	SourceID string `json:"sourceId"`
+	Name     string `json:"name"`
+	// ingredient already using the name, nil for failures
+	ExistingID     *uuid.UUID `json:"existingId,omitempty"`
+	ExistingSource string     `json:"existingSource,omitempty"`
+	Reason         string     `json:"reason"`
+}
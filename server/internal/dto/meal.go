@@ -9,15 +9,22 @@ import (
 // --- Recipe Request DTOs ---
 type RecipeIngredientDefinitionDTO struct {
 	Name     string  `json:"name" validate:"required,min=2"`
-	Weight   float64 `json:"weight" validate:"required,gt=0"`
+	Weight   uint    `json:"weight" validate:"required_without=Quantity,omitempty,gt=0"`
+	Quantity float64 `json:"quantity" validate:"omitempty,gt=0"`
+	Unit     string  `json:"unit"`
 	Calories uint    `json:"calories" validate:"required,gte=0"`
 }
 
 type RecipeIngredientDetailDTO struct {
-	ID       uuid.UUID `json:"id"`
-	Name     string    `json:"name"`
-	Weight   uint      `json:"weight"`
-	Calories uint      `json:"calories"`
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Weight uint      `json:"weight"`
+	// quantity in the unit it was entered in, equals weight for grams
+	Quantity    float64  `json:"quantity"`
+	Unit        string   `json:"unit"`
+	YieldFactor float64  `json:"yieldFactor,omitempty"`
+	Calories    uint     `json:"calories"`
+	Allergens   []string `json:"allergens,omitempty"`
 }
 type RecipeDetailResponseDTO struct {
 	ID            uuid.UUID
@@ -26,6 +33,25 @@ type RecipeDetailResponseDTO struct {
 	TotalWeight   uint
 	TotalCalories uint
 	Volume        float64
+	Version       uint
+	OwnerID       *uuid.UUID
+	Visibility    string
+	Servings      uint
+	// TotalWeight is weight of the finished dish, RawWeight is sum of raw ingredients
+	RawWeight    uint
+	CookedWeight uint
+	// nutrition of one serving
+	ServingWeight      uint
+	CaloriesPerServing uint
+	Portions           []RecipePortionDetailDTO
+	// allergens of any ingredient, diet tags shared by all ingredients
+	Allergens []string
+	DietTags  []string
+}
+type RecipePortionDetailDTO struct {
+	Name     string `json:"name"`
+	Weight   uint   `json:"weight"`
+	Calories uint   `json:"calories"`
 }
 
 type MealDetailResponseDTO struct {
@@ -33,9 +59,19 @@ type MealDetailResponseDTO struct {
 	Name          string                      `json:"name"`
 	Ingredients   []RecipeIngredientDetailDTO `json:"ingredients"`
 	TotalWeight   uint                        `json:"totalWeight"`
+	Quantity      float64                     `json:"quantity"`
+	Unit          string                      `json:"unit"`
 	TotalCalories uint                        `json:"totalCalories"`
 	CreatedAt     time.Time                   `json:"createdAt,omitempty"`
 	UpdatedAt     time.Time                   `json:"updatedAt,omitempty"`
+	// allergies of the user found in the recipe, set for meals logged from images
+	AllergyWarnings []AllergyWarningDTO `json:"allergyWarnings,omitempty"`
+	// ingredients of the recognized recipe with allergens not reviewed yet, allergy warnings can't cover them
+	UnverifiedIngredients []string `json:"unverifiedIngredients,omitempty"`
+}
+type AllergyWarningDTO struct {
+	Allergen    string   `json:"allergen"`
+	Ingredients []string `json:"ingredients"`
 }
 
 // --- LoggedMeal Request DTO ---
@@ -67,24 +103,135 @@ type PaginatedLoggedMealsResponseDTO struct {
 	Page       int                     `json:"page"`
 	PageSize   int                     `json:"pageSize"`
 }
+
+// either weight in grams or quantity with unit, unit defaults to grams
 type RecipeIngredientUsageRequestDTO struct {
-	Name   string `json:"name" validate:"required"`
-	Weight uint   `json:"weight" validate:"required,gt=0"`
+	Name     string  `json:"name" validate:"required"`
+	Weight   uint    `json:"weight" validate:"required_without=Quantity,omitempty,gt=0"`
+	Quantity float64 `json:"quantity,omitempty" validate:"omitempty,gt=0"`
+	Unit     string  `json:"unit,omitempty"`
+	// overrides yield factor of the ingredient
+	YieldFactor float64 `json:"yieldFactor,omitempty" validate:"omitempty,gt=0"`
 }
 
 type CreateRecipeRequestDTO struct {
 	Name        string                            `json:"name" validate:"required,min=3"`
 	Ingredients []RecipeIngredientUsageRequestDTO `json:"ingredients" validate:"required,min=1,dive"`
 	Volume      float64                           `json:"volume"`
+	// private, shared or public, own recipes default to private, catalog recipes are always public
+	Visibility string `json:"visibility" validate:"omitempty,oneof=private shared public"`
+	// measured weight of the finished dish, estimated from ingredient yield factors when missing
+	CookedWeight uint `json:"cookedWeight,omitempty"`
+	// defaults to one serving
+	Servings uint               `json:"servings" validate:"omitempty,gt=0"`
+	Portions []RecipePortionDTO `json:"portions" validate:"omitempty,dive"`
+	// nil for recipes of the global catalog
+	OwnerID *uuid.UUID `json:"-"`
+}
+type RecipePortionDTO struct {
+	Name   string `json:"name" validate:"required,max=50"`
+	Weight uint   `json:"weight" validate:"required,gt=0"`
+}
+
+// nil fields are left unchanged, ingredients replace the whole list
+type PatchRecipeRequestDTO struct {
+	Name        *string                           `json:"name" validate:"omitempty,min=3"`
+	Ingredients []RecipeIngredientUsageRequestDTO `json:"ingredients" validate:"omitempty,min=1,dive"`
+	Volume      *float64                          `json:"volume" validate:"omitempty,gte=0"`
+	Visibility  *string                           `json:"visibility" validate:"omitempty,oneof=private shared public"`
+	Servings    *uint                             `json:"servings" validate:"omitempty,gt=0"`
+	// 0 switches back to estimate from yield factors
+	CookedWeight *uint `json:"cookedWeight"`
+	// replaces all portions, empty list removes them
+	Portions []RecipePortionDTO `json:"portions" validate:"omitempty,dive"`
+}
+type RecipeListQueryDTO struct {
+	Query    string `form:"q"`
+	Sort     string `form:"sort"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"pageSize"`
+	// lists only own recipes, requires authentication
+	Mine bool `form:"mine"`
+}
+type RecipeListItemDTO struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	OwnerID       *uuid.UUID `json:"ownerId,omitempty"`
+	Visibility    string     `json:"visibility"`
+	TotalWeight   uint       `json:"totalWeight"`
+	TotalCalories uint       `json:"totalCalories"`
+	Volume        float64    `json:"volume"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+type PaginatedRecipesResponseDTO struct {
+	Recipes    []RecipeListItemDTO `json:"recipes"`
+	TotalCount int64               `json:"totalCount"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"pageSize"`
+}
+type RecipeVersionDTO struct {
+	Version       uint                        `json:"version"`
+	Name          string                      `json:"name"`
+	Ingredients   []RecipeIngredientDetailDTO `json:"ingredients"`
+	TotalWeight   uint                        `json:"totalWeight"`
+	RawWeight     uint                        `json:"rawWeight,omitempty"`
+	TotalCalories uint                        `json:"totalCalories"`
+	Volume        float64                     `json:"volume"`
+	CreatedAt     time.Time                   `json:"createdAt"`
+	// difference to the previous version, nil for the first one
+	Changes *RecipeVersionDiffDTO `json:"changes,omitempty"`
+}
+type RecipeVersionDiffDTO struct {
+	PreviousName       string                      `json:"previousName,omitempty"`
+	AddedIngredients   []RecipeIngredientDetailDTO `json:"addedIngredients"`
+	RemovedIngredients []RecipeIngredientDetailDTO `json:"removedIngredients"`
+	ChangedIngredients []RecipeIngredientChangeDTO `json:"changedIngredients"`
+	WeightDelta        int                         `json:"weightDelta"`
+	CaloriesDelta      int                         `json:"caloriesDelta"`
+	VolumeDelta        float64                     `json:"volumeDelta"`
+}
+type RecipeIngredientChangeDTO struct {
+	ID               uuid.UUID `json:"id"`
+	Name             string    `json:"name"`
+	PreviousWeight   uint      `json:"previousWeight"`
+	Weight           uint      `json:"weight"`
+	PreviousCalories uint      `json:"previousCalories"`
+	Calories         uint      `json:"calories"`
 }
 type CreateIngredientRequestDTO struct {
 	Name            string
 	CaloriesPerGram float64
+	// grams per gram, 0 when unknown
+	ProteinPerGram       float64
+	FatPerGram           float64
+	CarbohydratesPerGram float64
+	FiberPerGram         float64
+	SugarPerGram         float64
+	// milligrams per gram
+	SodiumPerGram float64
+	// grams per millilitre, needed for volume units
+	Density float64
+	// grams of one piece, needed for piece unit
+	PieceWeight float64
+	// cooked weight per gram of raw ingredient, defaults to 1
+	YieldFactor float64
+	// synonyms and translations, ignored when the ingredient is replaced
+	Aliases []IngredientAliasRequestDTO
+	// values of models.Allergens and models.DietTags, missing allergens leave the ingredient untagged
+	// while an empty list marks it as having none
+	Allergens []string
+	DietTags  []string
 }
+
+// weight in grams, number of servings or quantity with unit, unit defaults to grams
+// and can also be "serving" or name of recipe portion
 type CreateMealRequestDTO struct {
-	Name   string    `json:"name" validate:"required,min=3"`
-	Weight uint      `json:"weight" validate:"required,min=1"`
-	UserID uuid.UUID `json:"-"`
+	Name     string    `json:"name" validate:"required,min=3"`
+	Weight   uint      `json:"weight" validate:"required_without_all=Quantity Servings,omitempty,min=1"`
+	Servings float64   `json:"servings,omitempty" validate:"omitempty,gt=0"`
+	Quantity float64   `json:"quantity,omitempty" validate:"omitempty,gt=0"`
+	Unit     string    `json:"unit,omitempty"`
+	UserID   uuid.UUID `json:"-"`
 }
 type MealResponseDTO struct {
 	ID            uuid.UUID `json:"id"`
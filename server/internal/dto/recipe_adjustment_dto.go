@@ -0,0 +1,29 @@
+package dto
+
+// exactly one target is required
+type ScaleRecipeRequestDTO struct {
+	// grams of the finished dish
+	Weight   uint `json:"weight" validate:"omitempty,gt=0"`
+	Servings uint `json:"servings" validate:"omitempty,gt=0"`
+	Calories uint `json:"calories" validate:"omitempty,gt=0"`
+	// stores the result as new version of own recipe
+	Save bool `json:"save"`
+}
+
+type SubstituteIngredientRequestDTO struct {
+	From string `json:"from" validate:"required"`
+	To   string `json:"to" validate:"required"`
+	// grams of the substitute, by default the replaced amount is kept in its unit when the substitute can be measured in it
+	Weight uint `json:"weight" validate:"omitempty,gt=0"`
+	// stores the result as new version of own recipe
+	Save bool `json:"save"`
+}
+
+// recipe after scaling or substitution together with differences to the original
+type AdjustedRecipeResponseDTO struct {
+	// scale factor, omitted for substitutions
+	Factor  float64                  `json:"factor,omitempty"`
+	Recipe  *RecipeDetailResponseDTO `json:"recipe"`
+	Changes *RecipeVersionDiffDTO    `json:"changes"`
+	Saved   bool                     `json:"saved"`
+}
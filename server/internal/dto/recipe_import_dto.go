@@ -0,0 +1,29 @@
+package dto
+
+// either URL of a page with schema.org Recipe markup or ingredient lines as free text
+type RecipeImportRequestDTO struct {
+	URL  string `json:"url" validate:"omitempty,url"`
+	Text string `json:"text"`
+	// name of the recipe imported from text
+	Name string `json:"name"`
+}
+type RecipeImportLineDTO struct {
+	Line     string  `json:"line"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit,omitempty"`
+	Name     string  `json:"name"`
+	// matched, estimated, needs_weight or unmatched
+	Status string `json:"status"`
+	// catalog ingredient with weight converted to grams, nil when unmatched
+	Ingredient *RecipeIngredientDetailDTO `json:"ingredient,omitempty"`
+}
+
+// draft is not saved, the user reviews it and submits Recipe to recipe creation
+type RecipeImportDraftDTO struct {
+	Name   string                `json:"name"`
+	Yield  string                `json:"yield,omitempty"`
+	Source string                `json:"source,omitempty"`
+	Lines  []RecipeImportLineDTO `json:"lines"`
+	// contains only lines resolved to catalog ingredient with known weight
+	Recipe CreateRecipeRequestDTO `json:"recipe"`
+}
@@ -3,3 +3,44 @@ package dto
 type RefreshTokenRequestDTO struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
+
+type TwoFactorEnrollResponseDTO struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+type TwoFactorCodeRequestDTO struct {
+	Code string `json:"code" validate:"required"`
+}
+type TwoFactorRecoveryCodesResponseDTO struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+type TwoFactorDisableRequestDTO struct {
+	// not needed by accounts without password which signed in recently
+	Password string `json:"password"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// returned by login instead of the token pair when 2FA is enabled
+type TwoFactorChallengeResponseDTO struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	ChallengeToken    string `json:"challengeToken"`
+}
+
+// either code from authenticator app or one of recovery codes is required
+type TwoFactorLoginRequestDTO struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+type OIDCAuthorizationResponseDTO struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+// bound from query for redirects, from form for Apple form_post and from JSON for the mobile app
+type OIDCCallbackRequestDTO struct {
+	Code  string `json:"code" form:"code"`
+	State string `json:"state" form:"state"`
+	Error string `json:"error" form:"error"`
+}
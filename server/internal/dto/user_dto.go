@@ -19,16 +19,59 @@ type LoginRequestDTO struct {
 	Password string `json:"password" validate:"required"`
 }
 type UserResponseDTO struct {
-	ID          uuid.UUID `json:"id"`
-	Username    string    `json:"username"`
-	Email       string    `json:"email"`
-	FirstName   string    `json:"firstName"`
-	LastName    string    `json:"lastName"`
-	DateOfBirth string    `json:"dateOfBirth"`
-	CreatedAt   time.Time `json:"createdAt"`
-	MealCount   int64     `json:"mealCount"`
+	ID          uuid.UUID          `json:"id"`
+	Username    string             `json:"username"`
+	Email       string             `json:"email"`
+	FirstName   string             `json:"firstName"`
+	LastName    string             `json:"lastName"`
+	DateOfBirth string             `json:"dateOfBirth"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	MealCount   int64              `json:"mealCount"`
+	Role        string             `json:"role"`
+	Preferences UserPreferencesDTO `json:"preferences"`
+	// false for accounts created by signing in with a provider until they set a password
+	HasPassword bool `json:"hasPassword"`
+	// set when account is scheduled for deletion
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+}
+type UserPreferencesDTO struct {
+	Language         string   `json:"language"`
+	UnitSystem       string   `json:"unitSystem"`
+	DailyCalorieGoal uint     `json:"dailyCalorieGoal"`
+	Allergies        []string `json:"allergies"`
 }
 type LoginResponseDTO struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
 }
+type UpdateUserRoleRequestDTO struct {
+	Role string `json:"role" validate:"required,oneof=user editor admin"`
+}
+
+// nil fields are left unchanged
+type UpdateProfileRequestDTO struct {
+	FirstName   *string                          `json:"firstName" validate:"omitempty,min=2"`
+	LastName    *string                          `json:"lastName" validate:"omitempty,min=1"`
+	DateOfBirth *time.Time                       `json:"dateOfBirth"`
+	Preferences *UpdateUserPreferencesRequestDTO `json:"preferences"`
+}
+type UpdateUserPreferencesRequestDTO struct {
+	Language         *string `json:"language" validate:"omitempty,min=2,max=10"`
+	UnitSystem       *string `json:"unitSystem" validate:"omitempty,oneof=metric imperial"`
+	DailyCalorieGoal *uint   `json:"dailyCalorieGoal" validate:"omitempty,max=20000"`
+	// empty list clears allergies
+	Allergies []string `json:"allergies"`
+}
+
+// password is not needed by accounts without one which signed in recently, see User.HasPassword
+type ChangePasswordRequestDTO struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+}
+type ChangeEmailRequestDTO struct {
+	NewEmail string `json:"newEmail" validate:"required,email"`
+	Password string `json:"password"`
+}
+type ConfirmEmailChangeRequestDTO struct {
+	Token string `json:"token" validate:"required"`
+}
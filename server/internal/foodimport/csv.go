@@ -0,0 +1,162 @@
+package foodimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMissingColumns = errors.New("name and calories or energy_kj columns are required")
+	// databases publish energy in kcal, kJ or both, a column named only energy can't be trusted
+	ErrAmbiguousEnergy = errors.New("energy column must state its unit, name it energy_kcal or energy_kj")
+	ErrMissingName     = errors.New("name is empty")
+	ErrInvalidNumber   = errors.New("invalid number")
+)
+
+const kilojoulesPerKcal = 4.184
+
+// accepted headers of generic CSV columns, matched ignoring case
+var csvColumns = map[string][]string{
+	"id":            {"id", "source_id", "code"},
+	"name":          {"name", "description", "food"},
+	"calories":      {"calories", "kcal", "energy_kcal"},
+	"energy_kj":     {"energy_kj", "kj"},
+	"protein":       {"protein"},
+	"fat":           {"fat", "total_fat"},
+	"carbohydrates": {"carbohydrates", "carbs", "carbohydrate"},
+	"fiber":         {"fiber", "fibre"},
+	"sugar":         {"sugar", "sugars"},
+	"sodium":        {"sodium", "sodium_mg"},
+	"density":       {"density"},
+	"piece_weight":  {"piece_weight", "pieceweight"},
+	"yield_factor":  {"yield_factor", "yieldfactor"},
+}
+
+// reads CSV with header row, nutrients per 100 g and sodium in milligrams, only name and energy in kcal
+// or kJ are required,
+// files delimited by semicolons may use decimal commas as spreadsheets in many locales export them,
+// rows without ID use the name so re-running the import updates them
+func ReadCSV(r io.Reader) ([]Record, []RowError, error) {
+	buffered := bufio.NewReader(r)
+	firstLine, err := buffered.Peek(4096)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, nil, err
+	}
+	if line, _, found := bytes.Cut(firstLine, []byte("\n")); found {
+		firstLine = line
+	}
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	decimalComma := false
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+		decimalComma = true
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, title := range header {
+		title = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(title, "\ufeff")))
+		if title == "energy" {
+			return nil, nil, ErrAmbiguousEnergy
+		}
+		for column, titles := range csvColumns {
+			for _, accepted := range titles {
+				if title == accepted {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, nil, ErrMissingColumns
+	}
+	_, hasKcal := columns["calories"]
+	kjColumn, hasKJ := columns["energy_kj"]
+	if !hasKcal && !hasKJ {
+		return nil, nil, ErrMissingColumns
+	}
+	if !hasKcal {
+		// kJ values are converted below
+		columns["calories"] = kjColumn
+	}
+	var records []Record
+	var rowErrors []RowError
+	row := 1
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, rowErrors, nil
+		}
+		row++
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: row, Err: err})
+			continue
+		}
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(values) {
+				return ""
+			}
+			return strings.TrimSpace(values[i])
+		}
+		record := Record{SourceID: value("id"), Name: value("name")}
+		if record.SourceID == "" {
+			record.SourceID = record.Name
+		}
+		if record.Name == "" {
+			rowErrors = append(rowErrors, RowError{Row: row, SourceID: record.SourceID, Err: ErrMissingName})
+			continue
+		}
+		fields := []struct {
+			column string
+			target *float64
+		}{
+			{"calories", &record.Calories},
+			{"protein", &record.Protein},
+			{"fat", &record.Fat},
+			{"carbohydrates", &record.Carbohydrates},
+			{"fiber", &record.Fiber},
+			{"sugar", &record.Sugar},
+			{"sodium", &record.Sodium},
+			{"density", &record.Density},
+			{"piece_weight", &record.PieceWeight},
+			{"yield_factor", &record.YieldFactor},
+		}
+		var rowErr error
+		for _, field := range fields {
+			raw := value(field.column)
+			if raw == "" {
+				if field.column == "calories" {
+					rowErr = ErrMissingEnergy
+				}
+				continue
+			}
+			if decimalComma {
+				raw = strings.Replace(raw, ",", ".", 1)
+			}
+			number, err := strconv.ParseFloat(raw, 64)
+			if err != nil || number < 0 {
+				rowErr = fmt.Errorf("%w in %s column: %q", ErrInvalidNumber, field.column, raw)
+				break
+			}
+			*field.target = number
+		}
+		if !hasKcal && rowErr == nil {
+			record.Calories = round(record.Calories / kilojoulesPerKcal)
+		}
+		if rowErr != nil {
+			rowErrors = append(rowErrors, RowError{Row: row, SourceID: record.SourceID, Name: record.Name, Err: rowErr})
+			continue
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,65 @@
+package foodimport
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	file := "\ufeffID,Name,kcal,Protein,Fat,Carbs,Fibre,Sugars,Sodium_mg,Density\n" +
+		"1,Milk,64,3.3,3.6,4.8,0,4.8,43,1.03\n" +
+		",Oat flakes,372,13.5,7,58.7,10.1,1.1,\n" +
+		"3,,100\n" +
+		"4,Butter,\n" +
+		"5,Salt,0,,,,,,38758,-1\n"
+	records, rowErrors, err := ReadCSV(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	want := []Record{
+		{SourceID: "1", Name: "Milk", Calories: 64, Protein: 3.3, Fat: 3.6, Carbohydrates: 4.8, Sugar: 4.8, Sodium: 43, Density: 1.03},
+		// rows without ID are keyed by name
+		{SourceID: "Oat flakes", Name: "Oat flakes", Calories: 372, Protein: 13.5, Fat: 7, Carbohydrates: 58.7, Fiber: 10.1, Sugar: 1.1},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+	wantErrors := []error{ErrMissingName, ErrMissingEnergy, ErrInvalidNumber}
+	if len(rowErrors) != len(wantErrors) {
+		t.Fatalf("row errors = %v, want %d", rowErrors, len(wantErrors))
+	}
+	for i, rowErr := range rowErrors {
+		if rowErr.Row != i+4 || !errors.Is(rowErr, wantErrors[i]) {
+			t.Errorf("row error %d = %v, want row %d with %v", i, rowErr, i+4, wantErrors[i])
+		}
+	}
+}
+
+func TestReadCSVSemicolonsAndKilojoules(t *testing.T) {
+	records, rowErrors, err := ReadCSV(strings.NewReader("code;food;energy_kj;protein\nA1;Chleb;1046;8,5\n"))
+	if err != nil || len(rowErrors) != 0 {
+		t.Fatalf("ReadCSV: %v %v", err, rowErrors)
+	}
+	want := []Record{{SourceID: "A1", Name: "Chleb", Calories: 250, Protein: 8.5}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+}
+
+func TestReadCSVHeaders(t *testing.T) {
+	tests := []struct {
+		header string
+		want   error
+	}{
+		{"name,energy\n", ErrAmbiguousEnergy},
+		{"name,protein\n", ErrMissingColumns},
+		{"id,kcal\n", ErrMissingColumns},
+	}
+	for _, tt := range tests {
+		if _, _, err := ReadCSV(strings.NewReader(tt.header)); !errors.Is(err, tt.want) {
+			t.Errorf("header %q: err = %v, want %v", tt.header, err, tt.want)
+		}
+	}
+}
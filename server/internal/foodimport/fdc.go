@@ -0,0 +1,252 @@
+package foodimport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// source of records read from USDA FoodData Central downloads
+const FDCSource = "usda-fdc"
+
+var ErrMissingEnergy = errors.New("food has no energy value")
+
+// FoodData Central nutrient IDs
+const (
+	fdcEnergy                = 1008
+	fdcEnergyAtwaterGeneral  = 2047
+	fdcEnergyAtwaterSpecific = 2048
+	fdcProtein               = 1003
+	fdcFat                   = 1004
+	fdcCarbohydrates         = 1005
+	fdcCarbohydratesSummed   = 1050
+	fdcFiber                 = 1079
+	fdcSugar                 = 2000
+	fdcSugarNLEA             = 1063
+	fdcSodium                = 1093
+)
+
+var fdcNutrients = map[int]bool{
+	fdcEnergy: true, fdcEnergyAtwaterGeneral: true, fdcEnergyAtwaterSpecific: true,
+	fdcProtein: true, fdcFat: true, fdcCarbohydrates: true, fdcCarbohydratesSummed: true,
+	fdcFiber: true, fdcSugar: true, fdcSugarNLEA: true, fdcSodium: true,
+}
+
+type fdcFood struct {
+	FDCID         int    `json:"fdcId"`
+	Description   string `json:"description"`
+	FoodNutrients []struct {
+		Nutrient struct {
+			ID int `json:"id"`
+		} `json:"nutrient"`
+		Amount float64 `json:"amount"`
+	} `json:"foodNutrients"`
+	FoodPortions []struct {
+		Amount      float64 `json:"amount"`
+		GramWeight  float64 `json:"gramWeight"`
+		Modifier    string  `json:"modifier"`
+		MeasureUnit struct {
+			Name string `json:"name"`
+		} `json:"measureUnit"`
+	} `json:"foodPortions"`
+}
+
+// reads JSON download of any FoodData Central dataset, e.g. {"SRLegacyFoods": [...]},
+// foods are decoded one by one so the branded foods file doesn't have to fit in memory
+func ReadFDCJSON(r io.Reader) ([]Record, []RowError, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, nil, err
+	}
+	var records []Record
+	var rowErrors []RowError
+	row := 0
+	for decoder.More() {
+		if _, err := decoder.Token(); err != nil {
+			return nil, nil, fmt.Errorf("invalid FoodData Central file: %w", err)
+		}
+		if err := expectDelim(decoder, '['); err != nil {
+			return nil, nil, err
+		}
+		for decoder.More() {
+			row++
+			var food fdcFood
+			if err := decoder.Decode(&food); err != nil {
+				return nil, nil, fmt.Errorf("invalid food at position %d: %w", row, err)
+			}
+			nutrients := make(map[int]float64)
+			for _, nutrient := range food.FoodNutrients {
+				nutrients[nutrient.Nutrient.ID] = nutrient.Amount
+			}
+			var portions []portion
+			for _, p := range food.FoodPortions {
+				portions = append(portions, portion{amount: p.Amount, unit: p.MeasureUnit.Name, modifier: p.Modifier, grams: p.GramWeight})
+			}
+			record, err := fdcRecord(strconv.Itoa(food.FDCID), food.Description, nutrients, portions)
+			if err != nil {
+				rowErrors = append(rowErrors, RowError{Row: row, SourceID: record.SourceID, Name: record.Name, Err: err})
+				continue
+			}
+			records = append(records, record)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, nil, fmt.Errorf("invalid FoodData Central file: %w", err)
+		}
+	}
+	return records, rowErrors, nil
+}
+
+// reads CSV download of FoodData Central, the directory has food.csv, food_nutrient.csv
+// and optionally food_portion.csv with measure_unit.csv
+func ReadFDCCSV(dir string) ([]Record, []RowError, error) {
+	// only nutrients stored in the catalog are kept, the branded foods file has tens of millions of rows
+	nutrients := make(map[string]map[int]float64)
+	err := eachCSVRow(filepath.Join(dir, "food_nutrient.csv"), func(row map[string]string) error {
+		id, err := strconv.Atoi(row["nutrient_id"])
+		if err != nil || !fdcNutrients[id] {
+			return nil
+		}
+		amount, err := strconv.ParseFloat(row["amount"], 64)
+		if err != nil {
+			return nil
+		}
+		if nutrients[row["fdc_id"]] == nil {
+			nutrients[row["fdc_id"]] = make(map[int]float64)
+		}
+		nutrients[row["fdc_id"]][id] = amount
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	portions, err := readFDCPortions(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var records []Record
+	var rowErrors []RowError
+	// header is the first row of the file
+	row := 1
+	err = eachCSVRow(filepath.Join(dir, "food.csv"), func(food map[string]string) error {
+		row++
+		record, err := fdcRecord(food["fdc_id"], food["description"], nutrients[food["fdc_id"]], portions[food["fdc_id"]])
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: row, SourceID: record.SourceID, Name: record.Name, Err: err})
+			return nil
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return records, rowErrors, nil
+}
+
+// portions keyed by fdc_id, empty when the files are missing
+func readFDCPortions(dir string) (map[string][]portion, error) {
+	portions := make(map[string][]portion)
+	units := make(map[string]string)
+	err := eachCSVRow(filepath.Join(dir, "measure_unit.csv"), func(row map[string]string) error {
+		units[row["id"]] = row["name"]
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	err = eachCSVRow(filepath.Join(dir, "food_portion.csv"), func(row map[string]string) error {
+		amount, _ := strconv.ParseFloat(row["amount"], 64)
+		grams, _ := strconv.ParseFloat(row["gram_weight"], 64)
+		portions[row["fdc_id"]] = append(portions[row["fdc_id"]], portion{
+			amount:   amount,
+			unit:     units[row["measure_unit_id"]],
+			modifier: row["modifier"],
+			grams:    grams,
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return portions, nil
+}
+func fdcRecord(id string, description string, nutrients map[int]float64, portions []portion) (Record, error) {
+	record := Record{
+		SourceID: id,
+		Name:     strings.ToLower(strings.TrimSpace(description)),
+	}
+	calories, ok := firstNutrient(nutrients, fdcEnergy, fdcEnergyAtwaterGeneral, fdcEnergyAtwaterSpecific)
+	if !ok {
+		return record, ErrMissingEnergy
+	}
+	record.Calories = calories
+	record.Protein, _ = firstNutrient(nutrients, fdcProtein)
+	record.Fat, _ = firstNutrient(nutrients, fdcFat)
+	record.Carbohydrates, _ = firstNutrient(nutrients, fdcCarbohydrates, fdcCarbohydratesSummed)
+	record.Fiber, _ = firstNutrient(nutrients, fdcFiber)
+	record.Sugar, _ = firstNutrient(nutrients, fdcSugar, fdcSugarNLEA)
+	record.Sodium, _ = firstNutrient(nutrients, fdcSodium)
+	applyPortions(&record, portions)
+	return record, nil
+}
+
+// datasets differ in which of the equivalent nutrients they report
+func firstNutrient(nutrients map[int]float64, ids ...int) (float64, bool) {
+	for _, id := range ids {
+		if amount, ok := nutrients[id]; ok {
+			return amount, true
+		}
+	}
+	return 0, false
+}
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("invalid FoodData Central file: %w", err)
+	}
+	if token != delim {
+		return fmt.Errorf("invalid FoodData Central file: expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// calls fn with every row keyed by header columns
+func eachCSVRow(path string, fn func(row map[string]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	for {
+		values, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
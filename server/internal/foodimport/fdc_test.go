@@ -0,0 +1,78 @@
+package foodimport
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fdcJSON = `{"SRLegacyFoods": [
+ {"fdcId": 171287, "description": "Egg, whole, raw, fresh",
+  "foodNutrients": [{"nutrient": {"id": 1008}, "amount": 143}, {"nutrient": {"id": 1003}, "amount": 12.6},
+   {"nutrient": {"id": 1004}, "amount": 9.51}, {"nutrient": {"id": 1005}, "amount": 0.72}, {"nutrient": {"id": 1093}, "amount": 142}],
+  "foodPortions": [{"amount": 1, "gramWeight": 243, "modifier": "", "measureUnit": {"name": "cup"}},
+   {"amount": 1, "gramWeight": 50, "modifier": "medium", "measureUnit": {"name": "undetermined"}}]},
+ {"fdcId": 1, "description": "Mystery", "foodNutrients": [{"nutrient": {"id": 1003}, "amount": 1}]}
+], "FoundationFoods": [
+ {"fdcId": 2, "description": "Olive oil", "foodNutrients": [{"nutrient": {"id": 2047}, "amount": 884}, {"nutrient": {"id": 1004}, "amount": 100}]}
+]}`
+
+func TestReadFDCJSON(t *testing.T) {
+	records, rowErrors, err := ReadFDCJSON(strings.NewReader(fdcJSON))
+	if err != nil {
+		t.Fatalf("ReadFDCJSON: %v", err)
+	}
+	want := []Record{
+		{SourceID: "171287", Name: "egg, whole, raw, fresh", Calories: 143, Protein: 12.6, Fat: 9.51, Carbohydrates: 0.72, Sodium: 142, Density: 1.0271, PieceWeight: 50},
+		// Atwater energy is used when the food has no plain energy value
+		{SourceID: "2", Name: "olive oil", Calories: 884, Fat: 100},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].SourceID != "1" || !errors.Is(rowErrors[0], ErrMissingEnergy) {
+		t.Fatalf("row errors = %v, want missing energy of food 1", rowErrors)
+	}
+	if _, _, err := ReadFDCJSON(strings.NewReader(`[]`)); err == nil {
+		t.Fatal("array at top level should be rejected")
+	}
+}
+
+func TestReadFDCCSV(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"food.csv":          "\ufefffdc_id,data_type,description\n100,foundation_food,\"Milk, whole\"\n101,foundation_food,Water\n",
+		"food_nutrient.csv": "id,fdc_id,nutrient_id,amount\n1,100,1008,61\n2,100,1003,3.27\n3,100,1062,255\n4,100,2000,5.05\n",
+		"measure_unit.csv":  "id,name\n1000,cup\n1001,piece\n",
+		"food_portion.csv":  "id,fdc_id,amount,measure_unit_id,modifier,gram_weight\n1,100,1,1000,,244\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, rowErrors, err := ReadFDCCSV(dir)
+	if err != nil {
+		t.Fatalf("ReadFDCCSV: %v", err)
+	}
+	want := []Record{{SourceID: "100", Name: "milk, whole", Calories: 61, Protein: 3.27, Sugar: 5.05, Density: 1.0313}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Row != 3 || !errors.Is(rowErrors[0], ErrMissingEnergy) {
+		t.Fatalf("row errors = %v, want missing energy in row 3", rowErrors)
+	}
+	// portions are optional
+	for _, name := range []string{"measure_unit.csv", "food_portion.csv"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, _, err = ReadFDCCSV(dir)
+	if err != nil || len(records) != 1 || records[0].Density != 0 {
+		t.Fatalf("without portions: records = %+v, err = %v", records, err)
+	}
+}
@@ -0,0 +1,92 @@
+package foodimport
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// food of a composition database, nutrients are per 100 g as the databases publish them
+type Record struct {
+	SourceID string
+	Name     string
+	// kcal
+	Calories float64
+	// grams
+	Protein       float64
+	Fat           float64
+	Carbohydrates float64
+	Fiber         float64
+	Sugar         float64
+	// milligrams
+	Sodium float64
+	// grams per millilitre, 0 when unknown
+	Density float64
+	// grams of one piece, 0 when unknown
+	PieceWeight float64
+	// cooked weight per gram of raw food, 0 when unknown
+	YieldFactor float64
+}
+
+// row which couldn't be read, the rest of the file is still imported
+type RowError struct {
+	Row      int
+	SourceID string
+	Name     string
+	Err      error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d (%s): %v", e.Row, e.Name, e.Err)
+}
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// household measure of a food, e.g. 1 cup weighing 240 g
+type portion struct {
+	amount   float64
+	unit     string
+	modifier string
+	grams    float64
+}
+
+// millilitres of volume measures used by the databases
+var portionVolumes = map[string]float64{
+	"cup":         236.5882365,
+	"tablespoon":  14.78676478125,
+	"tbsp":        14.78676478125,
+	"teaspoon":    4.92892159375,
+	"tsp":         4.92892159375,
+	"ml":          1,
+	"milliliter":  1,
+	"millilitre":  1,
+	"fl oz":       29.5735295625,
+	"fluid ounce": 29.5735295625,
+}
+
+// first volume measure gives density, first piece measure gives piece weight
+func applyPortions(record *Record, portions []portion) {
+	for _, p := range portions {
+		if p.amount <= 0 || p.grams <= 0 {
+			continue
+		}
+		unit := strings.ToLower(strings.TrimSpace(p.unit))
+		modifier := strings.ToLower(strings.TrimSpace(p.modifier))
+		if millilitres, ok := portionVolumes[unit]; ok && record.Density == 0 {
+			record.Density = round(p.grams / (p.amount * millilitres))
+			continue
+		}
+		// SR Legacy describes pieces only by modifier, e.g. "medium (3" dia)"
+		isPiece := unit == "piece" || unit == "each" || unit == "medium" ||
+			(unit == "undetermined" || unit == "") && strings.HasPrefix(modifier, "medium")
+		if isPiece && record.PieceWeight == 0 {
+			record.PieceWeight = round(p.grams / p.amount)
+		}
+	}
+}
+
+// keeps four decimal places, enough for per gram values
+func round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
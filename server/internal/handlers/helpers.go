@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"foodgenie/internal/app"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/locale"
+	"foodgenie/internal/logging"
+	"foodgenie/internal/models"
+	"foodgenie/internal/services"
+	"foodgenie/internal/units"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// reads user ID set by AuthCheck, writes error response when missing
+func userIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	userIDUntyped, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return uuid.Nil, false
+	}
+	userID, ok := userIDUntyped.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format in context"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// reads user ID set by AuthCheck or OptionalAuth, nil for anonymous requests
+func optionalUserID(c *gin.Context) *uuid.UUID {
+	userIDUntyped, exists := c.Get("userID")
+	if !exists {
+		return nil
+	}
+	userID, ok := userIDUntyped.(uuid.UUID)
+	if !ok {
+		return nil
+	}
+	return &userID
+}
+
+// session of the access token, uuid.Nil for tokens issued before sessions were introduced
+func currentSessionID(c *gin.Context) uuid.UUID {
+	sessionID, _ := c.Get("sessionID")
+	id, _ := sessionID.(uuid.UUID)
+	return id
+}
+
+// signed in user may change global recipes, public routes use it to allow saving catalog recipes
+func canManageCatalog(c *gin.Context) bool {
+	return models.HasPermission(c.GetString("role"), models.PermissionCatalogWrite)
+}
+
+// stores languages from Accept-Language header in request context, services use them for ingredient names
+func Localize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if languages := locale.Parse(c.GetHeader("Accept-Language")); len(languages) > 0 {
+			c.Request = c.Request.WithContext(locale.WithLanguages(c.Request.Context(), languages))
+		}
+		c.Next()
+	}
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// takes X-Request-ID of the caller, e.g. a proxy, or generates one, the ID is returned in the response
+// and logged with records of the request
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// one record per request, headers and query are left out as they carry tokens and OIDC codes
+func LogRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// IDs of other systems are accepted when they can't break log lines
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// quantity can't be converted to grams, caused by the request
+func isQuantityError(err error) bool {
+	return errors.Is(err, services.ErrInvalidQuantity) ||
+		errors.Is(err, units.ErrUnknownUnit) ||
+		errors.Is(err, units.ErrDensityUnknown) ||
+		errors.Is(err, units.ErrPieceWeightUnknown) ||
+		errors.Is(err, units.ErrNonPositiveQuantity)
+}
+
+// issues token pair for authenticated user or 2FA challenge when it is enabled
+func writeLoginResponse(c *gin.Context, app *app.App, user *models.User) {
+	if user.TwoFactorEnabled {
+		challengeToken, err := app.TwoFactorService.StartLogin(c.Request.Context(), user)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed generating challenge token"})
+			return
+		}
+		c.JSON(http.StatusOK, dto.TwoFactorChallengeResponseDTO{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+		})
+		return
+	}
+	writeTokenPair(c, app, user)
+}
+
+// issues token pair bound to a new session
+func writeTokenPair(c *gin.Context, app *app.App, user *models.User) {
+	response, err := app.UserService.IssueTokens(c.Request.Context(), user)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed generating tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
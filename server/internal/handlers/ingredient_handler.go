@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"foodgenie/internal/app"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type IngredientHandler struct {
@@ -28,6 +32,152 @@ func (h *IngredientHandler) CreateIngredient(c *gin.Context) {
 	ing, err := h.App.IngredientService.CreateIngredient(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create ingredient " + err.Error()})
+		return
 	}
 	c.JSON(http.StatusCreated, ing)
 }
+
+// searches ingredients by name, used for autocomplete when building recipes
+func (h *IngredientHandler) ListIngredients(c *gin.Context) {
+	var query dto.IngredientListQueryDTO
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+	response, err := h.App.IngredientService.ListIngredients(c.Request.Context(), &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list ingredients"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+func (h *IngredientHandler) GetIngredientByID(c *gin.Context) {
+	ingredientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ingredient ID format"})
+		return
+	}
+	ingredient, err := h.App.IngredientService.GetIngredientByID(c.Request.Context(), ingredientID)
+	if err != nil {
+		writeIngredientError(c, err, "Could not retrieve ingredient")
+		return
+	}
+	c.JSON(http.StatusOK, ingredient)
+}
+func (h *IngredientHandler) DeleteIngredient(c *gin.Context) {
+	ingredientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ingredient ID format"})
+		return
+	}
+	if err := h.App.IngredientService.DeleteIngredient(c.Request.Context(), ingredientID); err != nil {
+		writeIngredientError(c, err, "Failed to delete ingredient")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "ingredient deleted successfully"})
+}
+func (h *IngredientHandler) ReplaceIngredient(c *gin.Context) {
+	ingredientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ingredient ID format"})
+		return
+	}
+	var req dto.CreateIngredientRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	response, err := h.App.IngredientService.ReplaceIngredient(c.Request.Context(), ingredientID, req)
+	if err != nil {
+		writeIngredientError(c, err, "Failed to update ingredient")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+func (h *IngredientHandler) PatchIngredient(c *gin.Context) {
+	ingredientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ingredient ID format"})
+		return
+	}
+	var req dto.PatchIngredientRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	response, err := h.App.IngredientService.PatchIngredient(c.Request.Context(), ingredientID, req)
+	if err != nil {
+		writeIngredientError(c, err, "Failed to update ingredient")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// adds synonym or translation, preferred alias is shown to users of its locale
+func (h *IngredientHandler) AddIngredientAlias(c *gin.Context) {
+	ingredientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ingredient ID format"})
+		return
+	}
+	var req dto.IngredientAliasRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	ingredient, err := h.App.IngredientService.AddIngredientAlias(c.Request.Context(), ingredientID, req)
+	if err != nil {
+		writeIngredientError(c, err, "Failed to add alias")
+		return
+	}
+	c.JSON(http.StatusCreated, ingredient)
+}
+func (h *IngredientHandler) DeleteIngredientAlias(c *gin.Context) {
+	ingredientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ingredient ID format"})
+		return
+	}
+	aliasID, err := uuid.Parse(c.Param("aliasId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alias ID format"})
+		return
+	}
+	if err := h.App.IngredientService.DeleteIngredientAlias(c.Request.Context(), ingredientID, aliasID); err != nil {
+		writeIngredientError(c, err, "Failed to delete alias")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "alias deleted successfully"})
+}
+
+// report of recipes recalculated after ingredient change
+func (h *IngredientHandler) GetRecalculation(c *gin.Context) {
+	recalculationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recalculation ID format"})
+		return
+	}
+	recalculation, err := h.App.IngredientService.GetRecalculation(c.Request.Context(), recalculationID)
+	if err != nil {
+		writeIngredientError(c, err, "Could not retrieve recalculation")
+		return
+	}
+	c.JSON(http.StatusOK, recalculation)
+}
+func writeIngredientError(c *gin.Context, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrIngredientInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidIngredient) || errors.Is(err, services.ErrIngredientNameTaken) ||
+		errors.Is(err, services.ErrInvalidAlias) || errors.Is(err, services.ErrAliasTaken) ||
+		errors.Is(err, services.ErrUnknownTag) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message + " " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"foodgenie/internal/app"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	App *app.App
+}
+
+func NewOIDCHandler(app *app.App) *OIDCHandler {
+	return &OIDCHandler{
+		App: app,
+	}
+}
+
+// returns URL the app opens in browser to sign in at the provider
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	response, err := h.App.OIDCService.StartLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownOIDCProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// finishes sign-in and issues FoodGenie tokens
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req dto.OIDCCallbackRequestDTO
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	user, err := h.App.OIDCService.CompleteLogin(c.Request.Context(), c.Param("provider"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownOIDCProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOIDCEmailConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in failed"})
+		}
+		return
+	}
+	writeLoginResponse(c, h.App, user)
+}
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"foodgenie/internal/app"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/recipeimport"
+	"foodgenie/internal/services"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type RecipeHandler struct {
@@ -26,7 +32,28 @@ func (h *RecipeHandler) CreateRecipe(c *gin.Context) {
 	}
 	recipe, err := h.App.RecipeService.CreateRecipe(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create recipe " + err.Error()})
+		writeRecipeError(c, err, "Failed to create recipe")
+		return
+	}
+	c.JSON(http.StatusCreated, recipe)
+}
+
+// creates recipe owned by the signed in user, private unless requested otherwise
+func (h *RecipeHandler) CreateMyRecipe(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.CreateRecipeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	req.OwnerID = &userID
+	recipe, err := h.App.RecipeService.CreateRecipe(c.Request.Context(), &req)
+	if err != nil {
+		writeRecipeError(c, err, "Failed to create recipe")
+		return
 	}
 	c.JSON(http.StatusCreated, recipe)
 }
@@ -35,7 +62,7 @@ func (h *RecipeHandler) GetRecipeByName(c *gin.Context) {
 	if recipeName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Recipe name parameter is missing in the URL path."})
 	}
-	recipeDTO, err := h.App.RecipeService.GetRecipeByName(c.Request.Context(), recipeName)
+	recipeDTO, err := h.App.RecipeService.GetRecipeByName(c.Request.Context(), recipeName, optionalUserID(c))
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -47,3 +74,253 @@ func (h *RecipeHandler) GetRecipeByName(c *gin.Context) {
 
 	c.JSON(http.StatusOK, recipeDTO)
 }
+func (h *RecipeHandler) ListRecipes(c *gin.Context) {
+	var query dto.RecipeListQueryDTO
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters: " + err.Error()})
+		return
+	}
+	viewerID := optionalUserID(c)
+	if query.Mine && viewerID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in to list own recipes"})
+		return
+	}
+	response, err := h.App.RecipeService.ListRecipes(c.Request.Context(), &query, viewerID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRecipeSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not list recipes"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+func (h *RecipeHandler) GetRecipeByID(c *gin.Context) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	recipeDTO, err := h.App.RecipeService.GetRecipeByID(c.Request.Context(), recipeID, optionalUserID(c))
+	if err != nil {
+		writeRecipeError(c, err, "Could not retrieve recipe")
+		return
+	}
+	c.JSON(http.StatusOK, recipeDTO)
+}
+
+// catalog routes manage global recipes, own routes manage recipes of the signed in user
+func (h *RecipeHandler) ReplaceRecipe(c *gin.Context) {
+	h.replaceRecipe(c, nil)
+}
+func (h *RecipeHandler) ReplaceMyRecipe(c *gin.Context) {
+	if userID, ok := userIDFromContext(c); ok {
+		h.replaceRecipe(c, &userID)
+	}
+}
+func (h *RecipeHandler) PatchRecipe(c *gin.Context) {
+	h.patchRecipe(c, nil)
+}
+func (h *RecipeHandler) PatchMyRecipe(c *gin.Context) {
+	if userID, ok := userIDFromContext(c); ok {
+		h.patchRecipe(c, &userID)
+	}
+}
+func (h *RecipeHandler) DeleteRecipe(c *gin.Context) {
+	h.deleteRecipe(c, nil)
+}
+func (h *RecipeHandler) DeleteMyRecipe(c *gin.Context) {
+	if userID, ok := userIDFromContext(c); ok {
+		h.deleteRecipe(c, &userID)
+	}
+}
+func (h *RecipeHandler) replaceRecipe(c *gin.Context, ownerID *uuid.UUID) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	var req dto.CreateRecipeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	recipeDTO, err := h.App.RecipeService.ReplaceRecipe(c.Request.Context(), recipeID, ownerID, &req)
+	if err != nil {
+		writeRecipeError(c, err, "Failed to update recipe")
+		return
+	}
+	c.JSON(http.StatusOK, recipeDTO)
+}
+func (h *RecipeHandler) patchRecipe(c *gin.Context, ownerID *uuid.UUID) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	var req dto.PatchRecipeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	recipeDTO, err := h.App.RecipeService.PatchRecipe(c.Request.Context(), recipeID, ownerID, &req)
+	if err != nil {
+		writeRecipeError(c, err, "Failed to update recipe")
+		return
+	}
+	c.JSON(http.StatusOK, recipeDTO)
+}
+func (h *RecipeHandler) deleteRecipe(c *gin.Context, ownerID *uuid.UUID) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	if err := h.App.RecipeService.DeleteRecipe(c.Request.Context(), recipeID, ownerID); err != nil {
+		writeRecipeError(c, err, "Failed to delete recipe")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "recipe deleted successfully"})
+}
+func (h *RecipeHandler) GetRecipeVersions(c *gin.Context) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	versions, err := h.App.RecipeService.GetRecipeVersions(c.Request.Context(), recipeID, optionalUserID(c))
+	if err != nil {
+		writeRecipeError(c, err, "Could not retrieve recipe versions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// returns draft recipe parsed from uploaded HTML page (multipart field "file"), URL or ingredient text
+func (h *RecipeHandler) ImportRecipe(c *gin.Context) {
+	ctx := c.Request.Context()
+	var draft *dto.RecipeImportDraftDTO
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		file, formErr := c.FormFile("file")
+		if formErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no file"})
+			return
+		}
+		openedFile, openErr := file.Open()
+		if openErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open uploaded file"})
+			return
+		}
+		defer openedFile.Close()
+		// one byte over the limit tells a truncated page from one of exactly MaxPageSize
+		page, readErr := io.ReadAll(io.LimitReader(openedFile, recipeimport.MaxPageSize+1))
+		if readErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read uploaded file"})
+			return
+		}
+		if len(page) > recipeimport.MaxPageSize {
+			err = recipeimport.ErrPageTooLarge
+		} else {
+			draft, err = h.App.RecipeImportService.ImportFromHTML(ctx, page)
+		}
+	} else {
+		var req dto.RecipeImportRequestDTO
+		if bindErr := c.ShouldBindJSON(&req); bindErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + bindErr.Error()})
+			return
+		}
+		switch {
+		case req.URL != "":
+			draft, err = h.App.RecipeImportService.ImportFromURL(ctx, req.URL)
+		case req.Text != "":
+			draft, err = h.App.RecipeImportService.ImportFromText(ctx, req.Name, req.Text)
+		default:
+			err = services.ErrImportSourceRequired
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrImportSourceRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, recipeimport.ErrPageTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, recipeimport.ErrNoRecipe):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrImportSourceUnavailable):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import recipe"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// returns recipe resized to target weight, servings or calories
+func (h *RecipeHandler) ScaleRecipe(c *gin.Context) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	var req dto.ScaleRecipeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	response, err := h.App.RecipeService.ScaleRecipe(c.Request.Context(), recipeID, optionalUserID(c), canManageCatalog(c), &req)
+	if err != nil {
+		writeRecipeError(c, err, "Failed to scale recipe")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// returns recipe with one ingredient swapped for another and the nutrition difference
+func (h *RecipeHandler) SubstituteIngredient(c *gin.Context) {
+	recipeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipe ID format"})
+		return
+	}
+	var req dto.SubstituteIngredientRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+	response, err := h.App.RecipeService.SubstituteIngredient(c.Request.Context(), recipeID, optionalUserID(c), canManageCatalog(c), &req)
+	if err != nil {
+		writeRecipeError(c, err, "Failed to substitute ingredient")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+func writeRecipeError(c *gin.Context, err error, message string) {
+	var unknown *services.UnknownIngredientsError
+	switch {
+	case errors.As(err, &unknown):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "unknownIngredients": unknown.Names})
+	case errors.Is(err, services.ErrRecipeNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRecipeNotOwned):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRecipeVisibility),
+		errors.Is(err, services.ErrRecipeIncomplete),
+		errors.Is(err, services.ErrInvalidPortion),
+		errors.Is(err, services.ErrConflictingIngredientLines),
+		errors.Is(err, services.ErrInvalidCookedWeight),
+		errors.Is(err, services.ErrInvalidScaleTarget),
+		errors.Is(err, services.ErrIngredientNotInRecipe),
+		errors.Is(err, services.ErrInvalidSubstitution),
+		isQuantityError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": message + ": " + err.Error()})
+	case errors.Is(err, services.ErrRecipeNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound),
+		strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"foodgenie/internal/app"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorHandler struct {
+	App *app.App
+}
+
+func NewTwoFactorHandler(app *app.App) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		App: app,
+	}
+}
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	response, err := h.App.TwoFactorService.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrTwoFactorAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start two-factor enrollment"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+func (h *TwoFactorHandler) Activate(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.TwoFactorCodeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	response, err := h.App.TwoFactorService.Activate(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.TwoFactorDisableRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := h.App.TwoFactorService.Disable(c.Request.Context(), userID, currentSessionID(c), &req); err != nil {
+		if errors.Is(err, services.ErrInvalidPassword) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrReauthenticationRequired) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		writeTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// second step of login for users with 2FA enabled
+func (h *TwoFactorHandler) CompleteLogin(c *gin.Context) {
+	var req dto.TwoFactorLoginRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	user, err := h.App.TwoFactorService.CompleteLogin(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidChallengeToken) || errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrTooManyTwoFactorAttempts) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		writeTwoFactorError(c, err)
+		return
+	}
+	writeTokenPair(c, h.App, user)
+}
+func writeTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorNotEnrolled), errors.Is(err, services.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor operation failed"})
+	}
+}
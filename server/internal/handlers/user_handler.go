@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
 	"foodgenie/internal/app"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/services"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -32,22 +37,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "incorrect credentials"})
 		return
 	}
-	at, err := h.App.SecurityService.GenerateAccessToken(userModel)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed generating access token"})
-		return
-	}
-	rt, err := h.App.SecurityService.GenerateRefreshToken(userModel)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "failed generating refresh token"})
-		return
-	}
-	response := dto.LoginResponseDTO{
-		AccessToken:  at,
-		RefreshToken: rt,
-	}
-	c.JSON(http.StatusOK, response)
-
+	writeLoginResponse(c, h.App, userModel)
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
@@ -65,37 +55,84 @@ func (h *UserHandler) Register(c *gin.Context) {
 	c.JSON(http.StatusCreated, userDTO)
 }
 
+var errInvalidAuthorizationHeader = errors.New("Invalid Authorization header format")
+
 func (h *UserHandler) AuthCheck() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		if c.GetHeader("Authorization") == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Missing Authorization header"})
 			return
 		}
+		err := h.authenticate(c)
+		switch {
+		case err == nil:
+			c.Next()
+		case errors.Is(err, errInvalidAuthorizationHeader):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		case isInvalidCredentials(err):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check session"})
+		}
+	}
+}
 
-		// Oczekiwany format: "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Invalid Authorization header format"})
+// authenticates request only when Authorization header is present, used by public routes
+// which return more data to signed in users, invalid or expired tokens are served as anonymous
+func (h *UserHandler) OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
 			return
 		}
-
-		accessToken := parts[1]
-
-		claims, err := h.App.SecurityService.ValidateAccessToken(accessToken)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if err := h.authenticate(c); err != nil && !isInvalidCredentials(err) {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check session"})
 			return
 		}
+		c.Next()
+	}
+}
+
+// stores user, role and session of the bearer token in the context
+func (h *UserHandler) authenticate(c *gin.Context) error {
+	// Oczekiwany format: "Bearer <token>"
+	parts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return errInvalidAuthorizationHeader
+	}
+	claims, err := h.App.UserService.AuthenticateAccessToken(c.Request.Context(), parts[1])
+	if err != nil {
+		return err
+	}
+	// validated by AuthenticateAccessToken
+	userID, _ := uuid.Parse(claims.UserID)
+
+	// tokens issued before roles were introduced carry no role
+	role := claims.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	// uuid.Nil for tokens issued before sessions were introduced
+	sessionID, _ := uuid.Parse(claims.SessionID)
+	c.Set("userID", userID)
+	c.Set("role", role)
+	c.Set("sessionID", sessionID)
+	return nil
+}
+
+// the request carries credentials which can't be accepted, as opposed to failing to check them
+func isInvalidCredentials(err error) bool {
+	return errors.Is(err, errInvalidAuthorizationHeader) || errors.Is(err, services.ErrInvalidAccessToken) || errors.Is(err, services.ErrSessionRevoked)
+}
 
-		// Parse the UserID from the valid claims
-		userID, err := uuid.Parse(claims.UserID)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID in token"})
+// must be used after AuthCheck
+func (h *UserHandler) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if !models.HasPermission(role, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "insufficient permissions"})
 			return
 		}
-
-		c.Set("userID", userID)
 		c.Next()
 	}
 }
@@ -113,7 +150,7 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 		return
 	}
 
-	userDTO, err := h.App.UserService.GetUserById(userUUID)
+	userDTO, err := h.App.UserService.GetUserById(c.Request.Context(), userUUID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve user data"})
 		return
@@ -142,3 +179,162 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, response)
 }
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID format"})
+		return
+	}
+	var req dto.UpdateUserRoleRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	userDTO, err := h.App.UserService.UpdateUserRole(c.Request.Context(), userID, req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user role"})
+		return
+	}
+	c.JSON(http.StatusOK, userDTO)
+}
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.UpdateProfileRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	userDTO, err := h.App.UserService.UpdateProfile(c.Request.Context(), userID, &req)
+	if err != nil {
+		writeUserError(c, err, "failed to update profile")
+		return
+	}
+	c.JSON(http.StatusOK, userDTO)
+}
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.ChangePasswordRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := h.App.UserService.ChangePassword(c.Request.Context(), userID, currentSessionID(c), &req); err != nil {
+		writeUserError(c, err, "failed to change password")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password changed, other sessions were signed out"})
+}
+func (h *UserHandler) RequestEmailChange(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.ChangeEmailRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if err := h.App.UserService.RequestEmailChange(c.Request.Context(), userID, currentSessionID(c), &req); err != nil {
+		writeUserError(c, err, "failed to request email change")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "confirmation email sent to the new address"})
+}
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var req dto.ConfirmEmailChangeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	userDTO, err := h.App.UserService.ConfirmEmailChange(c.Request.Context(), &req)
+	if err != nil {
+		writeUserError(c, err, "failed to confirm email change")
+		return
+	}
+	c.JSON(http.StatusOK, userDTO)
+}
+func writeUserError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReauthenticationRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDateOfBirth),
+		errors.Is(err, services.ErrUnknownTag),
+		errors.Is(err, services.ErrEmailUnchanged),
+		errors.Is(err, services.ErrInvalidConfirmationToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var req dto.DeleteAccountRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	response, err := h.App.AccountService.ScheduleDeletion(c.Request.Context(), userID, currentSessionID(c), &req)
+	if err != nil {
+		if errors.Is(err, services.ErrDeletionAlreadyScheduled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		writeUserError(c, err, "failed to schedule account deletion")
+		return
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+func (h *UserHandler) CancelDeletion(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	if err := h.App.AccountService.CancelDeletion(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, services.ErrDeletionNotScheduled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		writeUserError(c, err, "failed to cancel account deletion")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "account deletion cancelled"})
+}
+
+// archive is built in memory so a failure doesn't leave client with truncated file
+func (h *UserHandler) ExportMe(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	if err := h.App.AccountService.ExportUserData(c.Request.Context(), userID, &buf); err != nil {
+		writeUserError(c, err, "failed to export user data")
+		return
+	}
+	filename := "foodgenie-export-" + time.Now().Format("2006-01-02") + ".zip"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// runs fn immediately and then every interval until ctx is cancelled
+func RunPeriodically(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := fn(ctx); err != nil {
+			slog.ErrorContext(ctx, "job failed", "job", name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// starts periodic jobs and waits for them on shutdown
+type Runner struct {
+	wg sync.WaitGroup
+}
+
+// runs RunPeriodically in background, the job stops when ctx is cancelled
+func (r *Runner) Go(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		RunPeriodically(ctx, name, interval, fn)
+	}()
+}
+
+// waits until all jobs returned, false when ctx is done first
+func (r *Runner) Wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
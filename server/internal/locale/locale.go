@@ -0,0 +1,83 @@
+package locale
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// language of ingredient names in the catalog
+const Default = "en"
+
+// more languages than this in one header are ignored
+const maxLanguages = 10
+
+type contextKey struct{}
+
+// lower case tag with hyphens, "pl_PL" becomes "pl-pl"
+func Normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// languages from Accept-Language header ordered by preference, every regional tag is followed by its base language,
+// "pl-PL,en;q=0.5" gives pl-pl, pl, en
+func Parse(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = Normalize(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || name != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				quality = parsed
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: tag, quality: quality})
+		if len(tags) == maxLanguages {
+			break
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+	var languages []string
+	add := func(tag string) {
+		for _, language := range languages {
+			if language == tag {
+				return
+			}
+		}
+		languages = append(languages, tag)
+	}
+	for _, tag := range tags {
+		add(tag.tag)
+		if base, _, regional := strings.Cut(tag.tag, "-"); regional {
+			add(base)
+		}
+	}
+	return languages
+}
+
+func WithLanguages(ctx context.Context, languages []string) context.Context {
+	return context.WithValue(ctx, contextKey{}, languages)
+}
+
+// languages of the caller, empty when the request didn't state any
+func Languages(ctx context.Context) []string {
+	languages, _ := ctx.Value(contextKey{}).([]string)
+	return languages
+}
@@ -0,0 +1,18 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+)
+
+// used when SMTP is not configured, the body is not logged because it carries confirmation and reset tokens
+type logMailer struct{}
+
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "mail not sent, SMTP is not configured", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"foodgenie/internal/config"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type smtpMailer struct {
+	cfg config.MailConfig
+}
+
+func NewSMTPMailer(cfg config.MailConfig) Mailer {
+	return &smtpMailer{
+		cfg: cfg,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	address := net.JoinHostPort(m.cfg.SMTPHost, m.cfg.SMTPPort)
+	var auth smtp.Auth
+	if m.cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, m.cfg.SMTPHost)
+	}
+	var body strings.Builder
+	body.WriteString("From: " + m.cfg.From + "\r\n")
+	body.WriteString("To: " + msg.To + "\r\n")
+	body.WriteString("Subject: " + msg.Subject + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+	if err := smtp.SendMail(address, auth, m.cfg.From, []string{msg.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import "slices"
+
+// allergens follow the EU list of 14, nuts are tree nuts, shellfish are crustaceans
+const (
+	AllergenGluten    = "gluten"
+	AllergenMilk      = "milk"
+	AllergenEgg       = "egg"
+	AllergenNuts      = "nuts"
+	AllergenPeanuts   = "peanuts"
+	AllergenSoy       = "soy"
+	AllergenFish      = "fish"
+	AllergenShellfish = "shellfish"
+	AllergenMolluscs  = "molluscs"
+	AllergenSesame    = "sesame"
+	AllergenCelery    = "celery"
+	AllergenMustard   = "mustard"
+	AllergenSulphites = "sulphites"
+	AllergenLupin     = "lupin"
+)
+
+// recipe has diet tag only when all its ingredients have it
+const (
+	DietVegan        = "vegan"
+	DietVegetarian   = "vegetarian"
+	DietKetoFriendly = "keto-friendly"
+)
+
+// in the order they are listed in responses
+var Allergens = []string{
+	AllergenGluten, AllergenMilk, AllergenEgg, AllergenNuts, AllergenPeanuts, AllergenSoy, AllergenFish,
+	AllergenShellfish, AllergenMolluscs, AllergenSesame, AllergenCelery, AllergenMustard, AllergenSulphites, AllergenLupin,
+}
+var DietTags = []string{DietVegan, DietVegetarian, DietKetoFriendly}
+
+func IsValidAllergen(allergen string) bool {
+	return slices.Contains(Allergens, allergen)
+}
+func IsValidDietTag(tag string) bool {
+	return slices.Contains(DietTags, tag)
+}
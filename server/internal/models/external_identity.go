@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// account at external OpenID Connect provider linked to a user
+type ExternalIdentity struct {
+	BaseModel
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	User     User      `gorm:"foreignKey:UserID"`
+	Provider string    `gorm:"not null;uniqueIndex:idx_external_identity_subject"`
+	Subject  string    `gorm:"not null;uniqueIndex:idx_external_identity_subject"`
+	Email    string
+}
+
+// pending authorization request, consumed by the callback
+type OIDCLoginState struct {
+	BaseModel
+	State        string    `gorm:"not null;uniqueIndex"`
+	Provider     string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
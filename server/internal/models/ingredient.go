@@ -2,6 +2,32 @@ package models
 
 type Ingredient struct {
 	BaseModel
-	Name            string  `gorm:"not null;uniqueIndex"`
+	// unique among ingredients which are not deleted, so a deleted name can be added again
+	Name            string  `gorm:"not null;uniqueIndex:idx_ingredients_name_active,where:deleted_at IS NULL"`
 	CaloriesPerGram float64 `gorm:"not null;default:0"`
+	// grams of nutrient per gram, 0 when unknown
+	ProteinPerGram       float64 `gorm:"not null;default:0"`
+	FatPerGram           float64 `gorm:"not null;default:0"`
+	CarbohydratesPerGram float64 `gorm:"not null;default:0"`
+	FiberPerGram         float64 `gorm:"not null;default:0"`
+	SugarPerGram         float64 `gorm:"not null;default:0"`
+	// milligrams per gram
+	SodiumPerGram float64 `gorm:"not null;default:0"`
+	// grams per millilitre, 0 when unknown and the ingredient can't be measured by volume
+	Density float64 `gorm:"not null;default:0"`
+	// grams of one piece, 0 when unknown and the ingredient can't be counted
+	PieceWeight float64 `gorm:"not null;default:0"`
+	// cooked weight per gram of raw ingredient, e.g. 2.5 for rice and 0.75 for meat
+	YieldFactor float64 `gorm:"not null;default:1"`
+	// values of Allergens and DietTags
+	Allergens []string `gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	DietTags  []string `gorm:"serializer:json;type:jsonb;not null;default:'[]'"`
+	// false until someone reviewed the allergens, empty Allergens of untagged ingredient mean unknown rather than none
+	AllergensTagged bool `gorm:"not null;default:false"`
+	// dataset the ingredient was imported from like usda-fdc, empty for ingredients added by hand
+	Source string `gorm:"size:50;not null;default:'';uniqueIndex:idx_ingredients_source,where:source_id <> '' AND deleted_at IS NULL"`
+	// record ID in the dataset, imports update the ingredient with the same source and ID
+	SourceID string `gorm:"size:100;not null;default:'';uniqueIndex:idx_ingredients_source,where:source_id <> '' AND deleted_at IS NULL"`
+	// synonyms and translations, the name itself is english
+	Aliases []IngredientAlias `gorm:"foreignKey:IngredientID"`
 }
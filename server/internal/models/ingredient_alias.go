@@ -0,0 +1,16 @@
+package models
+
+import "github.com/google/uuid"
+
+// synonym or translation of ingredient name, recipes can use any alias of the ingredient
+type IngredientAlias struct {
+	BaseModel
+	IngredientID uuid.UUID `gorm:"type:uuid;not null;index"`
+	// the same word may be used in several locales, it has to name the same ingredient in all of them,
+	// unique per locale ignoring case
+	Name string `gorm:"size:100;not null;uniqueIndex:idx_ingredient_aliases_locale_lower_name,expression:LOWER(name),where:deleted_at IS NULL"`
+	// lower case language tag like pl or en-gb
+	Locale string `gorm:"size:35;not null;uniqueIndex:idx_ingredient_aliases_locale_lower_name,where:deleted_at IS NULL"`
+	// shown instead of ingredient name to users of the locale, at most one per locale
+	Preferred bool `gorm:"not null;default:false"`
+}
@@ -9,5 +9,14 @@ type Meal struct {
 	UserID   uuid.UUID `gorm:"type:uuid;not null;index"`
 	RecipeID uuid.UUID `gorm:"type:uuid;not null;index"`
 	Recipe   Recipe    `gorm:"foreignKey:RecipeID"`
-	Weight   uint      `gorm:"not null"`
+	// normalized to grams
+	Weight uint `gorm:"not null"`
+	// quantity and unit as entered, kept for display
+	Quantity float64 `gorm:"not null;default:0"`
+	// unit of measure, "serving" or name of recipe portion
+	Unit string `gorm:"size:50;not null;default:'g'"`
+	// version of the recipe the meal was logged against, meals logged before versioning were pinned to the first version
+	// by a migration
+	RecipeVersionID *uuid.UUID     `gorm:"type:uuid;index"`
+	RecipeVersion   *RecipeVersion `gorm:"foreignKey:RecipeVersionID"`
 }
@@ -2,18 +2,91 @@ package models
 
 import "github.com/google/uuid"
 
+const (
+	// visible and loggable only by the owner
+	RecipeVisibilityPrivate = "private"
+	// visible by anyone who knows its ID, not listed in the catalog
+	RecipeVisibilityShared = "shared"
+	// listed in the catalog next to global recipes
+	RecipeVisibilityPublic = "public"
+)
+
+func IsValidRecipeVisibility(visibility string) bool {
+	switch visibility {
+	case RecipeVisibilityPrivate, RecipeVisibilityShared, RecipeVisibilityPublic:
+		return true
+	}
+	return false
+}
+
 type Recipe struct {
 	BaseModel
-	Name             string                  `gorm:"not null;uniqueIndex"`
+	// nil for recipes of the global catalog
+	OwnerID    *uuid.UUID `gorm:"type:uuid;index;uniqueIndex:idx_recipes_owner_name,priority:1,where:deleted_at IS NULL AND owner_id IS NOT NULL"`
+	Visibility string     `gorm:"size:20;not null;default:'public'"`
+	// unique among global recipes and among recipes of one owner, soft deleted recipes excluded
+	Name             string                  `gorm:"not null;uniqueIndex:idx_recipes_name_global,where:deleted_at IS NULL AND owner_id IS NULL;uniqueIndex:idx_recipes_owner_name,priority:2"`
 	IngredientUsages []RecipeIngredientUsage `gorm:"foreignKey:RecipeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Weight           uint                    `gorm:"not null;default:0"`
-	Calories         uint                    `gorm:"not null;default:0"`
-	Volume           float64                 `gorm:"not null;default:0"`
+	// weight of the finished dish, portions and meals are scaled by it
+	Weight uint `gorm:"not null;default:0"`
+	// sum of raw ingredient weights, 0 for recipes created before cooking yield was introduced
+	RawWeight uint `gorm:"not null;default:0"`
+	// measured weight after cooking, 0 when Weight is estimated from ingredient yield factors
+	CookedWeight uint    `gorm:"not null;default:0"`
+	Calories     uint    `gorm:"not null;default:0"`
+	Volume       float64 `gorm:"not null;default:0"`
+	// number of servings the whole recipe makes
+	Servings uint            `gorm:"not null;default:1"`
+	Portions []RecipePortion `gorm:"foreignKey:RecipeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// latest RecipeVersion, new meals are pinned to it
+	CurrentVersionID *uuid.UUID `gorm:"type:uuid"`
+	Version          uint       `gorm:"not null;default:0"`
 }
 type RecipeIngredientUsage struct {
 	BaseModel
-	RecipeID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_ingredient_usage"`
-	IngredientID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_ingredient_usage"`
-	Weight       uint       `gorm:"not null"`
-	Ingredient   Ingredient `gorm:"foreignKey:IngredientID"`
+	RecipeID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_ingredient_usage"`
+	IngredientID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_ingredient_usage"`
+	// normalized to grams
+	Weight uint `gorm:"not null"`
+	// quantity and unit as entered, kept for display
+	Quantity float64 `gorm:"not null;default:0"`
+	Unit     string  `gorm:"size:10;not null;default:'g'"`
+	// yield factor used for this recipe, ingredient default unless overridden
+	YieldFactor float64    `gorm:"not null;default:1"`
+	Ingredient  Ingredient `gorm:"foreignKey:IngredientID"`
+}
+
+// named portion size like "slice" or "bowl"
+type RecipePortion struct {
+	BaseModel
+	RecipeID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_portion_name"`
+	Name     string    `gorm:"size:50;not null;uniqueIndex:idx_recipe_portion_name"`
+	Weight   uint      `gorm:"not null"`
+}
+
+// immutable snapshot of recipe, created on every change so logged meals keep their nutrition
+type RecipeVersion struct {
+	BaseModel
+	RecipeID    uuid.UUID                 `gorm:"type:uuid;not null;uniqueIndex:idx_recipe_version_number"`
+	Number      uint                      `gorm:"not null;uniqueIndex:idx_recipe_version_number"`
+	Name        string                    `gorm:"not null"`
+	Weight      uint                      `gorm:"not null"`
+	RawWeight   uint                      `gorm:"not null;default:0"`
+	Calories    uint                      `gorm:"not null"`
+	Volume      float64                   `gorm:"not null;default:0"`
+	Ingredients []RecipeVersionIngredient `gorm:"foreignKey:RecipeVersionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ingredient name and calories are copied so later catalog changes don't affect the snapshot
+type RecipeVersionIngredient struct {
+	BaseModel
+	RecipeVersionID uuid.UUID `gorm:"type:uuid;not null;index"`
+	IngredientID    uuid.UUID `gorm:"type:uuid;not null"`
+	Name            string    `gorm:"not null"`
+	Weight          uint      `gorm:"not null"`
+	Quantity        float64   `gorm:"not null;default:0"`
+	Unit            string    `gorm:"size:10;not null;default:'g'"`
+	CaloriesPerGram float64   `gorm:"not null"`
+	// current aliases of the ingredient, the snapshot name is shown in languages without one
+	Aliases []IngredientAlias `gorm:"foreignKey:IngredientID;references:IngredientID"`
 }
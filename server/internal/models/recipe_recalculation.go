@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RecalculationPending   = "pending"
+	RecalculationRunning   = "running"
+	RecalculationCompleted = "completed"
+	RecalculationFailed    = "failed"
+)
+
+// recomputation of cached totals of recipes using an ingredient whose nutrition data changed
+type RecipeRecalculation struct {
+	BaseModel
+	IngredientID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Status       string    `gorm:"size:20;not null;default:'pending';index"`
+	// yield factor of the ingredient before the change, usages with the same factor follow the new one
+	PreviousYieldFactor float64 `gorm:"not null;default:1"`
+	Error               string
+	// set when a worker claims the recalculation
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	Changes    []RecipeRecalculationChange `gorm:"foreignKey:RecalculationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// sets status and error summary from the changes, the recalculation fails only when no recipe could be recalculated
+func (r *RecipeRecalculation) Summarize() {
+	failed := 0
+	for _, change := range r.Changes {
+		if change.Error != "" {
+			failed++
+		}
+	}
+	r.Status = RecalculationCompleted
+	r.Error = ""
+	if failed > 0 {
+		r.Error = fmt.Sprintf("%d of %d recipes could not be recalculated", failed, len(r.Changes))
+		if failed == len(r.Changes) {
+			r.Status = RecalculationFailed
+		}
+	}
+}
+
+// totals of one recipe before and after recalculation
+type RecipeRecalculationChange struct {
+	BaseModel
+	RecalculationID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	RecipeID         uuid.UUID `gorm:"type:uuid;not null;index"`
+	RecipeName       string    `gorm:"not null"`
+	PreviousCalories uint      `gorm:"not null"`
+	Calories         uint      `gorm:"not null"`
+	PreviousWeight   uint      `gorm:"not null"`
+	Weight           uint      `gorm:"not null"`
+	// version created by the recalculation, zero when the recipe failed
+	Version uint `gorm:"not null"`
+	// why the recipe could not be recalculated, it keeps its previous totals
+	Error string
+}
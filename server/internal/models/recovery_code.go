@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// single use 2FA recovery code, only sha256 hash of the code is stored
+type RecoveryCode struct {
+	BaseModel
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash string    `gorm:"not null;uniqueIndex"`
+	UsedAt   *time.Time
+}
+
+// password step of a login waiting for the second factor, limits code attempts per challenge token
+type TwoFactorChallenge struct {
+	BaseModel
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Attempts  uint      `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,33 @@
+package models
+
+const (
+	RoleUser   = "user"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+const (
+	PermissionCatalogWrite = "catalog:write"
+	PermissionUsersManage  = "users:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleUser:   {},
+	RoleEditor: {PermissionCatalogWrite},
+	RoleAdmin:  {PermissionCatalogWrite, PermissionUsersManage},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// checks if role grants permission, unknown roles have no permissions
+func HasPermission(role string, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
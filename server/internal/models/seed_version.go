@@ -0,0 +1,14 @@
+package models
+
+// applied version of a seed dataset, the dataset is seeded again only when its file changes
+type SeedVersion struct {
+	BaseModel
+	Dataset string `gorm:"size:50;not null;uniqueIndex:idx_seed_versions_dataset_version"`
+	// increases with every applied change of the dataset
+	Version uint `gorm:"not null;uniqueIndex:idx_seed_versions_dataset_version"`
+	// sha256 of the seed file
+	Checksum  string `gorm:"size:64;not null"`
+	Created   int    `gorm:"not null"`
+	Updated   int    `gorm:"not null"`
+	Unchanged int    `gorm:"not null"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// login session, its ID is carried in tokens as sid and checked on refresh
+type Session struct {
+	BaseModel
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time `gorm:"index"`
+}
+
+// pending change of email, only sha256 hash of the token is stored
+type EmailChangeRequest struct {
+	BaseModel
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	NewEmail  string    `gorm:"not null"`
+	TokenHash string    `gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
@@ -6,10 +6,29 @@ import (
 
 type User struct {
 	BaseModel
-	Username    string    `gorm:"size:20;not null;uniqueIndex" json:"username"`
-	Email       string    `gorm:"uniqueIndex;not null" json:"email"`
-	Password    string    `gorm:"not null" json:"password"`
-	FirstName   string    `gorm:"not null" json:"first_name"`
-	LastName    string    `gorm:"not null" json:"last_name"`
-	DateOfBirth time.Time `gorm:"not null" json:"date_of_birth"`
+	Username string `gorm:"size:20;not null;uniqueIndex" json:"username"`
+	Email    string `gorm:"uniqueIndex;not null" json:"email"`
+	Password string `gorm:"not null" json:"password"`
+	// false for accounts created by signing in with a provider, their password is random until the user sets one
+	HasPassword bool            `gorm:"not null" json:"-"`
+	FirstName   string          `gorm:"not null" json:"first_name"`
+	LastName    string          `gorm:"not null" json:"last_name"`
+	DateOfBirth time.Time       `gorm:"not null" json:"date_of_birth"`
+	Role        string          `gorm:"size:20;not null;default:'user'" json:"role"`
+	Preferences UserPreferences `gorm:"embedded;embeddedPrefix:pref_" json:"preferences"`
+	// set when user requested account deletion, account is purged once it passes
+	DeletionScheduledAt *time.Time `gorm:"index" json:"deletion_scheduled_at"`
+	// TOTP secret encrypted with SecurityService.EncryptSecret, set on enrollment
+	TwoFactorSecret  string `gorm:"not null;default:''" json:"-"`
+	TwoFactorEnabled bool   `gorm:"not null;default:false" json:"two_factor_enabled"`
+	// last accepted TOTP time step, codes of this or earlier steps are rejected as replays
+	TwoFactorLastStep int64 `gorm:"not null;default:0" json:"-"`
+}
+
+type UserPreferences struct {
+	Language         string `gorm:"size:10;not null;default:'en'" json:"language"`
+	UnitSystem       string `gorm:"size:10;not null;default:'metric'" json:"unit_system"`
+	DailyCalorieGoal uint   `gorm:"not null;default:0" json:"daily_calorie_goal"`
+	// values of Allergens, meals logged from images warn about them
+	Allergies []string `gorm:"serializer:json;type:jsonb;not null;default:'[]'" json:"allergies"`
 }
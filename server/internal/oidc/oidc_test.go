@@ -0,0 +1,122 @@
+package oidc_test
+
+import (
+	"context"
+	"foodgenie/internal/oidc"
+	"foodgenie/internal/oidc/oidctest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newProvider(issuer *oidctest.Issuer) *oidc.Provider {
+	return oidc.NewProvider(oidc.ProviderConfig{
+		IssuerURL:   issuer.URL,
+		ClientID:    issuer.ClientID,
+		RedirectURL: "https://app.example.com/callback",
+	}, issuer.Client())
+}
+
+// returns code the issuer granted for the nonce and verifier
+func authorize(t *testing.T, issuer *oidctest.Issuer, provider *oidc.Provider, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, issuer.URL+"/authorize?") {
+		t.Fatalf("authorization url %s does not use the discovered endpoint", authURL)
+	}
+	return issuer.Authorize(t, authURL)
+}
+
+func TestExchange(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "foodgenie")
+	provider := newProvider(issuer)
+	code := authorize(t, issuer, provider, "nonce-1", "verifier-1")
+
+	claims, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := oidc.Claims{Subject: "subject-1", Email: "anna@example.com", EmailVerified: true, GivenName: "Anna", FamilyName: "Nowak"}
+	if *claims != want {
+		t.Fatalf("claims = %+v, want %+v", *claims, want)
+	}
+}
+
+func TestExchangeAppleEmailVerifiedString(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "foodgenie")
+	issuer.Claims["email_verified"] = "false"
+	provider := newProvider(issuer)
+	code := authorize(t, issuer, provider, "nonce-1", "verifier-1")
+
+	claims, err := provider.Exchange(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.EmailVerified {
+		t.Fatal("email_verified \"false\" should not be treated as verified")
+	}
+}
+
+func TestExchangeRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		nonce    string
+		verifier string
+		err      string
+	}{
+		{name: "nonce mismatch", nonce: "other-nonce", err: "nonce mismatch"},
+		{name: "wrong code verifier", verifier: "other-verifier", err: "status 400"},
+		{name: "expired", claims: map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}, err: "expired"},
+		{name: "missing expiry", claims: map[string]any{"exp": nil}, err: "exp claim is required"},
+		{name: "wrong audience", claims: map[string]any{"aud": "other-client"}, err: "aud"},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://evil.example.com"}, err: "iss"},
+		{name: "missing subject", claims: map[string]any{"sub": nil}, err: "missing subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := oidctest.NewIssuer(t, "foodgenie")
+			for key, value := range tt.claims {
+				issuer.Claims[key] = value
+			}
+			provider := newProvider(issuer)
+			code := authorize(t, issuer, provider, "nonce-1", "verifier-1")
+			nonce, verifier := "nonce-1", "verifier-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			_, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Exchange error = %v, want containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsUnsignedToken(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "foodgenie")
+	provider := newProvider(issuer)
+	signed := issuer.SignIDToken(t, map[string]any{
+		"iss":   issuer.URL,
+		"aud":   "foodgenie",
+		"sub":   "subject-1",
+		"nonce": "nonce-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := provider.VerifyIDToken(context.Background(), signed, "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken of signed token: %v", err)
+	}
+	payload := strings.Split(signed, ".")[1]
+	// header with alg none, signature stripped
+	unsigned := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0." + payload + "."
+	if _, err := provider.VerifyIDToken(context.Background(), unsigned, "nonce-1"); err == nil {
+		t.Fatal("token with alg none should be rejected")
+	}
+}
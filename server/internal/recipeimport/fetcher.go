@@ -0,0 +1,83 @@
+package recipeimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// pages bigger than this are not recipes worth importing
+const MaxPageSize = 5 << 20
+
+var ErrPageTooLarge = errors.New("page is too large")
+
+// downloads page of given URL, replaced by a stub in tests
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) ([]byte, error)
+}
+
+type httpFetcher struct {
+	client *http.Client
+}
+
+// client nil uses default client which refuses to connect to loopback and private addresses,
+// so the importer can't be used to reach internal services
+func NewHTTPFetcher(client *http.Client) Fetcher {
+	if client == nil {
+		dialer := &net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: rejectPrivateAddress,
+		}
+		client = &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil},
+		}
+	}
+	return &httpFetcher{client: client}
+}
+
+func (f *httpFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxPageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	if len(body) > MaxPageSize {
+		return nil, ErrPageTooLarge
+	}
+	return body, nil
+}
+
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("connections to %s are not allowed", host)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package recipeimport
+
+import (
+	"foodgenie/internal/units"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type unitAlias struct {
+	alias string
+	unit  units.Unit
+	// quantity is multiplied by it, for measures expressed in supported units, e.g. "dl"
+	multiplier float64
+}
+
+// measures common in recipes which the units package doesn't define
+var extraUnitAliases = []unitAlias{
+	{"fl oz", units.Millilitre, 29.5735295625},
+	{"fl. oz", units.Millilitre, 29.5735295625},
+	{"mg", units.Gram, 0.001},
+	{"cl", units.Millilitre, 10},
+	{"dl", units.Millilitre, 100},
+	{"cloves", units.Piece, 1},
+	{"clove", units.Piece, 1},
+	{"slices", units.Piece, 1},
+	{"slice", units.Piece, 1},
+}
+
+// longer aliases first so "fl oz" wins over "oz"
+var unitAliases = func() []unitAlias {
+	all := append([]unitAlias{}, extraUnitAliases...)
+	for name, unit := range units.Names() {
+		all = append(all, unitAlias{name, unit, 1})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if len(all[i].alias) != len(all[j].alias) {
+			return len(all[i].alias) > len(all[j].alias)
+		}
+		return all[i].alias < all[j].alias
+	})
+	return all
+}()
+
+var vulgarFractions = map[rune]float64{
+	'½': 0.5, '⅓': 1.0 / 3, '⅔': 2.0 / 3, '¼': 0.25, '¾': 0.75,
+	'⅕': 0.2, '⅖': 0.4, '⅗': 0.6, '⅘': 0.8, '⅙': 1.0 / 6, '⅚': 5.0 / 6, '⅛': 0.125, '⅜': 0.375, '⅝': 0.625, '⅞': 0.875,
+}
+
+var (
+	quantityPattern = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)(?:\s+(\d+)/(\d+)|/(\d+))?`)
+	rangePattern    = regexp.MustCompile(`^\s*(?:-|–|to)\s*`)
+	parenthesised   = regexp.MustCompile(`\([^)]*\)`)
+	trailingNotes   = regexp.MustCompile(`(?i)\s*(?:,.*|\bto taste\b.*|\bfor serving\b.*|\boptional\b.*)$`)
+)
+
+// ingredient line like "1 1/2 cups flour, sifted"
+type Line struct {
+	Raw string
+	// 0 when line has no quantity, e.g. "salt to taste"
+	Quantity float64
+	// empty when line has no quantity, countable items without unit like "2 eggs" are in pieces
+	Unit units.Unit
+	Name string
+}
+
+// splits free text into ingredient lines, empty lines and list bullets are skipped
+func ParseText(text string) []Line {
+	var lines []Line
+	for _, raw := range strings.Split(text, "\n") {
+		raw = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(raw), "-*•·"))
+		if raw == "" {
+			continue
+		}
+		lines = append(lines, ParseLine(raw))
+	}
+	return lines
+}
+
+func ParseLine(raw string) Line {
+	line := Line{Raw: raw}
+	rest := strings.TrimSpace(raw)
+	line.Quantity, rest = parseQuantity(rest)
+	if line.Quantity > 0 {
+		// ranges like "2-3 cups" use the average
+		if loc := rangePattern.FindStringIndex(rest); loc != nil {
+			if upper, remaining := parseQuantity(rest[loc[1]:]); upper > 0 {
+				line.Quantity = (line.Quantity + upper) / 2
+				rest = remaining
+			}
+		}
+	}
+	rest = strings.TrimSpace(rest)
+	if alias, remaining, ok := parseUnit(rest); ok && line.Quantity > 0 {
+		line.Unit = alias.unit
+		line.Quantity *= alias.multiplier
+		rest = remaining
+	} else if line.Quantity > 0 {
+		line.Unit = units.Piece
+	}
+	line.Name = cleanIngredientName(rest)
+	return line
+}
+
+// reads leading number, fraction, mixed number or unicode fraction
+func parseQuantity(text string) (float64, string) {
+	text = strings.TrimSpace(text)
+	var quantity float64
+	if match := quantityPattern.FindStringSubmatch(text); match != nil {
+		whole, _ := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", "."), 64)
+		quantity = whole
+		switch {
+		case match[2] != "":
+			quantity += fraction(match[2], match[3])
+		case match[4] != "":
+			quantity = fraction(match[1], match[4])
+		}
+		text = text[len(match[0]):]
+	}
+	if r, size := firstRune(text); size > 0 {
+		if value, ok := vulgarFractions[r]; ok {
+			quantity += value
+			text = text[size:]
+		}
+	}
+	return quantity, text
+}
+
+func fraction(numerator, denominator string) float64 {
+	n, _ := strconv.ParseFloat(numerator, 64)
+	d, _ := strconv.ParseFloat(denominator, 64)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func firstRune(text string) (rune, int) {
+	for _, r := range text {
+		return r, len(string(r))
+	}
+	return 0, 0
+}
+
+func parseUnit(text string) (unitAlias, string, bool) {
+	lower := strings.ToLower(text)
+	for _, candidate := range unitAliases {
+		if !strings.HasPrefix(lower, candidate.alias) {
+			continue
+		}
+		rest := text[len(candidate.alias):]
+		// unit must be a whole word, "g" must not match "garlic"
+		if next, size := firstRune(rest); size > 0 && next != ' ' && next != '.' && next != ',' {
+			continue
+		}
+		rest = strings.TrimPrefix(rest, ".")
+		rest = strings.TrimSpace(rest)
+		rest = strings.TrimPrefix(rest, "of ")
+		return candidate, rest, true
+	}
+	return unitAlias{}, text, false
+}
+
+func cleanIngredientName(text string) string {
+	text = parenthesised.ReplaceAllString(text, " ")
+	text = trailingNotes.ReplaceAllString(text, "")
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
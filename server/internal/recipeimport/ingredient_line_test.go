@@ -0,0 +1,54 @@
+package recipeimport
+
+import (
+	"foodgenie/internal/units"
+	"math"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		raw      string
+		quantity float64
+		unit     units.Unit
+		name     string
+	}{
+		{"200 g flour", 200, units.Gram, "flour"},
+		{"200g flour", 200, units.Gram, "flour"},
+		{"1 1/2 cups flour, sifted", 1.5, units.Cup, "flour"},
+		{"1/2 tsp salt", 0.5, units.Teaspoon, "salt"},
+		{"½ cup sugar", 0.5, units.Cup, "sugar"},
+		{"1½ cups milk", 1.5, units.Cup, "milk"},
+		{"2-3 tbsp olive oil", 2.5, units.Tablespoon, "olive oil"},
+		{"2 to 4 cloves garlic", 3, units.Piece, "garlic"},
+		{"1,5 kg potatoes", 1.5, units.Kilogram, "potatoes"},
+		{"2 dl cream", 200, units.Millilitre, "cream"},
+		{"4 fl oz water", 118.29411825, units.Millilitre, "water"},
+		{"2 large eggs (room temperature)", 2, units.Piece, "large eggs"},
+		{"3 garlic cloves", 3, units.Piece, "garlic cloves"},
+		{"1 cup of rice", 1, units.Cup, "rice"},
+		{"salt to taste", 0, "", "salt"},
+		{"Fresh basil, for serving", 0, "", "fresh basil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			line := ParseLine(tt.raw)
+			if math.Abs(line.Quantity-tt.quantity) > 1e-9 || line.Unit != tt.unit || line.Name != tt.name {
+				t.Fatalf("ParseLine(%q) = %v %q %q, want %v %q %q", tt.raw, line.Quantity, line.Unit, line.Name, tt.quantity, tt.unit, tt.name)
+			}
+			if line.Raw != tt.raw {
+				t.Fatalf("Raw = %q, want %q", line.Raw, tt.raw)
+			}
+		})
+	}
+}
+
+func TestParseText(t *testing.T) {
+	lines := ParseText("- 200 g flour\n\n* 2 eggs\n • pinch of salt  \n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %+v", len(lines), lines)
+	}
+	if lines[0].Name != "flour" || lines[1].Name != "eggs" || lines[2].Name != "pinch of salt" {
+		t.Fatalf("unexpected names %+v", lines)
+	}
+}
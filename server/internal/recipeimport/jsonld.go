@@ -0,0 +1,123 @@
+package recipeimport
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrNoRecipe = errors.New("page does not contain schema.org Recipe markup")
+
+var jsonLDScript = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+
+// values of schema.org Recipe used by the importer
+type Recipe struct {
+	Name        string
+	Ingredients []string
+	Yield       string
+}
+
+// finds first schema.org Recipe in JSON-LD blocks of HTML page
+func ParseHTML(page []byte) (*Recipe, error) {
+	for _, match := range jsonLDScript.FindAllSubmatch(page, -1) {
+		var document any
+		if err := json.Unmarshal(match[1], &document); err != nil {
+			// one broken block shouldn't hide recipe in another one
+			continue
+		}
+		if recipe := findRecipe(document); recipe != nil {
+			return recipe, nil
+		}
+	}
+	return nil, ErrNoRecipe
+}
+
+// walks arrays, @graph and nested nodes until node of type Recipe is found
+func findRecipe(node any) *Recipe {
+	switch value := node.(type) {
+	case []any:
+		for _, item := range value {
+			if recipe := findRecipe(item); recipe != nil {
+				return recipe
+			}
+		}
+	case map[string]any:
+		if isRecipeType(value["@type"]) {
+			return &Recipe{
+				Name:        cleanText(stringValue(value["name"])),
+				Ingredients: ingredientLines(value),
+				Yield:       cleanText(stringValue(value["recipeYield"])),
+			}
+		}
+		for _, key := range []string{"@graph", "mainEntity", "mainEntityOfPage"} {
+			if recipe := findRecipe(value[key]); recipe != nil {
+				return recipe
+			}
+		}
+	}
+	return nil
+}
+
+func isRecipeType(value any) bool {
+	switch t := value.(type) {
+	case string:
+		return t == "Recipe" || strings.HasSuffix(t, "/Recipe")
+	case []any:
+		for _, item := range t {
+			if isRecipeType(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recipeIngredient replaced deprecated ingredients property, both are still used in the wild
+func ingredientLines(recipe map[string]any) []string {
+	raw, ok := recipe["recipeIngredient"]
+	if !ok {
+		raw = recipe["ingredients"]
+	}
+	var lines []string
+	switch value := raw.(type) {
+	case string:
+		lines = strings.Split(value, "\n")
+	case []any:
+		for _, item := range value {
+			lines = append(lines, stringValue(item))
+		}
+	}
+	cleaned := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = cleanText(line); line != "" {
+			cleaned = append(cleaned, line)
+		}
+	}
+	return cleaned
+}
+
+// yield and name are sometimes arrays or numbers
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		if len(v) > 0 {
+			return stringValue(v[0])
+		}
+	}
+	return ""
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// removes markup and entities some sites leave in JSON-LD strings
+func cleanText(text string) string {
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
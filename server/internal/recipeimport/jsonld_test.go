@@ -0,0 +1,65 @@
+package recipeimport
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want Recipe
+	}{
+		{
+			name: "plain recipe",
+			page: `<html><head><script type="application/ld+json">
+{"@context":"https://schema.org","@type":"Recipe","name":"Pancakes","recipeYield":"4 servings",
+ "recipeIngredient":["200 g flour","2 eggs","300 ml milk"]}
+</script></head></html>`,
+			want: Recipe{Name: "Pancakes", Yield: "4 servings", Ingredients: []string{"200 g flour", "2 eggs", "300 ml milk"}},
+		},
+		{
+			name: "recipe in graph with type array and yield array",
+			page: `<script type='application/ld+json'>{"@context":"https://schema.org","@graph":[
+{"@type":"WebSite","name":"Cooking"},
+{"@type":["Recipe","NewsArticle"],"name":"Soup &amp; bread","recipeYield":[6,"6 bowls"],"recipeIngredient":["1 onion"]}]}</script>`,
+			want: Recipe{Name: "Soup & bread", Yield: "6", Ingredients: []string{"1 onion"}},
+		},
+		{
+			name: "broken block before valid one",
+			page: `<script type="application/ld+json">{"@type": "Recipe",</script>
+<script type="application/ld+json">[{"@type":"Organization"},{"@type":"http://schema.org/Recipe","name":"Salad","recipeIngredient":["<b>1</b> cucumber","  ","2 tomatoes"]}]</script>`,
+			want: Recipe{Name: "Salad", Ingredients: []string{"1 cucumber", "2 tomatoes"}},
+		},
+		{
+			name: "deprecated ingredients property as text",
+			page: `<SCRIPT TYPE="application/ld+json" id="recipe">{"mainEntity":{"@type":"Recipe","name":"Toast","ingredients":"1 slice bread\n1 tsp butter"}}</SCRIPT>`,
+			want: Recipe{Name: "Toast", Ingredients: []string{"1 slice bread", "1 tsp butter"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe, err := ParseHTML([]byte(tt.page))
+			if err != nil {
+				t.Fatalf("ParseHTML: %v", err)
+			}
+			if !reflect.DeepEqual(*recipe, tt.want) {
+				t.Fatalf("recipe = %+v, want %+v", *recipe, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHTMLWithoutRecipe(t *testing.T) {
+	for name, page := range map[string]string{
+		"no json-ld":       `<html><body><h1>Pancakes</h1></body></html>`,
+		"other types only": `<script type="application/ld+json">{"@type":"Article","name":"News"}</script>`,
+		"invalid json":     `<script type="application/ld+json">{"@type":"Recipe"</script>`,
+	} {
+		if _, err := ParseHTML([]byte(page)); !errors.Is(err, ErrNoRecipe) {
+			t.Errorf("%s: err = %v, want ErrNoRecipe", name, err)
+		}
+	}
+}
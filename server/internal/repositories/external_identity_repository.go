@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository interface {
+	CreateExternalIdentity(ctx context.Context, identity *models.ExternalIdentity) (*models.ExternalIdentity, error)
+	GetExternalIdentity(ctx context.Context, provider string, subject string) (*models.ExternalIdentity, error)
+	GetExternalIdentitiesForUser(ctx context.Context, userID uuid.UUID) ([]*models.ExternalIdentity, error)
+	CreateLoginState(ctx context.Context, state *models.OIDCLoginState) error
+	ConsumeLoginState(ctx context.Context, provider string, state string) (*models.OIDCLoginState, error)
+}
+type externalIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalIdentityRepository(db *gorm.DB) ExternalIdentityRepository {
+	return &externalIdentityRepository{
+		db: db,
+	}
+}
+func (r *externalIdentityRepository) CreateExternalIdentity(ctx context.Context, identity *models.ExternalIdentity) (*models.ExternalIdentity, error) {
+	if err := r.db.WithContext(ctx).Create(identity).Error; err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// gets identity with its user
+func (r *externalIdentityRepository) GetExternalIdentity(ctx context.Context, provider string, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	err := r.db.WithContext(ctx).Preload("User").
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("external identity not found %w", err)
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+func (r *externalIdentityRepository) GetExternalIdentitiesForUser(ctx context.Context, userID uuid.UUID) ([]*models.ExternalIdentity, error) {
+	var identities []*models.ExternalIdentity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+func (r *externalIdentityRepository) CreateLoginState(ctx context.Context, state *models.OIDCLoginState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+// deletes state so it can't be used twice, expired states are cleaned up on the way
+func (r *externalIdentityRepository) ConsumeLoginState(ctx context.Context, provider string, state string) (*models.OIDCLoginState, error) {
+	var loginState models.OIDCLoginState
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().
+			Where("state = ? AND provider = ?", state, provider).
+			First(&loginState)
+		if result.Error != nil {
+			return result.Error
+		}
+		// concurrent callback with the same state might have deleted it already
+		deleted := tx.Unscoped().Delete(&loginState)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("login state not found or expired")
+		}
+		return nil, err
+	}
+	return &loginState, nil
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// escapes LIKE wildcards in user input
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// preload scope including soft deleted rows, meals must stay displayable after their recipe is deleted
+func unscoped(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// preload scope listing portions from the smallest
+func orderByWeight(db *gorm.DB) *gorm.DB {
+	return db.Order("weight ASC")
+}
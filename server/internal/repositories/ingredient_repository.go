@@ -3,19 +3,45 @@ package repositories
 import (
 	"context"
 	"errors"
+	"fmt"
 	"foodgenie/internal/models"
+	"strings"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ingredientRepository struct {
 	db *gorm.DB
 }
 
+type IngredientFilter struct {
+	// matches names by prefix, word prefix, substring and letters in order, best matches first
+	Query    string
+	Page     int
+	PageSize int
+}
+
+// ingredient changed by an import, recalculation is nil when recipe totals stay
+type IngredientUpdate struct {
+	Ingredient    *models.Ingredient
+	Recalculation *models.RecipeRecalculation
+}
+
 type IngredientRepository interface {
 	CreateIngredient(ingredient *models.Ingredient) (*models.Ingredient, error)
 	GetIngredientByName(ctx context.Context, name string) (*models.Ingredient, error)
 	GetIngredientsByNames(ctx context.Context, names []string) ([]*models.Ingredient, error)
+	GetIngredientsByNamesIgnoreCase(ctx context.Context, names []string) ([]*models.Ingredient, error)
+	GetIngredientByID(ctx context.Context, id uuid.UUID) (*models.Ingredient, error)
+	UpdateIngredient(ctx context.Context, ingredient *models.Ingredient, recalculation *models.RecipeRecalculation) error
+	GetIngredientsForImport(ctx context.Context) ([]*models.Ingredient, error)
+	SaveImportedIngredients(ctx context.Context, created []*models.Ingredient, updates []IngredientUpdate) error
+	ListIngredients(ctx context.Context, filter IngredientFilter) ([]*models.Ingredient, int64, error)
+	DeleteIngredient(ctx context.Context, id uuid.UUID) (int64, error)
+	AddIngredientAlias(ctx context.Context, alias *models.IngredientAlias) error
+	DeleteIngredientAlias(ctx context.Context, ingredientID uuid.UUID, aliasID uuid.UUID) error
 }
 
 func NewIngredientRepository(db *gorm.DB) IngredientRepository {
@@ -32,20 +58,214 @@ func (r *ingredientRepository) CreateIngredient(ingredient *models.Ingredient) (
 }
 func (r *ingredientRepository) GetIngredientByName(ctx context.Context, name string) (*models.Ingredient, error) {
 	var ing *models.Ingredient
-	tx := r.db.WithContext(ctx).Model(&models.Ingredient{}).Where("name = ?", name).First(&ing)
+	tx := r.db.WithContext(ctx).Model(&models.Ingredient{}).Preload("Aliases").Where("name = ?", name).First(&ing)
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 	return ing, nil
 }
+
+// matches ingredient names exactly and aliases in any locale ignoring case, aliases are preloaded
 func (r *ingredientRepository) GetIngredientsByNames(ctx context.Context, names []string) ([]*models.Ingredient, error) {
 	var ingredients []*models.Ingredient
 	if len(names) == 0 {
 		return nil, errors.New("empty ingredient names list")
 	}
-	tx := r.db.WithContext(ctx).Model(&models.Ingredient{}).Where("name IN ?", names).Find(&ingredients)
+	tx := r.db.WithContext(ctx).Model(&models.Ingredient{}).Preload("Aliases").
+		Where("name IN ?", names).
+		Or("id IN (?)", aliasedIngredientIDs(r.db, lowerNames(names))).
+		Find(&ingredients)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return ingredients, nil
+}
+
+// names are expected in lower case, aliases in any locale match too and are preloaded
+func (r *ingredientRepository) GetIngredientsByNamesIgnoreCase(ctx context.Context, names []string) ([]*models.Ingredient, error) {
+	var ingredients []*models.Ingredient
+	if len(names) == 0 {
+		return nil, errors.New("empty ingredient names list")
+	}
+	tx := r.db.WithContext(ctx).Model(&models.Ingredient{}).Preload("Aliases").
+		Where("LOWER(name) IN ?", names).
+		Or("id IN (?)", aliasedIngredientIDs(r.db, names)).
+		Find(&ingredients)
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 	return ingredients, nil
 }
+func (r *ingredientRepository) GetIngredientByID(ctx context.Context, id uuid.UUID) (*models.Ingredient, error) {
+	var ing models.Ingredient
+	err := r.db.WithContext(ctx).Preload("Aliases").Where("id = ?", id).First(&ing).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("ingredient not found %w", err)
+		}
+		return nil, err
+	}
+	return &ing, nil
+}
+
+// saves ingredient and queues recalculation of recipes using it in one transaction, recalculation may be nil
+func (r *ingredientRepository) UpdateIngredient(ctx context.Context, ingredient *models.Ingredient, recalculation *models.RecipeRecalculation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// aliases are managed separately
+		if err := tx.Omit("Aliases").Save(ingredient).Error; err != nil {
+			return err
+		}
+		if recalculation == nil {
+			return nil
+		}
+		recalculation.IngredientID = ingredient.ID
+		recalculation.Status = models.RecalculationPending
+		return tx.Create(recalculation).Error
+	})
+}
+
+// gets whole catalog with aliases in two queries, preloading would bind every ingredient ID as a parameter
+func (r *ingredientRepository) GetIngredientsForImport(ctx context.Context) ([]*models.Ingredient, error) {
+	var ingredients []*models.Ingredient
+	if err := r.db.WithContext(ctx).Find(&ingredients).Error; err != nil {
+		return nil, err
+	}
+	var aliases []models.IngredientAlias
+	if err := r.db.WithContext(ctx).Find(&aliases).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Ingredient, len(ingredients))
+	for _, ingredient := range ingredients {
+		byID[ingredient.ID] = ingredient
+	}
+	for _, alias := range aliases {
+		if ingredient, ok := byID[alias.IngredientID]; ok {
+			ingredient.Aliases = append(ingredient.Aliases, alias)
+		}
+	}
+	return ingredients, nil
+}
+
+// writes one batch of an import in a transaction, updates queue their recalculations with them
+func (r *ingredientRepository) SaveImportedIngredients(ctx context.Context, created []*models.Ingredient, updates []IngredientUpdate) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(created) > 0 {
+			if err := tx.Omit("Aliases").Create(&created).Error; err != nil {
+				return err
+			}
+		}
+		for _, update := range updates {
+			if err := tx.Omit("Aliases").Save(update.Ingredient).Error; err != nil {
+				return err
+			}
+			if update.Recalculation == nil {
+				continue
+			}
+			update.Recalculation.IngredientID = update.Ingredient.ID
+			update.Recalculation.Status = models.RecalculationPending
+			if err := tx.Create(update.Recalculation).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ingredientRepository) ListIngredients(ctx context.Context, filter IngredientFilter) ([]*models.Ingredient, int64, error) {
+	var ingredients []*models.Ingredient
+	var totalCount int64
+	query := r.db.WithContext(ctx).Model(&models.Ingredient{}).Preload("Aliases")
+	term := strings.ToLower(strings.TrimSpace(filter.Query))
+	escaped := escapeLike(term)
+	if term != "" {
+		// trigram word similarity tolerates typos like "chiken", both use the trigram indexes
+		aliases := r.db.Model(&models.IngredientAlias{}).Select("ingredient_id").Where("LOWER(name) LIKE ? OR ? <% LOWER(name)", "%"+escaped+"%", term)
+		query = query.Where("LOWER(name) LIKE ? OR ? <% LOWER(name) OR id IN (?)", "%"+escaped+"%", term, aliases)
+	}
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+	order := clause.Expr{SQL: "name ASC, id", WithoutParentheses: true}
+	if term != "" {
+		// exact match first, then prefix, word prefix, substring and the rest
+		order = clause.Expr{
+			SQL:                "CASE WHEN LOWER(name) = ? THEN 0 WHEN LOWER(name) LIKE ? THEN 1 WHEN LOWER(name) LIKE ? THEN 2 WHEN LOWER(name) LIKE ? THEN 3 ELSE 4 END, word_similarity(?, LOWER(name)) DESC, LENGTH(name), name ASC, id",
+			Vars:               []interface{}{term, escaped + "%", "% " + escaped + "%", "%" + escaped + "%", term},
+			WithoutParentheses: true,
+		}
+	}
+	offset := (filter.Page - 1) * filter.PageSize
+	tx := query.Order(clause.OrderBy{Expression: order}).Limit(filter.PageSize).Offset(offset).Find(&ingredients)
+	if tx.Error != nil {
+		return nil, 0, tx.Error
+	}
+	return ingredients, totalCount, nil
+}
+
+// soft deletes ingredient unless recipes still use it, returns number of such recipes
+func (r *ingredientRepository) DeleteIngredient(ctx context.Context, id uuid.UUID) (int64, error) {
+	var inUse int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the lock keeps recipes from starting to use the ingredient before it is deleted
+		var ingredient models.Ingredient
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", id).Take(&ingredient).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("ingredient not found %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		// deleted recipes still count, meals logged with them load their ingredients
+		err = tx.Model(&models.RecipeIngredientUsage{}).
+			Joins("JOIN recipes ON recipes.id = recipe_ingredient_usages.recipe_id").
+			Where("recipe_ingredient_usages.ingredient_id = ?", id).
+			Distinct("recipe_ingredient_usages.recipe_id").
+			Count(&inUse).Error
+		if err != nil || inUse > 0 {
+			return err
+		}
+		if err := tx.Delete(&ingredient).Error; err != nil {
+			return err
+		}
+		// aliases are released so they can be used by another ingredient
+		return tx.Where("ingredient_id = ?", id).Delete(&models.IngredientAlias{}).Error
+	})
+	return inUse, err
+}
+
+// preferred alias replaces the previous preferred alias of the same locale
+func (r *ingredientRepository) AddIngredientAlias(ctx context.Context, alias *models.IngredientAlias) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if alias.Preferred {
+			err := tx.Model(&models.IngredientAlias{}).
+				Where("ingredient_id = ? AND locale = ? AND preferred", alias.IngredientID, alias.Locale).
+				Update("preferred", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(alias).Error
+	})
+}
+func (r *ingredientRepository) DeleteIngredientAlias(ctx context.Context, ingredientID uuid.UUID, aliasID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND ingredient_id = ?", aliasID, ingredientID).Delete(&models.IngredientAlias{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("alias not found %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// subquery of ingredients having any of the lower case aliases
+func aliasedIngredientIDs(db *gorm.DB, names []string) *gorm.DB {
+	return db.Model(&models.IngredientAlias{}).Select("ingredient_id").Where("LOWER(name) IN ?", names)
+}
+func lowerNames(names []string) []string {
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(name)
+	}
+	return lowered
+}
@@ -21,12 +21,22 @@ func (r *mealRepository) GetMealsForUser(ctx context.Context, userID uuid.UUID,
 		page = 1
 	}
 	offset := (page - 1) * pageSize
-	tx := r.db.WithContext(ctx).Model(&models.Meal{}).Where("user_id = ?", userID).Order("created_at DESC").Limit(pageSize).Offset(offset).Preload("Recipe").Find(&loggedMeals)
+	tx := r.db.WithContext(ctx).Model(&models.Meal{}).Where("user_id = ?", userID).Order("created_at DESC").Limit(pageSize).Offset(offset).Preload("Recipe", unscoped).Preload("RecipeVersion").Find(&loggedMeals)
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 	return loggedMeals, nil
 }
+
+// gets every meal of the user, used by data export
+func (r *mealRepository) GetAllMealsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Meal, error) {
+	var meals []*models.Meal
+	tx := r.db.WithContext(ctx).Model(&models.Meal{}).Where("user_id = ?", userID).Order("created_at ASC").Preload("Recipe", unscoped).Preload("Recipe.IngredientUsages.Ingredient", unscoped).Preload("Recipe.IngredientUsages.Ingredient.Aliases").Preload("RecipeVersion.Ingredients.Aliases").Find(&meals)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return meals, nil
+}
 func (r *mealRepository) CreateMeal(meal *models.Meal) (*models.Meal, error) {
 	tx := r.db.Create(meal)
 	if tx.Error != nil {
@@ -39,7 +49,10 @@ func (r *mealRepository) GetMealByID(ctx context.Context, userID uuid.UUID, meal
 	var meal *models.Meal
 	tx := r.db.WithContext(ctx).Model(&models.Meal{}).
 		Where("id = ? AND user_id = ?", mealID, userID).
-		Preload("Recipe.IngredientUsages.Ingredient").
+		Preload("Recipe", unscoped).
+		Preload("Recipe.IngredientUsages.Ingredient", unscoped).
+		Preload("Recipe.IngredientUsages.Ingredient.Aliases").
+		Preload("RecipeVersion.Ingredients.Aliases").
 		First(&meal)
 
 	if tx.Error != nil {
@@ -72,6 +85,7 @@ func (r *mealRepository) GetMealCountForUser(ctx context.Context, userID uuid.UU
 
 type MealRepository interface {
 	GetMealsForUser(ctx context.Context, userID uuid.UUID, page int) ([]*models.Meal, error)
+	GetAllMealsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Meal, error)
 	CreateMeal(loggedMeal *models.Meal) (*models.Meal, error)
 	GetMealByID(ctx context.Context, userID uuid.UUID, mealID uuid.UUID) (*models.Meal, error)
 	DeleteMealByID(ctx context.Context, userID uuid.UUID, mealID uuid.UUID) error
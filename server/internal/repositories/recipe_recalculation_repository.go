@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecipeRecalculationRepository interface {
+	GetRecalculationByID(ctx context.Context, id uuid.UUID) (*models.RecipeRecalculation, error)
+	GetPendingRecalculations(ctx context.Context, staleBefore time.Time) ([]*models.RecipeRecalculation, error)
+	ClaimRecalculation(ctx context.Context, recalculation *models.RecipeRecalculation, staleBefore time.Time) (bool, error)
+	FinishRecalculation(ctx context.Context, recalculation *models.RecipeRecalculation, recipes []*models.Recipe) error
+}
+type recipeRecalculationRepository struct {
+	db *gorm.DB
+}
+
+func NewRecipeRecalculationRepository(db *gorm.DB) RecipeRecalculationRepository {
+	return &recipeRecalculationRepository{
+		db: db,
+	}
+}
+
+// gets recalculation with its report, changes ordered by recipe name
+func (r *recipeRecalculationRepository) GetRecalculationByID(ctx context.Context, id uuid.UUID) (*models.RecipeRecalculation, error) {
+	var recalculation models.RecipeRecalculation
+	err := r.db.WithContext(ctx).
+		Preload("Changes", func(db *gorm.DB) *gorm.DB { return db.Order("recipe_name ASC") }).
+		Where("id = ?", id).
+		First(&recalculation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("recalculation not found %w", err)
+		}
+		return nil, err
+	}
+	return &recalculation, nil
+}
+
+// gets pending recalculations and running ones started before staleBefore from the oldest,
+// ingredient changes are applied in order they were made
+func (r *recipeRecalculationRepository) GetPendingRecalculations(ctx context.Context, staleBefore time.Time) ([]*models.RecipeRecalculation, error) {
+	var recalculations []*models.RecipeRecalculation
+	tx := r.db.WithContext(ctx).
+		Where(claimableRecalculation(r.db, staleBefore)).
+		Order("created_at ASC").
+		Find(&recalculations)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return recalculations, nil
+}
+
+// pending or running with expired lease, running rows claimed before leases were recorded have no start
+func claimableRecalculation(db *gorm.DB, staleBefore time.Time) *gorm.DB {
+	return db.Where("status = ?", models.RecalculationPending).
+		Or("status = ? AND (started_at IS NULL OR started_at < ?)", models.RecalculationRunning, staleBefore)
+}
+
+// marks recalculation as running and records its start, false when another worker claimed it first
+func (r *recipeRecalculationRepository) ClaimRecalculation(ctx context.Context, recalculation *models.RecipeRecalculation, staleBefore time.Time) (bool, error) {
+	// postgres keeps microseconds, the start identifies the claim when finishing
+	startedAt := time.Now().Truncate(time.Microsecond)
+	tx := r.db.WithContext(ctx).Model(&models.RecipeRecalculation{}).
+		Where("id = ?", recalculation.ID).
+		Where(claimableRecalculation(r.db, staleBefore)).
+		UpdateColumns(map[string]interface{}{
+			"status":     models.RecalculationRunning,
+			"started_at": startedAt,
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	if tx.RowsAffected != 1 {
+		return false, nil
+	}
+	recalculation.Status = models.RecalculationRunning
+	recalculation.StartedAt = &startedAt
+	return true, nil
+}
+
+// recipes edited or deleted after they were loaded keep the totals of the edit
+const recipeChangedDuringRecalculation = "recipe was changed while it was recalculated, totals of the change are kept"
+
+// saves recalculated recipes together with status and report of the recalculation,
+// nothing is written when another worker reclaimed it in the meantime
+func (r *recipeRecalculationRepository) FinishRecalculation(ctx context.Context, recalculation *models.RecipeRecalculation, recipes []*models.Recipe) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// locked in the same order by every worker
+		recipes = slices.Clone(recipes)
+		slices.SortFunc(recipes, func(a, b *models.Recipe) int { return strings.Compare(a.ID.String(), b.ID.String()) })
+		unchanged := make([]*models.Recipe, 0, len(recipes))
+		for _, recipe := range recipes {
+			ok, err := lockUnchangedRecipe(tx, recipe)
+			if err != nil {
+				return err
+			}
+			if ok {
+				unchanged = append(unchanged, recipe)
+				continue
+			}
+			for i := range recalculation.Changes {
+				if change := &recalculation.Changes[i]; change.RecipeID == recipe.ID {
+					change.Calories, change.Weight = change.PreviousCalories, change.PreviousWeight
+					change.Error = recipeChangedDuringRecalculation
+				}
+			}
+		}
+		if len(unchanged) < len(recipes) {
+			recalculation.Summarize()
+		}
+		result := tx.Model(&models.RecipeRecalculation{}).
+			Where("id = ? AND status = ? AND started_at = ?", recalculation.ID, models.RecalculationRunning, recalculation.StartedAt).
+			UpdateColumns(map[string]interface{}{
+				"status":      recalculation.Status,
+				"error":       recalculation.Error,
+				"finished_at": recalculation.FinishedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("recalculation was claimed again by another worker")
+		}
+		if err := updateRecipeTotals(tx, unchanged); err != nil {
+			return err
+		}
+		versions := make(map[uuid.UUID]uint, len(unchanged))
+		for _, recipe := range unchanged {
+			versions[recipe.ID] = recipe.Version
+		}
+		for i := range recalculation.Changes {
+			recalculation.Changes[i].RecalculationID = recalculation.ID
+			recalculation.Changes[i].Version = versions[recalculation.Changes[i].RecipeID]
+		}
+		if len(recalculation.Changes) == 0 {
+			return nil
+		}
+		return tx.Create(&recalculation.Changes).Error
+	})
+}
+
+// locks the recipe until the transaction ends, false when it was deleted or got a new version since it was loaded
+func lockUnchangedRecipe(tx *gorm.DB, recipe *models.Recipe) (bool, error) {
+	var locked models.Recipe
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "version", "current_version_id").
+		Where("id = ?", recipe.ID).
+		Take(&locked).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	sameVersion := locked.CurrentVersionID == nil && recipe.CurrentVersionID == nil ||
+		locked.CurrentVersionID != nil && recipe.CurrentVersionID != nil && *locked.CurrentVersionID == *recipe.CurrentVersionID
+	return locked.Version == recipe.Version && sameVersion, nil
+}
@@ -2,38 +2,323 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"foodgenie/internal/models"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type recipeRepository struct {
 	db *gorm.DB
 }
+
+// filter and pagination of recipe listing, Sort is one of keys of recipeSortColumns
+type RecipeFilter struct {
+	Query    string
+	Sort     string
+	Page     int
+	PageSize int
+	// own recipes of the viewer are listed next to global and public ones, nil for anonymous requests
+	ViewerID *uuid.UUID
+	// lists only recipes owned by the viewer
+	OwnedOnly bool
+}
+
+var recipeSortColumns = map[string]string{
+	"name":      "name ASC",
+	"-name":     "name DESC",
+	"calories":  "calories ASC",
+	"-calories": "calories DESC",
+	"weight":    "weight ASC",
+	"-weight":   "weight DESC",
+	"created":   "created_at ASC",
+	"-created":  "created_at DESC",
+}
+
 type RecipeRepository interface {
-	CreateRecipe(recipe *models.Recipe) (*models.Recipe, error)
-	GetRecipeByName(ctx context.Context, name string) (*models.Recipe, error)
+	CreateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error)
+	GetRecipeByName(ctx context.Context, name string, ownerID *uuid.UUID) (*models.Recipe, error)
+	GetRecipeByID(ctx context.Context, id uuid.UUID) (*models.Recipe, error)
+	ListRecipes(ctx context.Context, filter RecipeFilter) ([]*models.Recipe, int64, error)
+	UpdateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error)
+	DeleteRecipe(ctx context.Context, id uuid.UUID) error
+	GetRecipeVersions(ctx context.Context, recipeID uuid.UUID) ([]*models.RecipeVersion, error)
+	GetRecipesUsingIngredient(ctx context.Context, ingredientID uuid.UUID) ([]*models.Recipe, error)
+	GetRecipesForOwner(ctx context.Context, ownerID uuid.UUID) ([]*models.Recipe, error)
 }
 
 func NewRecipeRepository(db *gorm.DB) RecipeRepository {
 	return &recipeRepository{db: db}
 }
 
-// creates recipe
-func (r *recipeRepository) CreateRecipe(recipe *models.Recipe) (*models.Recipe, error) {
-	tx := r.db.Create(recipe)
+func IsValidRecipeSort(sort string) bool {
+	_, ok := recipeSortColumns[sort]
+	return ok
+}
+
+// creates recipe together with its first version, usages must be hydrated with ingredients,
+// returns gorm.ErrDuplicatedKey when the owner already has recipe with the same name
+func (r *recipeRepository) CreateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureRecipeNameAvailable(tx, recipe); err != nil {
+			return err
+		}
+		if err := tx.Omit("IngredientUsages.Ingredient").Create(recipe).Error; err != nil {
+			return err
+		}
+		return createRecipeVersion(tx, recipe)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+// gets recipe by name, recipe of the owner takes precedence over global recipe with the same name
+func (r *recipeRepository) GetRecipeByName(ctx context.Context, name string, ownerID *uuid.UUID) (*models.Recipe, error) {
+	var recipe *models.Recipe
+	query := r.db.WithContext(ctx).Model(&models.Recipe{}).Preload("IngredientUsages.Ingredient.Aliases").Preload("Portions", orderByWeight).Where("name = ?", name)
+	if ownerID != nil {
+		query = query.Where("owner_id = ? OR owner_id IS NULL", *ownerID).Order("owner_id IS NULL")
+	} else {
+		query = query.Where("owner_id IS NULL")
+	}
+	tx := query.First(&recipe)
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 	return recipe, nil
 }
 
-// gets recipe by name
-func (r *recipeRepository) GetRecipeByName(ctx context.Context, name string) (*models.Recipe, error) {
+// gets recipe by id with ingredients
+func (r *recipeRepository) GetRecipeByID(ctx context.Context, id uuid.UUID) (*models.Recipe, error) {
 	var recipe *models.Recipe
-	tx := r.db.WithContext(ctx).Model(&models.Recipe{}).Preload("IngredientUsages.Ingredient").Where("name = ?", name).First(&recipe)
+	tx := r.db.WithContext(ctx).Model(&models.Recipe{}).Preload("IngredientUsages.Ingredient.Aliases").Preload("Portions", orderByWeight).Where("id = ?", id).First(&recipe)
 	if tx.Error != nil {
+		if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("recipe not found %w", tx.Error)
+		}
 		return nil, tx.Error
 	}
 	return recipe, nil
 }
+
+// lists recipes matching filter, returns page and total count
+func (r *recipeRepository) ListRecipes(ctx context.Context, filter RecipeFilter) ([]*models.Recipe, int64, error) {
+	var recipes []*models.Recipe
+	var totalCount int64
+	query := r.db.WithContext(ctx).Model(&models.Recipe{})
+	switch {
+	case filter.OwnedOnly && filter.ViewerID != nil:
+		query = query.Where("owner_id = ?", *filter.ViewerID)
+	case filter.ViewerID != nil:
+		query = query.Where("owner_id IS NULL OR visibility = ? OR owner_id = ?", models.RecipeVisibilityPublic, *filter.ViewerID)
+	default:
+		query = query.Where("owner_id IS NULL OR visibility = ?", models.RecipeVisibilityPublic)
+	}
+	if filter.Query != "" {
+		query = query.Where("name ILIKE ?", "%"+escapeLike(filter.Query)+"%")
+	}
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+	order, ok := recipeSortColumns[filter.Sort]
+	if !ok {
+		order = recipeSortColumns["name"]
+	}
+	offset := (filter.Page - 1) * filter.PageSize
+	tx := query.Order(order).Order("id").Limit(filter.PageSize).Offset(offset).Find(&recipes)
+	if tx.Error != nil {
+		return nil, 0, tx.Error
+	}
+	return recipes, totalCount, nil
+}
+
+// saves recipe, replaces its ingredient usages and records new version, usages must be hydrated with ingredients
+func (r *recipeRepository) UpdateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureRecipeNameAvailable(tx, recipe); err != nil {
+			return err
+		}
+		// usages and portions are hard deleted, soft deleted rows would still collide with unique indexes
+		if err := tx.Unscoped().Where("recipe_id = ?", recipe.ID).Delete(&models.RecipeIngredientUsage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("recipe_id = ?", recipe.ID).Delete(&models.RecipePortion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("IngredientUsages", "Portions").Save(recipe).Error; err != nil {
+			return err
+		}
+		for i := range recipe.Portions {
+			recipe.Portions[i].ID = uuid.Nil
+			recipe.Portions[i].RecipeID = recipe.ID
+		}
+		if len(recipe.Portions) > 0 {
+			if err := tx.Create(&recipe.Portions).Error; err != nil {
+				return err
+			}
+		}
+		for i := range recipe.IngredientUsages {
+			recipe.IngredientUsages[i].ID = uuid.Nil
+			recipe.IngredientUsages[i].RecipeID = recipe.ID
+		}
+		if len(recipe.IngredientUsages) == 0 {
+			return createRecipeVersion(tx, recipe)
+		}
+		if err := tx.Omit("Ingredient").Create(&recipe.IngredientUsages).Error; err != nil {
+			return err
+		}
+		return createRecipeVersion(tx, recipe)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+// soft deletes recipe, meals keep referencing it
+func (r *recipeRepository) DeleteRecipe(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.Recipe{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("recipe not found %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// gets all versions of recipe ordered from the oldest, deleted recipes included
+func (r *recipeRepository) GetRecipeVersions(ctx context.Context, recipeID uuid.UUID) ([]*models.RecipeVersion, error) {
+	var versions []*models.RecipeVersion
+	tx := r.db.WithContext(ctx).Model(&models.RecipeVersion{}).
+		Where("recipe_id = ?", recipeID).
+		Order("number ASC").
+		Preload("Ingredients", func(db *gorm.DB) *gorm.DB { return db.Order("name ASC") }).
+		Preload("Ingredients.Aliases").
+		Find(&versions)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return versions, nil
+}
+
+// gets all recipes of the owner with ingredients ordered by name, used by the data export
+func (r *recipeRepository) GetRecipesForOwner(ctx context.Context, ownerID uuid.UUID) ([]*models.Recipe, error) {
+	var recipes []*models.Recipe
+	tx := r.db.WithContext(ctx).Model(&models.Recipe{}).Preload("IngredientUsages.Ingredient.Aliases").Preload("Portions", orderByWeight).Where("owner_id = ?", ownerID).Order("name ASC").Order("id").Find(&recipes)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return recipes, nil
+}
+
+// gets recipes of all owners with the ingredient, usages are hydrated with ingredients
+func (r *recipeRepository) GetRecipesUsingIngredient(ctx context.Context, ingredientID uuid.UUID) ([]*models.Recipe, error) {
+	var recipes []*models.Recipe
+	usages := r.db.Model(&models.RecipeIngredientUsage{}).Select("recipe_id").Where("ingredient_id = ?", ingredientID)
+	tx := r.db.WithContext(ctx).Model(&models.Recipe{}).
+		Preload("IngredientUsages.Ingredient").
+		Where("id IN (?)", usages).
+		Order("name ASC").
+		Find(&recipes)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return recipes, nil
+}
+
+// saves recomputed totals and usage weights of recipes and versions each of them
+func updateRecipeTotals(tx *gorm.DB, recipes []*models.Recipe) error {
+	for _, recipe := range recipes {
+		for _, usage := range recipe.IngredientUsages {
+			err := tx.Model(&models.RecipeIngredientUsage{}).Where("id = ?", usage.ID).UpdateColumns(map[string]interface{}{
+				"weight":       usage.Weight,
+				"yield_factor": usage.YieldFactor,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&models.Recipe{}).Where("id = ?", recipe.ID).UpdateColumns(map[string]interface{}{
+			"weight":     recipe.Weight,
+			"raw_weight": recipe.RawWeight,
+			"calories":   recipe.Calories,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := createRecipeVersion(tx, recipe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipe names are unique among global recipes and among recipes of one owner,
+// unique indexes still guard concurrent inserts, they are translated to the same error
+func ensureRecipeNameAvailable(tx *gorm.DB, recipe *models.Recipe) error {
+	query := tx.Model(&models.Recipe{}).Where("name = ? AND id <> ?", recipe.Name, recipe.ID)
+	if recipe.OwnerID != nil {
+		query = query.Where("owner_id = ?", *recipe.OwnerID)
+	} else {
+		query = query.Where("owner_id IS NULL")
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("recipe %q already exists %w", recipe.Name, gorm.ErrDuplicatedKey)
+	}
+	return nil
+}
+
+// snapshots current state of the recipe and points recipe to it, the recipe row stays locked until the transaction ends
+// so concurrent saves can't pick the same version number
+func createRecipeVersion(tx *gorm.DB, recipe *models.Recipe) error {
+	var locked models.Recipe
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", recipe.ID).First(&locked).Error; err != nil {
+		return err
+	}
+	var lastNumber uint
+	err := tx.Model(&models.RecipeVersion{}).
+		Where("recipe_id = ?", recipe.ID).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&lastNumber).Error
+	if err != nil {
+		return err
+	}
+	version := models.RecipeVersion{
+		RecipeID:  recipe.ID,
+		Number:    lastNumber + 1,
+		Name:      recipe.Name,
+		Weight:    recipe.Weight,
+		RawWeight: recipe.RawWeight,
+		Calories:  recipe.Calories,
+		Volume:    recipe.Volume,
+	}
+	for _, usage := range recipe.IngredientUsages {
+		version.Ingredients = append(version.Ingredients, models.RecipeVersionIngredient{
+			IngredientID:    usage.IngredientID,
+			Name:            usage.Ingredient.Name,
+			Weight:          usage.Weight,
+			Quantity:        usage.Quantity,
+			Unit:            usage.Unit,
+			CaloriesPerGram: usage.Ingredient.CaloriesPerGram,
+		})
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return err
+	}
+	recipe.CurrentVersionID = &version.ID
+	recipe.Version = version.Number
+	return tx.Model(&models.Recipe{}).Where("id = ?", recipe.ID).UpdateColumns(map[string]interface{}{
+		"current_version_id": version.ID,
+		"version":            version.Number,
+	}).Error
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type SeedVersionRepository interface {
+	GetLatestSeedVersion(ctx context.Context, dataset string) (*models.SeedVersion, error)
+	CreateSeedVersion(ctx context.Context, version *models.SeedVersion) error
+}
+type seedVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewSeedVersionRepository(db *gorm.DB) SeedVersionRepository {
+	return &seedVersionRepository{
+		db: db,
+	}
+}
+func (r *seedVersionRepository) GetLatestSeedVersion(ctx context.Context, dataset string) (*models.SeedVersion, error) {
+	var version models.SeedVersion
+	err := r.db.WithContext(ctx).Where("dataset = ?", dataset).Order("version DESC").First(&version).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("seed version not found %w", err)
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// numbers the version after the latest one of its dataset
+func (r *seedVersionRepository) CreateSeedVersion(ctx context.Context, version *models.SeedVersion) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest uint
+		err := tx.Model(&models.SeedVersion{}).
+			Where("dataset = ?", version.Dataset).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		version.Version = latest + 1
+		return tx.Create(version).Error
+	})
+}
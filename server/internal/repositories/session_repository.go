@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) (*models.Session, error)
+	GetActiveSession(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	ExtendSession(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id uuid.UUID) error
+	RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keepID uuid.UUID) error
+	GetSessionsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error)
+}
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+func (r *sessionRepository) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// gets session which is neither revoked nor expired
+func (r *sessionRepository) GetActiveSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	err := r.db.WithContext(ctx).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("session not found %w", err)
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+func (r *sessionRepository) ExtendSession(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Update("expires_at", expiresAt).Error
+}
+func (r *sessionRepository) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokes all sessions of the user except keepID, pass uuid.Nil to revoke all
+func (r *sessionRepository) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keepID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
+func (r *sessionRepository) GetSessionsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Session, error) {
+	var sessions []*models.Session
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
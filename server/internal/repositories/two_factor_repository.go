@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwoFactorRepository interface {
+	EnableTwoFactor(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID) error
+	UseTimeStep(ctx context.Context, userID uuid.UUID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+	CreateChallenge(ctx context.Context, challenge *models.TwoFactorChallenge) error
+	CountChallengeAttempt(ctx context.Context, id uuid.UUID) (*models.TwoFactorChallenge, error)
+	DeleteChallenge(ctx context.Context, id uuid.UUID) error
+}
+type twoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepository{
+		db: db,
+	}
+}
+
+// replaces recovery codes of the user and turns 2FA on in one transaction
+func (r *twoFactorRepository) EnableTwoFactor(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		if len(codes) > 0 {
+			if err := tx.Create(&codes).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", true).Error
+	})
+}
+
+// removes secret, recovery codes and pending challenges of the user
+func (r *twoFactorRepository) DisableTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.RecoveryCode{}, &models.TwoFactorChallenge{}} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"two_factor_secret":  "",
+		}).Error
+	})
+}
+
+// records TOTP time step as used, fails when the same or a later step was used already
+func (r *twoFactorRepository) UseTimeStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", userID, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("time step already used")
+	}
+	return nil
+}
+
+// marks unused code as used, fails if code doesn't exist or was already used
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("recovery code not found")
+	}
+	return nil
+}
+
+// stores challenge, expired challenges are cleaned up on the way
+func (r *twoFactorRepository) CreateChallenge(ctx context.Context, challenge *models.TwoFactorChallenge) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.TwoFactorChallenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(challenge).Error
+	})
+}
+
+// increments attempts of unexpired challenge and returns it, concurrent attempts are all counted
+func (r *twoFactorRepository) CountChallengeAttempt(ctx context.Context, id uuid.UUID) (*models.TwoFactorChallenge, error) {
+	var challenge models.TwoFactorChallenge
+	result := r.db.WithContext(ctx).Model(&challenge).Clauses(clause.Returning{}).
+		Where("id = ? AND expires_at > ?", id, time.Now()).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &challenge, nil
+}
+func (r *twoFactorRepository) DeleteChallenge(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&models.TwoFactorChallenge{}).Error
+}
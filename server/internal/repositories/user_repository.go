@@ -1,19 +1,28 @@
 package repositories
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"foodgenie/internal/models"
 
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
 	CreateUser(user *models.User) (*models.User, error)
+	UpdateUser(user *models.User) error
 	DeleteUser(user *models.User) error
+	GetUsersScheduledForDeletion(ctx context.Context, before time.Time) ([]*models.User, error)
 	GetUserByUsername(username string) (*models.User, error)
 	GetUserByEmail(email string) (models.User, error)
-	GetUserById(id uuid.UUID) (*models.User, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (*models.User, error)
+	CreateEmailChangeRequest(ctx context.Context, req *models.EmailChangeRequest) error
+	GetEmailChangeRequest(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error)
+	DeleteEmailChangeRequests(ctx context.Context, userID uuid.UUID) error
 }
 type userRepository struct {
 	db *gorm.DB
@@ -31,12 +40,95 @@ func (ur *userRepository) CreateUser(user *models.User) (*models.User, error) {
 	}
 	return user, nil
 }
-func (ur *userRepository) DeleteUser(user *models.User) error {
-	if err := ur.db.Delete(user).Error; err != nil {
+func (ur *userRepository) UpdateUser(user *models.User) error {
+	if err := ur.db.Save(user).Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+// permanently deletes user together with all rows referencing them
+func (ur *userRepository) DeleteUser(user *models.User) error {
+	return ur.db.Transaction(func(tx *gorm.DB) error {
+		dependents := []interface{}{
+			&models.Meal{},
+			&models.Session{},
+			&models.RecoveryCode{},
+			&models.TwoFactorChallenge{},
+			&models.EmailChangeRequest{},
+			&models.ExternalIdentity{},
+		}
+		for _, model := range dependents {
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := deleteOwnedRecipes(tx, user.ID); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(user).Error
+	})
+}
+
+// recipes still referenced by meals of other users are soft deleted without owner so their meals stay intact,
+// the rest is removed together with usages, portions and versions
+func deleteOwnedRecipes(tx *gorm.DB, ownerID uuid.UUID) error {
+	var ownedIDs []uuid.UUID
+	if err := tx.Unscoped().Model(&models.Recipe{}).Where("owner_id = ?", ownerID).Pluck("id", &ownedIDs).Error; err != nil {
+		return err
+	}
+	if len(ownedIDs) == 0 {
+		return nil
+	}
+	var referencedIDs []uuid.UUID
+	if err := tx.Unscoped().Model(&models.Meal{}).Distinct("recipe_id").Where("recipe_id IN ?", ownedIDs).Pluck("recipe_id", &referencedIDs).Error; err != nil {
+		return err
+	}
+	referenced := make(map[uuid.UUID]bool, len(referencedIDs))
+	for _, id := range referencedIDs {
+		referenced[id] = true
+	}
+	var unreferencedIDs []uuid.UUID
+	for _, id := range ownedIDs {
+		if !referenced[id] {
+			unreferencedIDs = append(unreferencedIDs, id)
+		}
+	}
+	if len(referencedIDs) > 0 {
+		// owner is cleared so nothing points to the purged user, deleted recipes without owner are never listed
+		err := tx.Unscoped().Model(&models.Recipe{}).Where("id IN ?", referencedIDs).UpdateColumns(map[string]interface{}{
+			"owner_id":   nil,
+			"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", time.Now()),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(unreferencedIDs) == 0 {
+		return nil
+	}
+	versionIDs := tx.Unscoped().Model(&models.RecipeVersion{}).Select("id").Where("recipe_id IN ?", unreferencedIDs)
+	if err := tx.Unscoped().Where("recipe_version_id IN (?)", versionIDs).Delete(&models.RecipeVersionIngredient{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("recipe_id IN ?", unreferencedIDs).Delete(&models.RecipeVersion{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("recipe_id IN ?", unreferencedIDs).Delete(&models.RecipeIngredientUsage{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("recipe_id IN ?", unreferencedIDs).Delete(&models.RecipePortion{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", unreferencedIDs).Delete(&models.Recipe{}).Error
+}
+func (ur *userRepository) GetUsersScheduledForDeletion(ctx context.Context, before time.Time) ([]*models.User, error) {
+	var users []*models.User
+	if err := ur.db.WithContext(ctx).Where("deletion_scheduled_at <= ?", before).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
 func (ur *userRepository) GetUserByUsername(username string) (*models.User, error) {
 	var user models.User
 	if err := ur.db.Where("username = ?", username).First(&user).Error; err != nil {
@@ -58,9 +150,9 @@ func (ur *userRepository) GetUserByEmail(email string) (models.User, error) {
 	}
 	return user, nil
 }
-func (ur *userRepository) GetUserById(id uuid.UUID) (*models.User, error) {
+func (ur *userRepository) GetUserById(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	if err := ur.db.Where("id = ?", id).First(&user).Error; err != nil {
+	if err := ur.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("user not found %w", err)
 		}
@@ -68,3 +160,29 @@ func (ur *userRepository) GetUserById(id uuid.UUID) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// replaces previous pending requests of the user
+func (ur *userRepository) CreateEmailChangeRequest(ctx context.Context, req *models.EmailChangeRequest) error {
+	return ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", req.UserID).Delete(&models.EmailChangeRequest{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(req).Error
+	})
+}
+
+// gets request which is not expired
+func (ur *userRepository) GetEmailChangeRequest(ctx context.Context, tokenHash string) (*models.EmailChangeRequest, error) {
+	var req models.EmailChangeRequest
+	err := ur.db.WithContext(ctx).Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).First(&req).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("email change request not found %w", err)
+		}
+		return nil, err
+	}
+	return &req, nil
+}
+func (ur *userRepository) DeleteEmailChangeRequests(ctx context.Context, userID uuid.UUID) error {
+	return ur.db.WithContext(ctx).Unscoped().Where("user_id = ?", userID).Delete(&models.EmailChangeRequest{}).Error
+}
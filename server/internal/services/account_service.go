@@ -0,0 +1,243 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/locale"
+	"foodgenie/internal/repositories"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDeletionAlreadyScheduled = errors.New("account deletion is already scheduled")
+	ErrDeletionNotScheduled     = errors.New("account deletion is not scheduled")
+)
+
+type AccountService interface {
+	ScheduleDeletion(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, req *dto.DeleteAccountRequestDTO) (*dto.AccountDeletionResponseDTO, error)
+	CancelDeletion(ctx context.Context, userID uuid.UUID) error
+	PurgeDeletedAccounts(ctx context.Context) error
+	ExportUserData(ctx context.Context, userID uuid.UUID, w io.Writer) error
+}
+type accountService struct {
+	userRepo             repositories.UserRepository
+	mealRepo             repositories.MealRepository
+	recipeRepo           repositories.RecipeRepository
+	sessionRepo          repositories.SessionRepository
+	externalIdentityRepo repositories.ExternalIdentityRepository
+	securityService      SecurityService
+	identityVerifier     identityVerifier
+	cfg                  config.AccountConfig
+}
+
+func NewAccountService(userRepo repositories.UserRepository, mealRepo repositories.MealRepository, recipeRepo repositories.RecipeRepository, sessionRepo repositories.SessionRepository, externalIdentityRepo repositories.ExternalIdentityRepository, securityService SecurityService, cfg config.AccountConfig) AccountService {
+	return &accountService{
+		userRepo:             userRepo,
+		mealRepo:             mealRepo,
+		recipeRepo:           recipeRepo,
+		sessionRepo:          sessionRepo,
+		externalIdentityRepo: externalIdentityRepo,
+		securityService:      securityService,
+		identityVerifier:     identityVerifier{securityService: securityService, sessionRepo: sessionRepo, window: cfg.ReauthenticationWindow},
+		cfg:                  cfg,
+	}
+}
+
+// marks account for deletion after grace period and signs out all sessions,
+// accounts without password confirm it by a recent login instead
+func (s *accountService) ScheduleDeletion(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, req *dto.DeleteAccountRequestDTO) (*dto.AccountDeletionResponseDTO, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletionScheduledAt != nil {
+		return nil, ErrDeletionAlreadyScheduled
+	}
+	if err := s.identityVerifier.verify(ctx, user, req.Password, sessionID); err != nil {
+		return nil, err
+	}
+	deletionAt := time.Now().Add(s.cfg.DeletionGracePeriod)
+	user.DeletionScheduledAt = &deletionAt
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to schedule deletion %w", err)
+	}
+	if err := s.sessionRepo.RevokeOtherSessions(ctx, user.ID, uuid.Nil); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions %w", err)
+	}
+	return &dto.AccountDeletionResponseDTO{DeletionScheduledAt: deletionAt}, nil
+}
+
+// user can sign in again during grace period and cancel the deletion
+func (s *accountService) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletionScheduledAt == nil {
+		return ErrDeletionNotScheduled
+	}
+	user.DeletionScheduledAt = nil
+	return s.userRepo.UpdateUser(user)
+}
+
+// hard deletes accounts whose grace period has passed, run periodically by the purge job,
+// an account that fails is retried on the next run and doesn't hold back the others,
+// meal photos are only streamed to the recognition service and never stored, there are no files to remove
+func (s *accountService) PurgeDeletedAccounts(ctx context.Context) error {
+	users, err := s.userRepo.GetUsersScheduledForDeletion(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to fetch accounts scheduled for deletion %w", err)
+	}
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.userRepo.DeleteUser(user); err != nil {
+			slog.ErrorContext(ctx, "failed to purge account", "user_id", user.ID, "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "purged account", "user_id", user.ID)
+	}
+	return nil
+}
+
+// writes ZIP archive with all personal data of the user
+func (s *accountService) ExportUserData(ctx context.Context, userID uuid.UUID, w io.Writer) error {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	meals, err := s.mealRepo.GetAllMealsForUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch meals %w", err)
+	}
+	recipeDTOs, err := s.exportRecipes(ctx, userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.sessionRepo.GetSessionsForUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch sessions %w", err)
+	}
+	identities, err := s.externalIdentityRepo.GetExternalIdentitiesForUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch linked accounts %w", err)
+	}
+
+	profile := dto.ExportProfileDTO{
+		UserResponseDTO:  *mapUserToDTO(user),
+		TwoFactorEnabled: user.TwoFactorEnabled,
+		UpdatedAt:        user.UpdatedAt,
+		ExportedAt:       time.Now(),
+	}
+	profile.MealCount = int64(len(meals))
+	mealDTOs := make([]*dto.MealDetailResponseDTO, len(meals))
+	for i, meal := range meals {
+		mealDTOs[i] = mapMealToDetailDTO(meal, locale.Languages(ctx))
+	}
+	sessionDTOs := make([]dto.ExportSessionDTO, len(sessions))
+	for i, session := range sessions {
+		sessionDTOs[i] = dto.ExportSessionDTO{
+			ID:        session.ID,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			RevokedAt: session.RevokedAt,
+		}
+	}
+	identityDTOs := make([]dto.ExportLinkedAccountDTO, len(identities))
+	for i, identity := range identities {
+		identityDTOs[i] = dto.ExportLinkedAccountDTO{
+			Provider: identity.Provider,
+			Email:    identity.Email,
+			LinkedAt: identity.CreatedAt,
+		}
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		value any
+	}{
+		{"profile.json", profile},
+		{"meals.json", mealDTOs},
+		{"recipes.json", recipeDTOs},
+		{"sessions.json", sessionDTOs},
+		{"linked_accounts.json", identityDTOs},
+	}
+	for _, file := range files {
+		if err := writeJSONFile(archive, file.name, file.value); err != nil {
+			return err
+		}
+	}
+	if err := writeMealsCSV(archive, mealDTOs); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// own recipes with ingredients and all versions
+func (s *accountService) exportRecipes(ctx context.Context, userID uuid.UUID) ([]dto.ExportRecipeDTO, error) {
+	recipes, err := s.recipeRepo.GetRecipesForOwner(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recipes %w", err)
+	}
+	recipeDTOs := make([]dto.ExportRecipeDTO, len(recipes))
+	for i, recipe := range recipes {
+		versions, err := s.recipeRepo.GetRecipeVersions(ctx, recipe.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch recipe versions %w", err)
+		}
+		recipeDTOs[i] = dto.ExportRecipeDTO{
+			RecipeDetailResponseDTO: *mapRecipeToDTO(recipe, locale.Languages(ctx)),
+			Versions:                mapRecipeVersionsToDTO(versions, locale.Languages(ctx)),
+		}
+	}
+	return recipeDTOs, nil
+}
+func writeJSONFile(archive *zip.Writer, name string, value any) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s %w", name, err)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to write %s %w", name, err)
+	}
+	return nil
+}
+func writeMealsCSV(archive *zip.Writer, meals []*dto.MealDetailResponseDTO) error {
+	f, err := archive.Create("meals.csv")
+	if err != nil {
+		return fmt.Errorf("failed to create meals.csv %w", err)
+	}
+	writer := csv.NewWriter(f)
+	if err := writer.Write([]string{"id", "logged_at", "recipe", "weight_g", "calories_kcal"}); err != nil {
+		return err
+	}
+	for _, meal := range meals {
+		err := writer.Write([]string{
+			meal.ID.String(),
+			meal.CreatedAt.Format(time.RFC3339),
+			meal.Name,
+			strconv.FormatUint(uint64(meal.TotalWeight), 10),
+			strconv.FormatUint(uint64(meal.TotalCalories), 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// deletes users unless they are listed in failing
+type purgeUserRepository struct {
+	fakeUserRepository
+	failing map[uuid.UUID]bool
+	deleted []uuid.UUID
+}
+
+func (r *purgeUserRepository) GetUsersScheduledForDeletion(ctx context.Context, before time.Time) ([]*models.User, error) {
+	return r.users, nil
+}
+
+func (r *purgeUserRepository) DeleteUser(user *models.User) error {
+	if r.failing[user.ID] {
+		return errors.New("foreign key violation")
+	}
+	r.deleted = append(r.deleted, user.ID)
+	return nil
+}
+
+func TestPurgeDeletedAccountsContinuesAfterFailure(t *testing.T) {
+	users := []*models.User{{}, {}, {}}
+	for _, user := range users {
+		user.ID = uuid.New()
+	}
+	repo := &purgeUserRepository{fakeUserRepository: fakeUserRepository{users: users}, failing: map[uuid.UUID]bool{users[0].ID: true}}
+	service := NewAccountService(repo, nil, nil, nil, nil, nil, config.AccountConfig{})
+
+	if err := service.PurgeDeletedAccounts(context.Background()); err != nil {
+		t.Fatalf("PurgeDeletedAccounts: %v", err)
+	}
+	if len(repo.deleted) != 2 || repo.deleted[0] != users[1].ID || repo.deleted[1] != users[2].ID {
+		t.Fatalf("deleted = %v, want the two accounts after the failing one", repo.deleted)
+	}
+}
+
+func TestScheduleDeletionWithoutPasswordNeedsRecentLogin(t *testing.T) {
+	user := &models.User{Username: "anna"}
+	user.ID = uuid.New()
+	session := &models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	session.ID = uuid.New()
+	session.CreatedAt = time.Now().Add(-time.Hour)
+	sessions := &fakeSessionRepository{sessions: map[uuid.UUID]*models.Session{session.ID: session}}
+	cfg := config.AccountConfig{DeletionGracePeriod: time.Hour, ReauthenticationWindow: 10 * time.Minute}
+	service := NewAccountService(&fakeUserRepository{users: []*models.User{user}}, nil, nil, sessions, nil, nil, cfg)
+	ctx := context.Background()
+
+	if _, err := service.ScheduleDeletion(ctx, user.ID, session.ID, &dto.DeleteAccountRequestDTO{}); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Fatalf("old login: err = %v, want ErrReauthenticationRequired", err)
+	}
+	session.CreatedAt = time.Now()
+	if _, err := service.ScheduleDeletion(ctx, user.ID, session.ID, &dto.DeleteAccountRequestDTO{}); err != nil {
+		t.Fatalf("ScheduleDeletion after recent login: %v", err)
+	}
+}
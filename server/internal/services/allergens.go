@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"slices"
+	"strings"
+)
+
+var ErrUnknownTag = errors.New("unknown allergen or diet tag")
+
+// lower cases and deduplicates tags, the result follows the order of known tags
+func normalizeTags(values []string, known []string) ([]string, error) {
+	tags := []string{}
+	for _, value := range values {
+		tag := strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(known, tag) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTag, value)
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.SortFunc(tags, func(a, b string) int {
+		return slices.Index(known, a) - slices.Index(known, b)
+	})
+	return tags, nil
+}
+
+// vegan ingredients are vegetarian as well
+func normalizeDietTags(values []string) ([]string, error) {
+	if slices.ContainsFunc(values, func(value string) bool { return strings.EqualFold(strings.TrimSpace(value), models.DietVegan) }) {
+		values = append(values, models.DietVegetarian)
+	}
+	return normalizeTags(values, models.DietTags)
+}
+
+// recipe contains allergens of all its ingredients
+func recipeAllergens(recipe *models.Recipe) []string {
+	allergens := []string{}
+	for _, allergen := range models.Allergens {
+		if slices.ContainsFunc(recipe.IngredientUsages, func(usage models.RecipeIngredientUsage) bool {
+			return slices.Contains(usage.Ingredient.Allergens, allergen)
+		}) {
+			allergens = append(allergens, allergen)
+		}
+	}
+	return allergens
+}
+
+// recipe fits a diet only when all its ingredients do
+func recipeDietTags(recipe *models.Recipe) []string {
+	tags := []string{}
+	if len(recipe.IngredientUsages) == 0 {
+		return tags
+	}
+	for _, tag := range models.DietTags {
+		if !slices.ContainsFunc(recipe.IngredientUsages, func(usage models.RecipeIngredientUsage) bool {
+			return !slices.Contains(usage.Ingredient.DietTags, tag)
+		}) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ingredients with allergens nobody reviewed, the recipe may contain allergens not listed for it
+func unverifiedIngredients(recipe *models.Recipe, languages []string) []string {
+	var names []string
+	for _, usage := range recipe.IngredientUsages {
+		if !usage.Ingredient.AllergensTagged {
+			names = append(names, localizedName(&usage.Ingredient, languages))
+		}
+	}
+	return names
+}
+
+// allergies of the user found in the recipe together with the ingredients containing them
+func allergyWarnings(recipe *models.Recipe, allergies []string, languages []string) []dto.AllergyWarningDTO {
+	var warnings []dto.AllergyWarningDTO
+	for _, allergen := range models.Allergens {
+		if !slices.Contains(allergies, allergen) {
+			continue
+		}
+		warning := dto.AllergyWarningDTO{Allergen: allergen}
+		for _, usage := range recipe.IngredientUsages {
+			if slices.Contains(usage.Ingredient.Allergens, allergen) {
+				warning.Ingredients = append(warning.Ingredients, localizedName(&usage.Ingredient, languages))
+			}
+		}
+		if len(warning.Ingredients) > 0 {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"foodgenie/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestAllergyWarningsAndUnverifiedIngredients(t *testing.T) {
+	recipe := &models.Recipe{IngredientUsages: []models.RecipeIngredientUsage{
+		{Ingredient: models.Ingredient{Name: "flour", Allergens: []string{models.AllergenGluten}, AllergensTagged: true}},
+		{Ingredient: models.Ingredient{Name: "salt", Allergens: []string{}, AllergensTagged: true}},
+		// imported ingredient nobody reviewed, it may contain anything
+		{Ingredient: models.Ingredient{Name: "sauce", Allergens: []string{}}},
+		{Ingredient: models.Ingredient{Name: "milk", Allergens: []string{models.AllergenMilk}, AllergensTagged: true,
+			Aliases: []models.IngredientAlias{{Name: "mleko", Locale: "pl", Preferred: true}}}},
+	}}
+
+	warnings := allergyWarnings(recipe, []string{models.AllergenMilk, models.AllergenEgg}, []string{"pl"})
+	if len(warnings) != 1 || warnings[0].Allergen != models.AllergenMilk || !reflect.DeepEqual(warnings[0].Ingredients, []string{"mleko"}) {
+		t.Fatalf("warnings = %+v, want milk in mleko", warnings)
+	}
+	if unverified := unverifiedIngredients(recipe, nil); !reflect.DeepEqual(unverified, []string{"sauce"}) {
+		t.Fatalf("unverified = %v, want [sauce]", unverified)
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"foodgenie/internal/locale"
+	"foodgenie/internal/models"
+	"foodgenie/internal/units"
+	"math"
+	"strings"
+)
+
+// meals can be logged in servings of the recipe
+const servingUnit = "serving"
+
+var ErrInvalidQuantity = errors.New("weight or quantity of at least one gram is required")
+
+// converts quantity in given unit to grams, plain weight in grams is used when quantity is missing,
+// returns grams together with quantity and unit to store for display
+func normalizeQuantity(weight uint, quantity float64, unitName string, conversion units.Conversion) (uint, float64, units.Unit, error) {
+	if quantity == 0 {
+		if weight == 0 {
+			return 0, 0, "", ErrInvalidQuantity
+		}
+		return weight, float64(weight), units.Gram, nil
+	}
+	unit := units.Gram
+	if unitName != "" {
+		parsed, err := units.Parse(unitName)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		unit = parsed
+	}
+	grams, err := units.ToGrams(quantity, unit, conversion)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	rounded := uint(math.Round(grams))
+	if rounded == 0 {
+		return 0, 0, "", ErrInvalidQuantity
+	}
+	return rounded, quantity, unit, nil
+}
+
+func ingredientConversion(ingredient *models.Ingredient) units.Conversion {
+	return units.Conversion{
+		Density:     ingredient.Density,
+		PieceWeight: ingredient.PieceWeight,
+	}
+}
+
+func isServingUnit(unit string) bool {
+	return unit == servingUnit || unit == "servings"
+}
+
+// rows created before units were introduced have only weight in grams
+func displayQuantity(weight uint, quantity float64, unit string) (float64, string) {
+	if quantity == 0 || unit == "" {
+		return float64(weight), string(units.Gram)
+	}
+	return quantity, unit
+}
+
+// name in the first of the languages having preferred alias, the catalog name is english
+func localizedName(ingredient *models.Ingredient, languages []string) string {
+	if name, ok := preferredAlias(ingredient.Aliases, languages); ok {
+		return name
+	}
+	return ingredient.Name
+}
+
+// preferred alias in the first of the languages having one, languages after english are not searched
+func preferredAlias(aliases []models.IngredientAlias, languages []string) (string, bool) {
+	for _, language := range languages {
+		for _, alias := range aliases {
+			if alias.Preferred && alias.Locale == language {
+				return alias.Name, true
+			}
+		}
+		if language == locale.Default {
+			break
+		}
+	}
+	return "", false
+}
+
+// assigns ingredients to requested names, exact name wins over name in other case and that over alias,
+// names without ingredient are left out
+func matchIngredientNames(ingredients []*models.Ingredient, names []string) map[string]*models.Ingredient {
+	exact := make(map[string]*models.Ingredient)
+	folded := make(map[string]*models.Ingredient)
+	aliased := make(map[string]*models.Ingredient)
+	for _, ingredient := range ingredients {
+		exact[ingredient.Name] = ingredient
+		folded[strings.ToLower(ingredient.Name)] = ingredient
+		for _, alias := range ingredient.Aliases {
+			aliased[strings.ToLower(alias.Name)] = ingredient
+		}
+	}
+	matched := make(map[string]*models.Ingredient)
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		if ingredient, ok := exact[name]; ok {
+			matched[name] = ingredient
+		} else if ingredient, ok := folded[lower]; ok {
+			matched[name] = ingredient
+		} else if ingredient, ok := aliased[lower]; ok {
+			matched[name] = ingredient
+		}
+	}
+	return matched
+}
+
+// name or any alias of the ingredient ignoring case
+func ingredientHasName(ingredient *models.Ingredient, name string) bool {
+	if strings.EqualFold(ingredient.Name, name) {
+		return true
+	}
+	for _, alias := range ingredient.Aliases {
+		if strings.EqualFold(alias.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"foodgenie/internal/units"
+	"testing"
+)
+
+func TestNormalizeQuantity(t *testing.T) {
+	milk := units.Conversion{Density: 1.03}
+	egg := units.Conversion{PieceWeight: 50}
+	tests := []struct {
+		name       string
+		weight     uint
+		quantity   float64
+		unit       string
+		conversion units.Conversion
+		grams      uint
+		stored     float64
+		storedUnit units.Unit
+		err        error
+	}{
+		{"plain weight", 120, 0, "", units.Conversion{}, 120, 120, units.Gram, nil},
+		{"quantity defaults to grams", 0, 80, "", units.Conversion{}, 80, 80, units.Gram, nil},
+		{"quantity wins over weight", 500, 0.25, "kg", units.Conversion{}, 250, 0.25, units.Kilogram, nil},
+		{"ounces are rounded", 0, 3, "oz", units.Conversion{}, 85, 3, units.Ounce, nil},
+		{"volume with density", 0, 1, "cup", milk, 244, 1, units.Cup, nil},
+		{"litres alias", 0, 0.5, "Liters", milk, 515, 0.5, units.Litre, nil},
+		{"pieces with piece weight", 0, 3, "pcs", egg, 150, 3, units.Piece, nil},
+		{"volume without density", 0, 1, "cup", egg, 0, 0, "", units.ErrDensityUnknown},
+		{"pieces without piece weight", 0, 2, "piece", milk, 0, 0, "", units.ErrPieceWeightUnknown},
+		{"unknown unit", 0, 1, "pinch", milk, 0, 0, "", units.ErrUnknownUnit},
+		{"nothing entered", 0, 0, "", units.Conversion{}, 0, 0, "", ErrInvalidQuantity},
+		{"less than half a gram", 0, 0.4, "g", units.Conversion{}, 0, 0, "", ErrInvalidQuantity},
+		{"negative quantity", 0, -2, "g", units.Conversion{}, 0, 0, "", units.ErrNonPositiveQuantity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grams, stored, unit, err := normalizeQuantity(tt.weight, tt.quantity, tt.unit, tt.conversion)
+			if !errors.Is(err, tt.err) || grams != tt.grams || stored != tt.stored || unit != tt.storedUnit {
+				t.Fatalf("normalizeQuantity(%d, %v, %q) = %d, %v, %q, %v, want %d, %v, %q, %v",
+					tt.weight, tt.quantity, tt.unit, grams, stored, unit, err, tt.grams, tt.stored, tt.storedUnit, tt.err)
+			}
+		})
+	}
+}
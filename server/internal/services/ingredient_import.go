@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/foodimport"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"strings"
+)
+
+const (
+	importConflictNameTaken     = "name is used by another ingredient"
+	importConflictDuplicateName = "name is used by another record of the import"
+)
+
+var ErrInvalidImportSource = errors.New("import source is required and can have at most 50 characters")
+
+type IngredientImportOptions struct {
+	// reports what would change without writing to the database
+	DryRun bool
+	// ingredients added by hand with the same name are linked to the record instead of reported as conflicts
+	Adopt bool
+}
+
+// upserts dataset records into the catalog by source and record ID, records whose name is used by
+// another ingredient are reported as conflicts and left out, the catalog is loaded once and
+// changes are written in batches, each batch in one transaction
+func (s *ingredientService) ImportIngredients(ctx context.Context, source string, records []foodimport.Record, options IngredientImportOptions) (*dto.IngredientImportReportDTO, error) {
+	if source == "" || len(source) > 50 {
+		return nil, ErrInvalidImportSource
+	}
+	report := &dto.IngredientImportReportDTO{
+		Source:    source,
+		DryRun:    options.DryRun,
+		Conflicts: []dto.IngredientImportIssueDTO{},
+		Failed:    []dto.IngredientImportIssueDTO{},
+	}
+	catalog, err := s.ingredientRepo.GetIngredientsForImport(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ingredients %w", err)
+	}
+	index := newImportIndex(source, catalog)
+	// lower case names taken by records of this import
+	claimed := make(map[string]string)
+	batch := &importBatch{}
+	for _, record := range records {
+		if err := s.importRecord(source, record, options, index, claimed, batch, report); err != nil {
+			report.Failed = append(report.Failed, importIssue(record, err.Error()))
+		}
+		if len(batch.records) >= importBatchSize {
+			if err := s.flushImportBatch(ctx, batch, options, report); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err := s.flushImportBatch(ctx, batch, options, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// decides what the record changes, writes are collected in the batch
+func (s *ingredientService) importRecord(source string, record foodimport.Record, options IngredientImportOptions, index *importIndex, claimed map[string]string, batch *importBatch, report *dto.IngredientImportReportDTO) error {
+	imported := ingredientFromRecord(source, record)
+	if imported.Name == "" || imported.SourceID == "" {
+		return ErrInvalidIngredient
+	}
+	lowerName := strings.ToLower(imported.Name)
+	if sourceID, ok := claimed[lowerName]; ok && sourceID != record.SourceID {
+		report.Conflicts = append(report.Conflicts, importConflict(record, nil, importConflictDuplicateName))
+		return nil
+	}
+	existing := index.bySource[imported.SourceID]
+	if existing == nil || !strings.EqualFold(existing.Name, imported.Name) {
+		owner := index.byName(imported.Name)
+		switch {
+		case owner == nil:
+			// name is free
+		case existing != nil && owner == existing:
+			// record name is an alias of the ingredient, its name stays
+			imported.Name = existing.Name
+		case existing == nil && options.Adopt && owner.Source == "":
+			// adopted ingredient keeps its name even when the record matched its alias
+			existing = owner
+			imported.Name = owner.Name
+		default:
+			report.Conflicts = append(report.Conflicts, importConflict(record, owner, importConflictNameTaken))
+			return nil
+		}
+	}
+	claimed[lowerName] = record.SourceID
+	if existing == nil {
+		if imported.YieldFactor == 0 {
+			imported.YieldFactor = 1
+		}
+		batch.records = append(batch.records, record)
+		batch.created = append(batch.created, imported)
+		index.add(imported)
+		return nil
+	}
+	updated := mergeImportedIngredient(existing, imported)
+	if sameImportedData(existing, updated) {
+		report.Unchanged++
+		return nil
+	}
+	update := repositories.IngredientUpdate{Ingredient: updated}
+	if changesRecipeTotals(existing, updated) {
+		update.Recalculation = &models.RecipeRecalculation{PreviousYieldFactor: existing.YieldFactor}
+	}
+	batch.records = append(batch.records, record)
+	batch.updates = append(batch.updates, update)
+	index.replace(existing, updated)
+	return nil
+}
+
+// writes collected changes and counts them, records of a failed batch are reported as failed
+func (s *ingredientService) flushImportBatch(ctx context.Context, batch *importBatch, options IngredientImportOptions, report *dto.IngredientImportReportDTO) error {
+	defer batch.reset()
+	if len(batch.records) == 0 {
+		return nil
+	}
+	if !options.DryRun {
+		if err := s.ingredientRepo.SaveImportedIngredients(ctx, batch.created, batch.updates); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			for _, record := range batch.records {
+				report.Failed = append(report.Failed, importIssue(record, err.Error()))
+			}
+			return nil
+		}
+	}
+	report.Created += len(batch.created)
+	report.Updated += len(batch.updates)
+	for _, update := range batch.updates {
+		if update.Recalculation != nil {
+			report.Recalculations++
+		}
+	}
+	return nil
+}
+
+// records written together, a crash leaves whole batches applied and running the import again finishes it
+const importBatchSize = 500
+
+type importBatch struct {
+	records []foodimport.Record
+	created []*models.Ingredient
+	updates []repositories.IngredientUpdate
+}
+
+func (b *importBatch) reset() {
+	b.records, b.created, b.updates = nil, nil, nil
+}
+
+// catalog kept in memory during an import, follows changes of the import so later records see them
+type importIndex struct {
+	source   string
+	bySource map[string]*models.Ingredient
+	exact    map[string]*models.Ingredient
+	folded   map[string]*models.Ingredient
+	aliased  map[string]*models.Ingredient
+}
+
+func newImportIndex(source string, ingredients []*models.Ingredient) *importIndex {
+	index := &importIndex{
+		source:   source,
+		bySource: make(map[string]*models.Ingredient),
+		exact:    make(map[string]*models.Ingredient),
+		folded:   make(map[string]*models.Ingredient),
+		aliased:  make(map[string]*models.Ingredient),
+	}
+	for _, ingredient := range ingredients {
+		index.add(ingredient)
+	}
+	return index
+}
+func (i *importIndex) add(ingredient *models.Ingredient) {
+	if ingredient.Source == i.source && ingredient.SourceID != "" {
+		i.bySource[ingredient.SourceID] = ingredient
+	}
+	i.exact[ingredient.Name] = ingredient
+	i.folded[strings.ToLower(ingredient.Name)] = ingredient
+	for _, alias := range ingredient.Aliases {
+		i.aliased[strings.ToLower(alias.Name)] = ingredient
+	}
+}
+func (i *importIndex) replace(previous *models.Ingredient, ingredient *models.Ingredient) {
+	if i.exact[previous.Name] == previous {
+		delete(i.exact, previous.Name)
+	}
+	if i.folded[strings.ToLower(previous.Name)] == previous {
+		delete(i.folded, strings.ToLower(previous.Name))
+	}
+	// adopted ingredient is no longer known by its old source ID
+	if previous.Source == i.source && i.bySource[previous.SourceID] == previous {
+		delete(i.bySource, previous.SourceID)
+	}
+	i.add(ingredient)
+}
+
+// ingredient named so or having such alias, nil when there is none, same precedence as matchIngredientNames
+func (i *importIndex) byName(name string) *models.Ingredient {
+	if ingredient, ok := i.exact[name]; ok {
+		return ingredient
+	}
+	lower := strings.ToLower(name)
+	if ingredient, ok := i.folded[lower]; ok {
+		return ingredient
+	}
+	return i.aliased[lower]
+}
+
+// databases publish nutrients per 100 g, the catalog stores them per gram,
+// they don't list allergens so the ingredient stays untagged until someone reviews it
+func ingredientFromRecord(source string, record foodimport.Record) *models.Ingredient {
+	return &models.Ingredient{
+		Name:                 strings.TrimSpace(record.Name),
+		CaloriesPerGram:      record.Calories / 100,
+		ProteinPerGram:       record.Protein / 100,
+		FatPerGram:           record.Fat / 100,
+		CarbohydratesPerGram: record.Carbohydrates / 100,
+		FiberPerGram:         record.Fiber / 100,
+		SugarPerGram:         record.Sugar / 100,
+		SodiumPerGram:        record.Sodium / 100,
+		Density:              record.Density,
+		PieceWeight:          record.PieceWeight,
+		YieldFactor:          record.YieldFactor,
+		Allergens:            []string{},
+		DietTags:             []string{},
+		Source:               source,
+		SourceID:             strings.TrimSpace(record.SourceID),
+	}
+}
+
+// nutrients come from the record, measures only when the record knows them so values entered by hand stay,
+// name differing only in case is kept
+func mergeImportedIngredient(existing *models.Ingredient, imported *models.Ingredient) *models.Ingredient {
+	updated := *existing
+	if !strings.EqualFold(existing.Name, imported.Name) {
+		updated.Name = imported.Name
+	}
+	updated.CaloriesPerGram = imported.CaloriesPerGram
+	updated.ProteinPerGram = imported.ProteinPerGram
+	updated.FatPerGram = imported.FatPerGram
+	updated.CarbohydratesPerGram = imported.CarbohydratesPerGram
+	updated.FiberPerGram = imported.FiberPerGram
+	updated.SugarPerGram = imported.SugarPerGram
+	updated.SodiumPerGram = imported.SodiumPerGram
+	if imported.Density > 0 {
+		updated.Density = imported.Density
+	}
+	if imported.PieceWeight > 0 {
+		updated.PieceWeight = imported.PieceWeight
+	}
+	if imported.YieldFactor > 0 {
+		updated.YieldFactor = imported.YieldFactor
+	}
+	updated.Source = imported.Source
+	updated.SourceID = imported.SourceID
+	return &updated
+}
+func sameImportedData(a *models.Ingredient, b *models.Ingredient) bool {
+	return a.Name == b.Name &&
+		a.CaloriesPerGram == b.CaloriesPerGram &&
+		a.ProteinPerGram == b.ProteinPerGram &&
+		a.FatPerGram == b.FatPerGram &&
+		a.CarbohydratesPerGram == b.CarbohydratesPerGram &&
+		a.FiberPerGram == b.FiberPerGram &&
+		a.SugarPerGram == b.SugarPerGram &&
+		a.SodiumPerGram == b.SodiumPerGram &&
+		a.Density == b.Density &&
+		a.PieceWeight == b.PieceWeight &&
+		a.YieldFactor == b.YieldFactor &&
+		a.Source == b.Source &&
+		a.SourceID == b.SourceID
+}
+func importIssue(record foodimport.Record, reason string) dto.IngredientImportIssueDTO {
+	return dto.IngredientImportIssueDTO{
+		SourceID: record.SourceID,
+		Name:     record.Name,
+		Reason:   reason,
+	}
+}
+func importConflict(record foodimport.Record, owner *models.Ingredient, reason string) dto.IngredientImportIssueDTO {
+	conflict := importIssue(record, reason)
+	if owner != nil {
+		conflict.ExistingID = &owner.ID
+		conflict.ExistingSource = owner.Source
+	}
+	return conflict
+}
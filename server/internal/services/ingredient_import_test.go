@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"foodgenie/internal/config"
+	"foodgenie/internal/foodimport"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func (r *fakeIngredientRepository) GetIngredientsForImport(ctx context.Context) ([]*models.Ingredient, error) {
+	return r.ingredients, nil
+}
+
+func (r *fakeIngredientRepository) SaveImportedIngredients(ctx context.Context, created []*models.Ingredient, updates []repositories.IngredientUpdate) error {
+	r.batches++
+	for _, ingredient := range created {
+		ingredient.ID = uuid.New()
+		r.ingredients = append(r.ingredients, ingredient)
+	}
+	r.updates = append(r.updates, updates...)
+	return nil
+}
+
+func TestImportIngredients(t *testing.T) {
+	milk := &models.Ingredient{Name: "milk", CaloriesPerGram: 0.6, YieldFactor: 1, Source: foodimport.FDCSource, SourceID: "1"}
+	butter := &models.Ingredient{Name: "Butter", CaloriesPerGram: 7.17, YieldFactor: 1,
+		Aliases: []models.IngredientAlias{{Name: "masło", Locale: "pl"}}}
+	salt := &models.Ingredient{Name: "salt", YieldFactor: 1, Source: "manual", SourceID: "salt"}
+	for _, ingredient := range []*models.Ingredient{milk, butter, salt} {
+		ingredient.ID = uuid.New()
+	}
+	repo := &fakeIngredientRepository{ingredients: []*models.Ingredient{milk, butter, salt}}
+	service := NewIngredientService(repo, nil, nil, config.CatalogConfig{})
+
+	records := []foodimport.Record{
+		// calories changed, recipes using milk are recalculated
+		{SourceID: "1", Name: "milk", Calories: 64},
+		{SourceID: "2", Name: "oats", Calories: 372},
+		{SourceID: "3", Name: "oat flakes", Calories: 372},
+		{SourceID: "4", Name: "OATS", Calories: 380},
+		// name of an ingredient added by hand and an alias of it
+		{SourceID: "5", Name: "butter", Calories: 717},
+		{SourceID: "6", Name: "Masło", Calories: 717},
+		// another source owns the name
+		{SourceID: "7", Name: "Salt"},
+		{SourceID: "8", Name: " "},
+	}
+	report, err := service.ImportIngredients(context.Background(), foodimport.FDCSource, records, IngredientImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportIngredients: %v", err)
+	}
+	if report.Created != 2 || report.Updated != 1 || report.Recalculations != 1 || len(report.Conflicts) != 4 || len(report.Failed) != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if repo.batches != 1 || len(repo.updates) != 1 || repo.updates[0].Ingredient.CaloriesPerGram != 0.64 {
+		t.Fatalf("saved %d batches with updates %+v", repo.batches, repo.updates)
+	}
+
+	// butter is adopted, its alias then names the adopted ingredient
+	adopted, err := service.ImportIngredients(context.Background(), foodimport.FDCSource, records[4:6], IngredientImportOptions{Adopt: true})
+	if err != nil {
+		t.Fatalf("ImportIngredients with adopt: %v", err)
+	}
+	if adopted.Updated != 1 || len(adopted.Conflicts) != 1 || adopted.Conflicts[0].SourceID != "6" {
+		t.Fatalf("adopt report = %+v", adopted)
+	}
+	if update := repo.updates[1].Ingredient; update.ID != butter.ID || update.Name != "Butter" || update.SourceID != "5" {
+		t.Fatalf("adopted ingredient = %+v", update)
+	}
+}
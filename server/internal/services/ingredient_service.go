@@ -3,31 +3,123 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"foodgenie/internal/config"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/foodimport"
+	"foodgenie/internal/locale"
 	"foodgenie/internal/models"
 	"foodgenie/internal/repositories"
+	"foodgenie/internal/units"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type ingredientService struct {
-	ingredientRepo repositories.IngredientRepository
+	ingredientRepo    repositories.IngredientRepository
+	recipeRepo        repositories.RecipeRepository
+	recalculationRepo repositories.RecipeRecalculationRepository
+	cfg               config.CatalogConfig
 }
 
 type IngredientService interface {
 	CreateIngredient(ctx context.Context, req dto.CreateIngredientRequestDTO) (*models.Ingredient, error)
+	ListIngredients(ctx context.Context, query *dto.IngredientListQueryDTO) (*dto.PaginatedIngredientsResponseDTO, error)
+	GetIngredientByID(ctx context.Context, id uuid.UUID) (*dto.IngredientDTO, error)
+	GetIngredientByName(ctx context.Context, name string) (*dto.IngredientDTO, error)
+	DeleteIngredient(ctx context.Context, id uuid.UUID) error
+	ReplaceIngredient(ctx context.Context, id uuid.UUID, req dto.CreateIngredientRequestDTO) (*dto.IngredientUpdateResponseDTO, error)
+	PatchIngredient(ctx context.Context, id uuid.UUID, req dto.PatchIngredientRequestDTO) (*dto.IngredientUpdateResponseDTO, error)
+	AddIngredientAlias(ctx context.Context, ingredientID uuid.UUID, req dto.IngredientAliasRequestDTO) (*dto.IngredientDTO, error)
+	DeleteIngredientAlias(ctx context.Context, ingredientID uuid.UUID, aliasID uuid.UUID) error
+	GetRecalculation(ctx context.Context, id uuid.UUID) (*dto.RecipeRecalculationDTO, error)
+	ProcessRecalculations(ctx context.Context) error
+	ImportIngredients(ctx context.Context, source string, records []foodimport.Record, options IngredientImportOptions) (*dto.IngredientImportReportDTO, error)
 }
 
-func NewIngredientService(ingredientRepo repositories.IngredientRepository) IngredientService {
+var (
+	ErrInvalidIngredient   = errors.New("ingredient name cannot be empty, density, piece weight and yield factor cannot be negative")
+	ErrIngredientNameTaken = errors.New("ingredient with this name already exists")
+	ErrIngredientInUse     = errors.New("ingredient is used by recipes")
+	ErrInvalidAlias        = errors.New("alias name cannot be empty and only one alias per locale can be preferred")
+	ErrAliasTaken          = errors.New("alias already names another ingredient")
+)
+
+const (
+	defaultIngredientPageSize = 20
+	maxIngredientPageSize     = 100
+)
+
+func NewIngredientService(ingredientRepo repositories.IngredientRepository, recipeRepo repositories.RecipeRepository, recalculationRepo repositories.RecipeRecalculationRepository, cfg config.CatalogConfig) IngredientService {
 	return &ingredientService{
-		ingredientRepo: ingredientRepo,
+		ingredientRepo:    ingredientRepo,
+		recipeRepo:        recipeRepo,
+		recalculationRepo: recalculationRepo,
+		cfg:               cfg,
 	}
 }
 func (s *ingredientService) CreateIngredient(ctx context.Context, req dto.CreateIngredientRequestDTO) (*models.Ingredient, error) {
 	if req.Name == "" {
 		return nil, errors.New("ingredient name cannot be empty")
 	}
+	if req.Density < 0 || req.PieceWeight < 0 || req.YieldFactor < 0 {
+		return nil, errors.New("density, piece weight and yield factor cannot be negative")
+	}
+	if req.CaloriesPerGram < 0 || req.ProteinPerGram < 0 || req.FatPerGram < 0 || req.CarbohydratesPerGram < 0 ||
+		req.FiberPerGram < 0 || req.SugarPerGram < 0 || req.SodiumPerGram < 0 {
+		return nil, errors.New("calories and nutrients cannot be negative")
+	}
+	if req.YieldFactor == 0 {
+		req.YieldFactor = 1
+	}
+	allergens, err := normalizeTags(req.Allergens, models.Allergens)
+	if err != nil {
+		return nil, err
+	}
+	dietTags, err := normalizeDietTags(req.DietTags)
+	if err != nil {
+		return nil, err
+	}
 	ingToCreate := models.Ingredient{
-		Name:            req.Name,
-		CaloriesPerGram: req.CaloriesPerGram,
+		Name:                 req.Name,
+		CaloriesPerGram:      req.CaloriesPerGram,
+		ProteinPerGram:       req.ProteinPerGram,
+		FatPerGram:           req.FatPerGram,
+		CarbohydratesPerGram: req.CarbohydratesPerGram,
+		FiberPerGram:         req.FiberPerGram,
+		SugarPerGram:         req.SugarPerGram,
+		SodiumPerGram:        req.SodiumPerGram,
+		Density:              req.Density,
+		PieceWeight:          req.PieceWeight,
+		YieldFactor:          req.YieldFactor,
+		Allergens:            allergens,
+		AllergensTagged:      req.Allergens != nil,
+		DietTags:             dietTags,
+	}
+	preferred := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, aliasReq := range req.Aliases {
+		alias, err := buildIngredientAlias(aliasReq)
+		if err != nil {
+			return nil, err
+		}
+		if alias.Preferred && preferred[alias.Locale] {
+			return nil, ErrInvalidAlias
+		}
+		preferred[alias.Locale] = preferred[alias.Locale] || alias.Preferred
+		key := alias.Locale + "\x00" + strings.ToLower(alias.Name)
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s", ErrAliasTaken, alias.Name)
+		}
+		seen[key] = true
+		if err := s.ensureAliasAvailable(ctx, uuid.Nil, alias); err != nil {
+			return nil, err
+		}
+		ingToCreate.Aliases = append(ingToCreate.Aliases, *alias)
 	}
 	ing, err := s.ingredientRepo.CreateIngredient(&ingToCreate)
 	if err != nil {
@@ -35,3 +127,411 @@ func (s *ingredientService) CreateIngredient(ctx context.Context, req dto.Create
 	}
 	return ing, nil
 }
+
+// lists ingredients matching the search, all of them by name when it is empty
+func (s *ingredientService) ListIngredients(ctx context.Context, query *dto.IngredientListQueryDTO) (*dto.PaginatedIngredientsResponseDTO, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultIngredientPageSize
+	}
+	if query.PageSize > maxIngredientPageSize {
+		query.PageSize = maxIngredientPageSize
+	}
+	ingredients, totalCount, err := s.ingredientRepo.ListIngredients(ctx, repositories.IngredientFilter{
+		Query:    query.Query,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingredients %w", err)
+	}
+	items := make([]dto.IngredientDTO, len(ingredients))
+	for i, ingredient := range ingredients {
+		items[i] = *mapIngredientToDTO(ingredient, locale.Languages(ctx))
+	}
+	return &dto.PaginatedIngredientsResponseDTO{
+		Ingredients: items,
+		TotalCount:  totalCount,
+		Page:        query.Page,
+		PageSize:    query.PageSize,
+	}, nil
+}
+func (s *ingredientService) GetIngredientByID(ctx context.Context, id uuid.UUID) (*dto.IngredientDTO, error) {
+	ingredient, err := s.ingredientRepo.GetIngredientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mapIngredientToDTO(ingredient, locale.Languages(ctx)), nil
+}
+
+// exact catalog name, aliases don't match
+func (s *ingredientService) GetIngredientByName(ctx context.Context, name string) (*dto.IngredientDTO, error) {
+	ingredient, err := s.ingredientRepo.GetIngredientByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return mapIngredientToDTO(ingredient, locale.Languages(ctx)), nil
+}
+
+// soft deletes ingredient, recipes using it have to be changed first
+func (s *ingredientService) DeleteIngredient(ctx context.Context, id uuid.UUID) error {
+	inUse, err := s.ingredientRepo.DeleteIngredient(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return fmt.Errorf("%w: %d recipes", ErrIngredientInUse, inUse)
+	}
+	return nil
+}
+
+// replaces all nutrition data of the ingredient, nutrients missing in the request are reset to unknown
+func (s *ingredientService) ReplaceIngredient(ctx context.Context, id uuid.UUID, req dto.CreateIngredientRequestDTO) (*dto.IngredientUpdateResponseDTO, error) {
+	ingredient, err := s.ingredientRepo.GetIngredientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.YieldFactor == 0 {
+		req.YieldFactor = 1
+	}
+	allergens, err := normalizeTags(req.Allergens, models.Allergens)
+	if err != nil {
+		return nil, err
+	}
+	dietTags, err := normalizeDietTags(req.DietTags)
+	if err != nil {
+		return nil, err
+	}
+	previous := *ingredient
+	ingredient.Name = req.Name
+	ingredient.CaloriesPerGram = req.CaloriesPerGram
+	ingredient.ProteinPerGram = req.ProteinPerGram
+	ingredient.FatPerGram = req.FatPerGram
+	ingredient.CarbohydratesPerGram = req.CarbohydratesPerGram
+	ingredient.FiberPerGram = req.FiberPerGram
+	ingredient.SugarPerGram = req.SugarPerGram
+	ingredient.SodiumPerGram = req.SodiumPerGram
+	ingredient.Density = req.Density
+	ingredient.PieceWeight = req.PieceWeight
+	ingredient.YieldFactor = req.YieldFactor
+	ingredient.Allergens = allergens
+	ingredient.AllergensTagged = req.Allergens != nil
+	ingredient.DietTags = dietTags
+	return s.saveIngredient(ctx, &previous, ingredient)
+}
+
+// updates only fields present in the request
+func (s *ingredientService) PatchIngredient(ctx context.Context, id uuid.UUID, req dto.PatchIngredientRequestDTO) (*dto.IngredientUpdateResponseDTO, error) {
+	ingredient, err := s.ingredientRepo.GetIngredientByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := *ingredient
+	if req.Name != nil {
+		ingredient.Name = *req.Name
+	}
+	if req.CaloriesPerGram != nil {
+		ingredient.CaloriesPerGram = *req.CaloriesPerGram
+	}
+	for _, nutrient := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{req.ProteinPerGram, &ingredient.ProteinPerGram},
+		{req.FatPerGram, &ingredient.FatPerGram},
+		{req.CarbohydratesPerGram, &ingredient.CarbohydratesPerGram},
+		{req.FiberPerGram, &ingredient.FiberPerGram},
+		{req.SugarPerGram, &ingredient.SugarPerGram},
+		{req.SodiumPerGram, &ingredient.SodiumPerGram},
+	} {
+		if nutrient.value != nil {
+			*nutrient.target = *nutrient.value
+		}
+	}
+	if req.Density != nil {
+		ingredient.Density = *req.Density
+	}
+	if req.PieceWeight != nil {
+		ingredient.PieceWeight = *req.PieceWeight
+	}
+	if req.YieldFactor != nil {
+		ingredient.YieldFactor = *req.YieldFactor
+	}
+	if req.Allergens != nil {
+		if ingredient.Allergens, err = normalizeTags(req.Allergens, models.Allergens); err != nil {
+			return nil, err
+		}
+		ingredient.AllergensTagged = true
+	}
+	if req.DietTags != nil {
+		if ingredient.DietTags, err = normalizeDietTags(req.DietTags); err != nil {
+			return nil, err
+		}
+	}
+	return s.saveIngredient(ctx, &previous, ingredient)
+}
+
+// recipes using the ingredient are recalculated in background when nutrition data changed
+func (s *ingredientService) saveIngredient(ctx context.Context, previous *models.Ingredient, ingredient *models.Ingredient) (*dto.IngredientUpdateResponseDTO, error) {
+	if ingredient.Name == "" || ingredient.CaloriesPerGram < 0 || ingredient.Density < 0 || ingredient.PieceWeight < 0 || ingredient.YieldFactor <= 0 ||
+		ingredient.ProteinPerGram < 0 || ingredient.FatPerGram < 0 || ingredient.CarbohydratesPerGram < 0 ||
+		ingredient.FiberPerGram < 0 || ingredient.SugarPerGram < 0 || ingredient.SodiumPerGram < 0 {
+		return nil, ErrInvalidIngredient
+	}
+	if ingredient.Name != previous.Name {
+		existing, err := s.ingredientRepo.GetIngredientByName(ctx, ingredient.Name)
+		if err == nil && existing.ID != ingredient.ID {
+			return nil, ErrIngredientNameTaken
+		}
+	}
+	var recalculation *models.RecipeRecalculation
+	if changesRecipeTotals(previous, ingredient) {
+		recalculation = &models.RecipeRecalculation{PreviousYieldFactor: previous.YieldFactor}
+	}
+	if err := s.ingredientRepo.UpdateIngredient(ctx, ingredient, recalculation); err != nil {
+		return nil, fmt.Errorf("failed to update ingredient %w", err)
+	}
+	response := &dto.IngredientUpdateResponseDTO{Ingredient: mapIngredientToDTO(ingredient, locale.Languages(ctx))}
+	if recalculation != nil {
+		response.Recalculation = mapRecalculationToDTO(recalculation)
+	}
+	return response, nil
+}
+
+// adds synonym or translation usable in recipes instead of the ingredient name
+func (s *ingredientService) AddIngredientAlias(ctx context.Context, ingredientID uuid.UUID, req dto.IngredientAliasRequestDTO) (*dto.IngredientDTO, error) {
+	if _, err := s.ingredientRepo.GetIngredientByID(ctx, ingredientID); err != nil {
+		return nil, err
+	}
+	alias, err := buildIngredientAlias(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureAliasAvailable(ctx, ingredientID, alias); err != nil {
+		return nil, err
+	}
+	alias.IngredientID = ingredientID
+	if err := s.ingredientRepo.AddIngredientAlias(ctx, alias); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrAliasTaken
+		}
+		return nil, fmt.Errorf("failed to add alias %w", err)
+	}
+	return s.GetIngredientByID(ctx, ingredientID)
+}
+func (s *ingredientService) DeleteIngredientAlias(ctx context.Context, ingredientID uuid.UUID, aliasID uuid.UUID) error {
+	return s.ingredientRepo.DeleteIngredientAlias(ctx, ingredientID, aliasID)
+}
+
+// alias must not resolve to a different ingredient, recipes are matched by name regardless of locale,
+// the ingredient itself can't have it twice in one locale in any case
+func (s *ingredientService) ensureAliasAvailable(ctx context.Context, ingredientID uuid.UUID, alias *models.IngredientAlias) error {
+	ingredients, err := s.ingredientRepo.GetIngredientsByNames(ctx, []string{alias.Name})
+	if err != nil {
+		return fmt.Errorf("failed to check alias %w", err)
+	}
+	existing, ok := matchIngredientNames(ingredients, []string{alias.Name})[alias.Name]
+	if !ok {
+		return nil
+	}
+	if existing.ID != ingredientID {
+		return fmt.Errorf("%w: %s", ErrAliasTaken, existing.Name)
+	}
+	for _, own := range existing.Aliases {
+		if own.Locale == alias.Locale && strings.EqualFold(own.Name, alias.Name) {
+			return fmt.Errorf("%w: %s", ErrAliasTaken, own.Name)
+		}
+	}
+	return nil
+}
+func (s *ingredientService) GetRecalculation(ctx context.Context, id uuid.UUID) (*dto.RecipeRecalculationDTO, error) {
+	recalculation, err := s.recalculationRepo.GetRecalculationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mapRecalculationToDTO(recalculation), nil
+}
+
+// recalculates recipes of pending ingredient changes, run periodically in background,
+// recalculations whose worker died are taken over once their lease expires
+func (s *ingredientService) ProcessRecalculations(ctx context.Context) error {
+	staleBefore := time.Now().Add(-s.cfg.RecalculationLease)
+	recalculations, err := s.recalculationRepo.GetPendingRecalculations(ctx, staleBefore)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pending recalculations %w", err)
+	}
+	for _, recalculation := range recalculations {
+		claimed, err := s.recalculationRepo.ClaimRecalculation(ctx, recalculation, staleBefore)
+		if err != nil {
+			return fmt.Errorf("failed to claim recalculation %s %w", recalculation.ID, err)
+		}
+		if !claimed {
+			continue
+		}
+		recipes, changes, err := s.recalculateRecipes(ctx, recalculation)
+		if err != nil {
+			// recipes couldn't be loaded, the recalculation is retried once its lease expires
+			slog.ErrorContext(ctx, "recalculation failed", "recalculation_id", recalculation.ID, "error", err)
+			continue
+		}
+		now := time.Now()
+		recalculation.FinishedAt = &now
+		recalculation.Changes = changes
+		recalculation.Summarize()
+		if err := s.recalculationRepo.FinishRecalculation(ctx, recalculation, recipes); err != nil {
+			slog.ErrorContext(ctx, "failed to finish recalculation", "recalculation_id", recalculation.ID, "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "recalculation finished", "recalculation_id", recalculation.ID, "recipes", len(changes), "error", recalculation.Error)
+	}
+	return nil
+}
+
+// recomputes totals of recipes using the ingredient, returns recipes to save and report of all touched recipes,
+// changed recipes get a new version so meals keep their numbers, a recipe that fails keeps its totals
+func (s *ingredientService) recalculateRecipes(ctx context.Context, recalculation *models.RecipeRecalculation) ([]*models.Recipe, []models.RecipeRecalculationChange, error) {
+	recipes, err := s.recipeRepo.GetRecipesUsingIngredient(ctx, recalculation.IngredientID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch recipes %w", err)
+	}
+	var changed []*models.Recipe
+	var changes []models.RecipeRecalculationChange
+	for _, recipe := range recipes {
+		previousCalories, previousWeight, previousRawWeight := recipe.Calories, recipe.Weight, recipe.RawWeight
+		usagesChanged := false
+		for i := range recipe.IngredientUsages {
+			usage := &recipe.IngredientUsages[i]
+			if usage.IngredientID != recalculation.IngredientID {
+				continue
+			}
+			// overridden yield factor stays
+			if usage.YieldFactor == recalculation.PreviousYieldFactor && usage.YieldFactor != usage.Ingredient.YieldFactor {
+				usage.YieldFactor = usage.Ingredient.YieldFactor
+				usagesChanged = true
+			}
+			if weight, ok := reconvertedWeight(usage); ok && weight != usage.Weight {
+				usage.Weight = weight
+				usagesChanged = true
+			}
+		}
+		if err := applyRecipeTotals(recipe); err != nil {
+			changes = append(changes, models.RecipeRecalculationChange{
+				RecipeID:         recipe.ID,
+				RecipeName:       recipe.Name,
+				PreviousCalories: previousCalories,
+				Calories:         previousCalories,
+				PreviousWeight:   previousWeight,
+				Weight:           previousWeight,
+				Error:            err.Error(),
+			})
+			continue
+		}
+		if !usagesChanged && recipe.Calories == previousCalories && recipe.Weight == previousWeight && recipe.RawWeight == previousRawWeight {
+			continue
+		}
+		changed = append(changed, recipe)
+		changes = append(changes, models.RecipeRecalculationChange{
+			RecipeID:         recipe.ID,
+			RecipeName:       recipe.Name,
+			PreviousCalories: previousCalories,
+			Calories:         recipe.Calories,
+			PreviousWeight:   previousWeight,
+			Weight:           recipe.Weight,
+		})
+	}
+	return changed, changes, nil
+}
+
+// recipes store only calories and weights, changes of other nutrients don't affect them
+func changesRecipeTotals(previous *models.Ingredient, ingredient *models.Ingredient) bool {
+	return previous.CaloriesPerGram != ingredient.CaloriesPerGram ||
+		previous.Density != ingredient.Density ||
+		previous.PieceWeight != ingredient.PieceWeight ||
+		previous.YieldFactor != ingredient.YieldFactor
+}
+
+// amounts entered in volume or count units follow new density and piece weight,
+// weight is kept when the ingredient can no longer be converted
+func reconvertedWeight(usage *models.RecipeIngredientUsage) (uint, bool) {
+	if usage.Quantity == 0 || usage.Unit == "" {
+		return 0, false
+	}
+	unit, err := units.Parse(usage.Unit)
+	if err != nil || unit.Kind() == units.Mass {
+		return 0, false
+	}
+	weight, _, _, err := normalizeQuantity(usage.Weight, usage.Quantity, usage.Unit, ingredientConversion(&usage.Ingredient))
+	if err != nil {
+		return 0, false
+	}
+	return weight, true
+}
+func buildIngredientAlias(req dto.IngredientAliasRequestDTO) (*models.IngredientAlias, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidAlias
+	}
+	tag := locale.Normalize(req.Locale)
+	if tag == "" {
+		tag = locale.Default
+	}
+	return &models.IngredientAlias{Name: name, Locale: tag, Preferred: req.Preferred}, nil
+}
+func mapIngredientToDTO(ingredient *models.Ingredient, languages []string) *dto.IngredientDTO {
+	aliases := make([]dto.IngredientAliasDTO, len(ingredient.Aliases))
+	for i, alias := range ingredient.Aliases {
+		aliases[i] = dto.IngredientAliasDTO{
+			ID:        alias.ID,
+			Name:      alias.Name,
+			Locale:    alias.Locale,
+			Preferred: alias.Preferred,
+		}
+	}
+	return &dto.IngredientDTO{
+		ID:                   ingredient.ID,
+		Name:                 ingredient.Name,
+		LocalizedName:        localizedName(ingredient, languages),
+		CaloriesPerGram:      ingredient.CaloriesPerGram,
+		ProteinPerGram:       ingredient.ProteinPerGram,
+		FatPerGram:           ingredient.FatPerGram,
+		CarbohydratesPerGram: ingredient.CarbohydratesPerGram,
+		FiberPerGram:         ingredient.FiberPerGram,
+		SugarPerGram:         ingredient.SugarPerGram,
+		SodiumPerGram:        ingredient.SodiumPerGram,
+		Density:              ingredient.Density,
+		PieceWeight:          ingredient.PieceWeight,
+		YieldFactor:          ingredient.YieldFactor,
+		Allergens:            append([]string{}, ingredient.Allergens...),
+		AllergensTagged:      ingredient.AllergensTagged,
+		DietTags:             append([]string{}, ingredient.DietTags...),
+		Source:               ingredient.Source,
+		SourceID:             ingredient.SourceID,
+		Aliases:              aliases,
+	}
+}
+func mapRecalculationToDTO(recalculation *models.RecipeRecalculation) *dto.RecipeRecalculationDTO {
+	changes := make([]dto.RecipeRecalculationChangeDTO, len(recalculation.Changes))
+	for i, change := range recalculation.Changes {
+		changes[i] = dto.RecipeRecalculationChangeDTO{
+			RecipeID:         change.RecipeID,
+			RecipeName:       change.RecipeName,
+			PreviousCalories: change.PreviousCalories,
+			Calories:         change.Calories,
+			PreviousWeight:   change.PreviousWeight,
+			Weight:           change.Weight,
+			Version:          change.Version,
+			Error:            change.Error,
+		}
+	}
+	return &dto.RecipeRecalculationDTO{
+		ID:           recalculation.ID,
+		IngredientID: recalculation.IngredientID,
+		Status:       recalculation.Status,
+		Error:        recalculation.Error,
+		CreatedAt:    recalculation.CreatedAt,
+		FinishedAt:   recalculation.FinishedAt,
+		Changes:      changes,
+	}
+}
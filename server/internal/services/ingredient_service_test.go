@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fakeRecipeRepository struct {
+	repositories.RecipeRepository
+	recipes []*models.Recipe
+	updated []*models.Recipe
+}
+
+func (r *fakeRecipeRepository) GetRecipesUsingIngredient(ctx context.Context, ingredientID uuid.UUID) ([]*models.Recipe, error) {
+	return r.recipes, nil
+}
+
+type fakeRecalculationRepository struct {
+	repositories.RecipeRecalculationRepository
+	recalculations []*models.RecipeRecalculation
+	saved          []*models.Recipe
+}
+
+func (r *fakeRecalculationRepository) GetPendingRecalculations(ctx context.Context, staleBefore time.Time) ([]*models.RecipeRecalculation, error) {
+	return r.recalculations, nil
+}
+
+func (r *fakeRecalculationRepository) ClaimRecalculation(ctx context.Context, recalculation *models.RecipeRecalculation, staleBefore time.Time) (bool, error) {
+	stale := recalculation.StartedAt == nil || recalculation.StartedAt.Before(staleBefore)
+	if recalculation.Status != models.RecalculationPending && !(recalculation.Status == models.RecalculationRunning && stale) {
+		return false, nil
+	}
+	now := time.Now()
+	recalculation.Status = models.RecalculationRunning
+	recalculation.StartedAt = &now
+	return true, nil
+}
+
+func (r *fakeRecalculationRepository) FinishRecalculation(ctx context.Context, recalculation *models.RecipeRecalculation, recipes []*models.Recipe) error {
+	r.saved = append(r.saved, recipes...)
+	return nil
+}
+
+func TestProcessRecalculationsContinuesAfterRecipeFailure(t *testing.T) {
+	// yield factor dropped to zero, a recipe made only of the ingredient has no cooked weight left
+	oil := models.Ingredient{CaloriesPerGram: 9, YieldFactor: 0}
+	oil.ID = uuid.New()
+	flour := models.Ingredient{CaloriesPerGram: 3.64, YieldFactor: 1}
+	flour.ID = uuid.New()
+	fried := &models.Recipe{Name: "Oil", Calories: 900, Weight: 100, RawWeight: 100, IngredientUsages: []models.RecipeIngredientUsage{
+		{IngredientID: oil.ID, Ingredient: oil, Weight: 100, YieldFactor: 1},
+	}}
+	dough := &models.Recipe{Name: "Dough", Calories: 1178, Weight: 200, RawWeight: 200, IngredientUsages: []models.RecipeIngredientUsage{
+		{IngredientID: oil.ID, Ingredient: oil, Weight: 50, YieldFactor: 1},
+		{IngredientID: flour.ID, Ingredient: flour, Weight: 200, YieldFactor: 1},
+	}}
+	started := time.Now().Add(-time.Hour)
+	recalculation := &models.RecipeRecalculation{IngredientID: oil.ID, Status: models.RecalculationRunning, StartedAt: &started, PreviousYieldFactor: 1}
+	recalculations := &fakeRecalculationRepository{recalculations: []*models.RecipeRecalculation{recalculation}}
+	service := NewIngredientService(nil, &fakeRecipeRepository{recipes: []*models.Recipe{fried, dough}}, recalculations, config.CatalogConfig{RecalculationLease: 10 * time.Minute})
+
+	if err := service.ProcessRecalculations(context.Background()); err != nil {
+		t.Fatalf("ProcessRecalculations: %v", err)
+	}
+	if recalculation.Status != models.RecalculationCompleted || recalculation.Error == "" {
+		t.Fatalf("recalculation = %s %q, want completed with error summary", recalculation.Status, recalculation.Error)
+	}
+	if len(recalculation.Changes) != 2 || recalculation.Changes[0].Error == "" || recalculation.Changes[1].Error != "" {
+		t.Fatalf("changes = %+v, want failed Oil and recalculated Dough", recalculation.Changes)
+	}
+	if len(recalculations.saved) != 1 || recalculations.saved[0] != dough || dough.Weight != 200 || dough.RawWeight != 250 {
+		t.Fatalf("saved = %+v, want only Dough with 250 g raw and 200 g cooked", recalculations.saved)
+	}
+}
+
+func (r *fakeIngredientRepository) GetIngredientByID(ctx context.Context, id uuid.UUID) (*models.Ingredient, error) {
+	for _, ingredient := range r.ingredients {
+		if ingredient.ID == id {
+			return ingredient, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeIngredientRepository) GetIngredientsByNames(ctx context.Context, names []string) ([]*models.Ingredient, error) {
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+	return r.GetIngredientsByNamesIgnoreCase(ctx, lower)
+}
+
+func TestAddIngredientAliasIgnoresCase(t *testing.T) {
+	egg := &models.Ingredient{Name: "egg", Aliases: []models.IngredientAlias{{Name: "jajo", Locale: "pl"}}}
+	egg.ID = uuid.New()
+	milk := &models.Ingredient{Name: "milk"}
+	milk.ID = uuid.New()
+	service := NewIngredientService(&fakeIngredientRepository{ingredients: []*models.Ingredient{egg, milk}}, nil, nil, config.CatalogConfig{})
+	ctx := context.Background()
+
+	if _, err := service.AddIngredientAlias(ctx, egg.ID, dto.IngredientAliasRequestDTO{Name: "Jajo", Locale: "pl"}); !errors.Is(err, ErrAliasTaken) {
+		t.Fatalf("alias differing in case: err = %v, want ErrAliasTaken", err)
+	}
+	if _, err := service.AddIngredientAlias(ctx, milk.ID, dto.IngredientAliasRequestDTO{Name: "JAJO", Locale: "cs"}); !errors.Is(err, ErrAliasTaken) {
+		t.Fatalf("alias of another ingredient: err = %v, want ErrAliasTaken", err)
+	}
+}
+
+func (r *fakeIngredientRepository) UpdateIngredient(ctx context.Context, ingredient *models.Ingredient, recalculation *models.RecipeRecalculation) error {
+	r.updates = append(r.updates, repositories.IngredientUpdate{Ingredient: ingredient, Recalculation: recalculation})
+	return nil
+}
+
+func TestNutrientsAreResetOnReplaceAndKeptOnPatch(t *testing.T) {
+	oats := &models.Ingredient{Name: "oats", CaloriesPerGram: 3.8, ProteinPerGram: 0.13, FatPerGram: 0.07, YieldFactor: 1}
+	oats.ID = uuid.New()
+	repo := &fakeIngredientRepository{ingredients: []*models.Ingredient{oats}}
+	service := NewIngredientService(repo, nil, nil, config.CatalogConfig{})
+	ctx := context.Background()
+
+	fiber := 0.1
+	patched, err := service.PatchIngredient(ctx, oats.ID, dto.PatchIngredientRequestDTO{FiberPerGram: &fiber})
+	if err != nil {
+		t.Fatalf("PatchIngredient: %v", err)
+	}
+	if got := patched.Ingredient; got.ProteinPerGram != 0.13 || got.FatPerGram != 0.07 || got.FiberPerGram != 0.1 {
+		t.Fatalf("patched = %+v, want protein and fat kept and fiber set", got)
+	}
+	if patched.Recalculation != nil {
+		t.Fatal("nutrients other than calories must not recalculate recipes")
+	}
+
+	replaced, err := service.ReplaceIngredient(ctx, oats.ID, dto.CreateIngredientRequestDTO{Name: "oats", CaloriesPerGram: 3.8, SugarPerGram: 0.01})
+	if err != nil {
+		t.Fatalf("ReplaceIngredient: %v", err)
+	}
+	if got := replaced.Ingredient; got.ProteinPerGram != 0 || got.FatPerGram != 0 || got.FiberPerGram != 0 || got.SugarPerGram != 0.01 {
+		t.Fatalf("replaced = %+v, want only sugar set", got)
+	}
+
+	negative := -1.0
+	if _, err := service.PatchIngredient(ctx, oats.ID, dto.PatchIngredientRequestDTO{SodiumPerGram: &negative}); !errors.Is(err, ErrInvalidIngredient) {
+		t.Fatalf("negative sodium: err = %v, want ErrInvalidIngredient", err)
+	}
+}
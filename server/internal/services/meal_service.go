@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"foodgenie/internal/ai"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/locale"
 	"foodgenie/internal/models"
 	"foodgenie/internal/repositories"
+	"foodgenie/internal/units"
 	"io"
+	"math"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -15,12 +19,13 @@ import (
 type mealService struct {
 	mealRepo   repositories.MealRepository
 	recipeRepo repositories.RecipeRepository
+	userRepo   repositories.UserRepository
 	aiService  ai.AIService
 }
 
 // creates meal for user
 func (s *mealService) CreateMealForUser(ctx context.Context, req *dto.CreateMealRequestDTO) (*dto.MealDetailResponseDTO, error) {
-	if req.Name == "" || req.Weight == 0 {
+	if req.Name == "" || (req.Weight == 0 && req.Quantity == 0 && req.Servings == 0) {
 		return nil, fmt.Errorf("invalid request body")
 	}
 	// create request dto ---> model
@@ -33,71 +38,188 @@ func (s *mealService) CreateMealForUser(ctx context.Context, req *dto.CreateMeal
 		return nil, fmt.Errorf("failed to create meal %w", err)
 	}
 	// model --> meal detail dto
-	mealDetailDTO := mapMealToDetailDTO(createdMeal)
+	mealDetailDTO := mapMealToDetailDTO(createdMeal, locale.Languages(ctx))
 	return mealDetailDTO, nil
 
 }
 func (s *mealService) ProcessAndLogMealFromImage(ctx context.Context, userID uuid.UUID, image io.Reader) (*dto.MealDetailResponseDTO, error) {
+	// loaded first so a missing user doesn't cost an image analysis
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %w", err)
+	}
 	// sending image to ai for analysis
 	aiAnalysis, err := s.aiService.AnalyzeMealImage(ctx, image)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze meal image %w", err)
 	}
 	// fetching recipe with matching name
-	recipeModel, err := s.recipeRepo.GetRecipeByName(ctx, aiAnalysis.Name)
+	recipeModel, err := s.recipeRepo.GetRecipeByName(ctx, aiAnalysis.Name, &userID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid recipe name %w", err)
 	}
 	weight := (aiAnalysis.Volume / recipeModel.Volume) * float64(recipeModel.Weight)
 	mealToLog := &models.Meal{
-		UserID:   userID,
-		RecipeID: recipeModel.ID,
-		Weight:   uint(weight),
-		Recipe:   *recipeModel,
+		UserID:          userID,
+		RecipeID:        recipeModel.ID,
+		RecipeVersionID: recipeModel.CurrentVersionID,
+		Weight:          uint(weight),
+		Quantity:        float64(uint(weight)),
+		Unit:            string(units.Gram),
+		Recipe:          *recipeModel,
 	}
 	loggedMeal, err := s.mealRepo.CreateMeal(mealToLog)
 	if err != nil {
 		return nil, fmt.Errorf("failed to log meal %w", err)
 	}
-	return mapMealToDetailDTO(loggedMeal), nil
+	// the recipe was recognized from the photo, so the user may not know what is in it
+	mealDetailDTO := mapMealToDetailDTO(loggedMeal, locale.Languages(ctx))
+	mealDetailDTO.AllergyWarnings = allergyWarnings(recipeModel, user.Preferences.Allergies, locale.Languages(ctx))
+	mealDetailDTO.UnverifiedIngredients = unverifiedIngredients(recipeModel, locale.Languages(ctx))
+	return mealDetailDTO, nil
 }
-func mapMealToDetailDTO(meal *models.Meal) *dto.MealDetailResponseDTO {
-	ratio := float64(meal.Weight) / float64(meal.Recipe.Weight)
-	var ingredientDTOS []dto.RecipeIngredientDetailDTO
+
+// recipe data the meal is computed from
+type mealRecipeSnapshot struct {
+	name        string
+	weight      uint
+	calories    uint
+	ingredients []mealIngredientSnapshot
+}
+type mealIngredientSnapshot struct {
+	id              uuid.UUID
+	name            string
+	weight          uint
+	quantity        float64
+	unit            string
+	caloriesPerGram float64
+}
+
+// meals pinned to recipe version use its snapshot, meals without one fall back to the current recipe
+func snapshotMealRecipe(meal *models.Meal, languages []string) mealRecipeSnapshot {
+	if version := meal.RecipeVersion; version != nil {
+		snapshot := mealRecipeSnapshot{name: version.Name, weight: version.Weight, calories: version.Calories}
+		for _, ing := range version.Ingredients {
+			name, ok := preferredAlias(ing.Aliases, languages)
+			if !ok {
+				name = ing.Name
+			}
+			snapshot.ingredients = append(snapshot.ingredients, mealIngredientSnapshot{
+				id:              ing.IngredientID,
+				name:            name,
+				weight:          ing.Weight,
+				quantity:        ing.Quantity,
+				unit:            ing.Unit,
+				caloriesPerGram: ing.CaloriesPerGram,
+			})
+		}
+		return snapshot
+	}
+	snapshot := mealRecipeSnapshot{name: meal.Recipe.Name, weight: meal.Recipe.Weight, calories: meal.Recipe.Calories}
 	for _, usage := range meal.Recipe.IngredientUsages {
-		ingWeight := uint(float64(usage.Weight) * ratio)
+		snapshot.ingredients = append(snapshot.ingredients, mealIngredientSnapshot{
+			id:              usage.Ingredient.ID,
+			name:            localizedName(&usage.Ingredient, languages),
+			weight:          usage.Weight,
+			quantity:        usage.Quantity,
+			unit:            usage.Unit,
+			caloriesPerGram: usage.Ingredient.CaloriesPerGram,
+		})
+	}
+	return snapshot
+}
+
+// converts number of servings, named portions and units of measure to grams,
+// returns quantity and unit to store for display
+func mealQuantity(req *dto.CreateMealRequestDTO, recipe *models.Recipe) (uint, float64, string, error) {
+	servings, unit := req.Servings, strings.ToLower(strings.TrimSpace(req.Unit))
+	if servings == 0 && isServingUnit(unit) {
+		servings = req.Quantity
+	}
+	if servings > 0 {
+		return portionQuantity(servings, recipeServingWeight(recipe), servingUnit)
+	}
+	for _, portion := range recipe.Portions {
+		if portion.Name == unit {
+			return portionQuantity(req.Quantity, float64(portion.Weight), portion.Name)
+		}
+	}
+	weight, quantity, parsedUnit, err := normalizeQuantity(req.Weight, req.Quantity, req.Unit, recipeConversion(recipe))
+	return weight, quantity, string(parsedUnit), err
+}
+func portionQuantity(count float64, portionWeight float64, unit string) (uint, float64, string, error) {
+	weight := uint(math.Round(count * portionWeight))
+	if count <= 0 || weight == 0 {
+		return 0, 0, "", ErrInvalidQuantity
+	}
+	return weight, count, unit, nil
+}
+
+// recipe volume is in litres, dishes without volume can be logged only by mass
+func recipeConversion(recipe *models.Recipe) units.Conversion {
+	var conversion units.Conversion
+	if recipe.Volume > 0 {
+		conversion.Density = float64(recipe.Weight) / (recipe.Volume * 1000)
+	}
+	return conversion
+}
+
+// portion of the whole recipe eaten in the meal
+func (r mealRecipeSnapshot) ratio(mealWeight uint) float64 {
+	if r.weight == 0 {
+		return 0
+	}
+	return float64(mealWeight) / float64(r.weight)
+}
+func mapMealToDetailDTO(meal *models.Meal, languages []string) *dto.MealDetailResponseDTO {
+	recipe := snapshotMealRecipe(meal, languages)
+	ratio := recipe.ratio(meal.Weight)
+	var ingredientDTOS []dto.RecipeIngredientDetailDTO
+	for _, ing := range recipe.ingredients {
+		ingWeight := uint(float64(ing.weight) * ratio)
+		quantity, unit := displayQuantity(ing.weight, ing.quantity, ing.unit)
 		ingDTO := dto.RecipeIngredientDetailDTO{
-			ID:       usage.Ingredient.ID,
-			Name:     usage.Ingredient.Name,
+			ID:       ing.id,
+			Name:     ing.name,
 			Weight:   ingWeight,
-			Calories: uint(float64(ingWeight) * usage.Ingredient.CaloriesPerGram),
+			Quantity: quantity * ratio,
+			Unit:     unit,
+			Calories: uint(float64(ingWeight) * ing.caloriesPerGram),
 		}
 		ingredientDTOS = append(ingredientDTOS, ingDTO)
 	}
 	mealDTO := &dto.MealDetailResponseDTO{
 		ID:            meal.ID,
-		Name:          meal.Recipe.Name,
+		Name:          recipe.name,
 		Ingredients:   ingredientDTOS,
 		TotalWeight:   meal.Weight,
-		TotalCalories: uint(float64(meal.Recipe.Calories) * ratio),
+		TotalCalories: uint(float64(recipe.calories) * ratio),
 		CreatedAt:     meal.CreatedAt,
 	}
+	mealDTO.Quantity, mealDTO.Unit = displayQuantity(meal.Weight, meal.Quantity, meal.Unit)
 	return mealDTO
 }
 
 func (s *mealService) buildMealFromDTO(ctx context.Context, mealDTO *dto.CreateMealRequestDTO) (*models.Meal, error) {
-	if mealDTO.Name == "" || mealDTO.Weight == 0 {
+	if mealDTO.Name == "" || (mealDTO.Weight == 0 && mealDTO.Quantity == 0 && mealDTO.Servings == 0) {
 		return nil, fmt.Errorf("wrong meal data")
 	}
-	recipe, err := s.recipeRepo.GetRecipeByName(ctx, mealDTO.Name)
+	recipe, err := s.recipeRepo.GetRecipeByName(ctx, mealDTO.Name, &mealDTO.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve recipe %w", err)
 	}
+	weight, quantity, unit, err := mealQuantity(mealDTO, recipe)
+	if err != nil {
+		return nil, fmt.Errorf("invalid meal quantity %w", err)
+	}
 	mealModel := &models.Meal{
-		UserID:   mealDTO.UserID,
-		RecipeID: recipe.ID,
-		Recipe:   *recipe,
-		Weight:   mealDTO.Weight,
+		UserID:          mealDTO.UserID,
+		RecipeID:        recipe.ID,
+		RecipeVersionID: recipe.CurrentVersionID,
+		Recipe:          *recipe,
+		Weight:          weight,
+		Quantity:        quantity,
+		Unit:            unit,
 	}
 	return mealModel, nil
 }
@@ -108,12 +230,12 @@ func (s *mealService) GetMealsForUser(ctx context.Context, userID uuid.UUID, pag
 	}
 	meals := make([]*dto.MealResponseDTO, len(mealModels))
 	for i, meal := range mealModels {
-		ratio := float64(meal.Weight) / float64(meal.Recipe.Weight)
-		totalCalories := uint(float64(meal.Recipe.Calories) * ratio)
+		recipe := snapshotMealRecipe(meal, nil)
+		totalCalories := uint(float64(recipe.calories) * recipe.ratio(meal.Weight))
 
 		meals[i] = &dto.MealResponseDTO{
 			ID:            meal.ID,
-			Name:          meal.Recipe.Name,
+			Name:          recipe.name,
 			TotalWeight:   meal.Weight,
 			TotalCalories: totalCalories,
 			CreatedAt:     meal.CreatedAt,
@@ -128,7 +250,7 @@ func (s *mealService) GetMealDetails(ctx context.Context, userID uuid.UUID, meal
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch meal: %w", err)
 	}
-	mealDetailDTO := mapMealToDetailDTO(mealModel)
+	mealDetailDTO := mapMealToDetailDTO(mealModel, locale.Languages(ctx))
 	return mealDetailDTO, nil
 }
 func (s *mealService) DeleteMealByID(ctx context.Context, userID uuid.UUID, mealID uuid.UUID) error {
@@ -155,10 +277,11 @@ type MealService interface {
 	GetMealCountForUser(ctx context.Context, userID uuid.UUID) (int64, error)
 }
 
-func NewMealService(mealRepo repositories.MealRepository, recipeRepo repositories.RecipeRepository, aiService ai.AIService) MealService {
+func NewMealService(mealRepo repositories.MealRepository, recipeRepo repositories.RecipeRepository, userRepo repositories.UserRepository, aiService ai.AIService) MealService {
 	return &mealService{
 		mealRepo:   mealRepo,
 		recipeRepo: recipeRepo,
+		userRepo:   userRepo,
 		aiService:  aiService,
 	}
 }
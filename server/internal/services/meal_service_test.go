@@ -0,0 +1,25 @@
+package services
+
+import (
+	"foodgenie/internal/models"
+	"testing"
+)
+
+func TestMealAndVersionDetailsUseCallerLanguage(t *testing.T) {
+	aliases := []models.IngredientAlias{{Name: "jajko", Locale: "pl", Preferred: true}}
+	version := &models.RecipeVersion{Name: "omelette", Weight: 100, Calories: 150, Ingredients: []models.RecipeVersionIngredient{
+		{Name: "egg", Weight: 100, CaloriesPerGram: 1.5, Aliases: aliases},
+	}}
+	meal := &models.Meal{Weight: 50, RecipeVersion: version}
+
+	if name := mapMealToDetailDTO(meal, []string{"pl-pl", "pl"}).Ingredients[0].Name; name != "jajko" {
+		t.Errorf("meal ingredient = %q, want jajko", name)
+	}
+	if name := mapRecipeVersionToDTO(version, []string{"pl"}).Ingredients[0].Name; name != "jajko" {
+		t.Errorf("version ingredient = %q, want jajko", name)
+	}
+	// the snapshot keeps the name of the time it was taken
+	if name := mapRecipeVersionToDTO(version, []string{"de"}).Ingredients[0].Name; name != "egg" {
+		t.Errorf("version ingredient without alias = %q, want egg", name)
+	}
+}
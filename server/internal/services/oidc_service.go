@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/oidc"
+	"foodgenie/internal/repositories"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown sign-in provider")
+	ErrInvalidOIDCState    = errors.New("invalid or expired sign-in state")
+	ErrOIDCEmailConflict   = errors.New("account with this email already exists and the provider did not verify the email, sign in with password instead")
+)
+
+type OIDCService interface {
+	StartLogin(ctx context.Context, provider string) (*dto.OIDCAuthorizationResponseDTO, error)
+	CompleteLogin(ctx context.Context, provider string, req *dto.OIDCCallbackRequestDTO) (*models.User, error)
+}
+type oidcService struct {
+	providers            map[string]*oidc.Provider
+	userRepo             repositories.UserRepository
+	externalIdentityRepo repositories.ExternalIdentityRepository
+	securityService      SecurityService
+	stateDuration        time.Duration
+}
+
+func NewOIDCService(cfg config.OIDCConfig, userRepo repositories.UserRepository, externalIdentityRepo repositories.ExternalIdentityRepository, securityService SecurityService) OIDCService {
+	providers := make(map[string]*oidc.Provider)
+	for name, providerCfg := range cfg.Providers {
+		providers[name] = oidc.NewProvider(oidc.ProviderConfig{
+			IssuerURL:    providerCfg.IssuerURL,
+			ClientID:     providerCfg.ClientID,
+			ClientSecret: providerCfg.ClientSecret,
+			RedirectURL:  providerCfg.RedirectURL,
+			Scopes:       providerCfg.Scopes,
+			AuthParams:   providerCfg.AuthParams,
+		}, nil)
+	}
+	return &oidcService{
+		providers:            providers,
+		userRepo:             userRepo,
+		externalIdentityRepo: externalIdentityRepo,
+		securityService:      securityService,
+		stateDuration:        cfg.StateDuration,
+	}
+}
+
+// stores state, nonce and PKCE verifier and returns provider authorization URL
+func (s *oidcService) StartLogin(ctx context.Context, provider string) (*dto.OIDCAuthorizationResponseDTO, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	loginState := &models.OIDCLoginState{
+		Provider:  provider,
+		ExpiresAt: time.Now().Add(s.stateDuration),
+	}
+	for _, value := range []*string{&loginState.State, &loginState.Nonce, &loginState.CodeVerifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			return nil, err
+		}
+		*value = random
+	}
+	authURL, err := p.AuthCodeURL(ctx, loginState.State, loginState.Nonce, loginState.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authorization url %w", err)
+	}
+	if err := s.externalIdentityRepo.CreateLoginState(ctx, loginState); err != nil {
+		return nil, fmt.Errorf("failed to store login state %w", err)
+	}
+	return &dto.OIDCAuthorizationResponseDTO{
+		AuthorizationURL: authURL,
+		State:            loginState.State,
+	}, nil
+}
+
+// exchanges code, then finds linked user, links by verified email or creates new user
+func (s *oidcService) CompleteLogin(ctx context.Context, provider string, req *dto.OIDCCallbackRequestDTO) (*models.User, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	if req.Error != "" {
+		return nil, fmt.Errorf("provider returned error: %s", req.Error)
+	}
+	if req.Code == "" || req.State == "" {
+		return nil, ErrInvalidOIDCState
+	}
+	loginState, err := s.externalIdentityRepo.ConsumeLoginState(ctx, provider, req.State)
+	if err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+	claims, err := p.Exchange(ctx, req.Code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code %w", err)
+	}
+	identity, err := s.externalIdentityRepo.GetExternalIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		return &identity.User, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch identity %w", err)
+	}
+	user, err := s.findOrCreateUser(claims)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.externalIdentityRepo.CreateExternalIdentity(ctx, &models.ExternalIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity %w", err)
+	}
+	return user, nil
+}
+func (s *oidcService) findOrCreateUser(claims *oidc.Claims) (*models.User, error) {
+	if claims.Email == "" {
+		return nil, errors.New("provider did not return an email address")
+	}
+	existing, err := s.userRepo.GetUserByEmail(claims.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %w", err)
+	}
+	if existing.ID != uuid.Nil {
+		// unverified email could be used to take over an existing account
+		if !claims.EmailVerified {
+			return nil, ErrOIDCEmailConflict
+		}
+		return &existing, nil
+	}
+	username, err := s.generateUsername(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	// random password nobody knows, the user sets one with ChangePassword after a recent login
+	randomPassword, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.securityService.GenerateHashFromPassword(randomPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate hash from password: %w", err)
+	}
+	user, err := s.userRepo.CreateUser(&models.User{
+		Username:    username,
+		Email:       claims.Email,
+		Password:    hashedPassword,
+		HasPassword: false,
+		FirstName:   claims.GivenName,
+		LastName:    claims.FamilyName,
+		Role:        models.RoleUser,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user %w", err)
+	}
+	return user, nil
+}
+
+// derives unique username from email local part, usernames are limited to 20 characters
+func (s *oidcService) generateUsername(email string) (string, error) {
+	base := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '.' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(strings.SplitN(email, "@", 2)[0]))
+	if len(base) > 13 {
+		base = base[:13]
+	}
+	if base == "" {
+		base = "user"
+	}
+	for i := 0; i < 5; i++ {
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		username := base + "_" + hex.EncodeToString(suffix)
+		if _, err := s.userRepo.GetUserByUsername(username); err != nil {
+			return username, nil
+		}
+	}
+	return "", errors.New("failed to generate unique username")
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/oidc/oidctest"
+	"foodgenie/internal/repositories"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// in-memory users, methods the sign-in flow doesn't use panic through the nil interface
+type fakeUserRepository struct {
+	repositories.UserRepository
+	users []*models.User
+}
+
+func (r *fakeUserRepository) CreateUser(user *models.User) (*models.User, error) {
+	user.ID = uuid.New()
+	r.users = append(r.users, user)
+	return user, nil
+}
+
+func (r *fakeUserRepository) GetUserByEmail(email string) (models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return *user, nil
+		}
+	}
+	return models.User{}, nil
+}
+
+func (r *fakeUserRepository) GetUserByUsername(username string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakeExternalIdentityRepository struct {
+	repositories.ExternalIdentityRepository
+	users      *fakeUserRepository
+	states     map[string]*models.OIDCLoginState
+	identities []*models.ExternalIdentity
+}
+
+func (r *fakeExternalIdentityRepository) CreateLoginState(ctx context.Context, state *models.OIDCLoginState) error {
+	r.states[state.State] = state
+	return nil
+}
+
+func (r *fakeExternalIdentityRepository) ConsumeLoginState(ctx context.Context, provider string, state string) (*models.OIDCLoginState, error) {
+	loginState, ok := r.states[state]
+	delete(r.states, state)
+	if !ok || loginState.Provider != provider || loginState.ExpiresAt.Before(time.Now()) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return loginState, nil
+}
+
+func (r *fakeExternalIdentityRepository) CreateExternalIdentity(ctx context.Context, identity *models.ExternalIdentity) (*models.ExternalIdentity, error) {
+	r.identities = append(r.identities, identity)
+	return identity, nil
+}
+
+func (r *fakeExternalIdentityRepository) GetExternalIdentity(ctx context.Context, provider string, subject string) (*models.ExternalIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider != provider || identity.Subject != subject {
+			continue
+		}
+		for _, user := range r.users.users {
+			if user.ID == identity.UserID {
+				identity.User = *user
+			}
+		}
+		return identity, nil
+	}
+	return nil, fmt.Errorf("external identity not found %w", gorm.ErrRecordNotFound)
+}
+
+type oidcTest struct {
+	issuer     *oidctest.Issuer
+	users      *fakeUserRepository
+	identities *fakeExternalIdentityRepository
+	service    OIDCService
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	issuer := oidctest.NewIssuer(t, "foodgenie")
+	users := &fakeUserRepository{}
+	identities := &fakeExternalIdentityRepository{users: users, states: make(map[string]*models.OIDCLoginState)}
+	service := NewOIDCService(config.OIDCConfig{
+		Providers: map[string]config.OIDCProviderConfig{
+			"test": {IssuerURL: issuer.URL, ClientID: issuer.ClientID, RedirectURL: "https://app.example.com/callback"},
+		},
+		StateDuration: 10 * time.Minute,
+	}, users, identities, NewSecurityService(config.JWTConfig{}, config.TwoFactorConfig{}))
+	return &oidcTest{issuer: issuer, users: users, identities: identities, service: service}
+}
+
+// starts login and lets the issuer approve it, returns the callback request
+func (o *oidcTest) authorize(t *testing.T) *dto.OIDCCallbackRequestDTO {
+	t.Helper()
+	start, err := o.service.StartLogin(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	authURL, err := url.Parse(start.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authURL.Query().Get("state") != start.State {
+		t.Fatalf("authorization url does not carry the state")
+	}
+	return &dto.OIDCCallbackRequestDTO{Code: o.issuer.Authorize(t, start.AuthorizationURL), State: start.State}
+}
+
+func TestOIDCCompleteLoginCreatesAndLinksUser(t *testing.T) {
+	o := newOIDCTest(t)
+
+	user, err := o.service.CompleteLogin(context.Background(), "test", o.authorize(t))
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if user.Email != "anna@example.com" || user.FirstName != "Anna" || user.Role != models.RoleUser {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != user.ID || o.identities.identities[0].Subject != "subject-1" {
+		t.Fatalf("identity was not linked, got %+v", o.identities.identities)
+	}
+
+	again, err := o.service.CompleteLogin(context.Background(), "test", o.authorize(t))
+	if err != nil {
+		t.Fatalf("second CompleteLogin: %v", err)
+	}
+	if again.ID != user.ID || len(o.users.users) != 1 {
+		t.Fatalf("second sign-in should return the linked user, got %+v of %d users", again, len(o.users.users))
+	}
+}
+
+func TestOIDCCompleteLoginLinksVerifiedEmail(t *testing.T) {
+	o := newOIDCTest(t)
+	existing, _ := o.users.CreateUser(&models.User{Username: "anna", Email: "anna@example.com"})
+
+	user, err := o.service.CompleteLogin(context.Background(), "test", o.authorize(t))
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("verified email should sign in to the existing account")
+	}
+}
+
+func TestOIDCCompleteLoginRejectsUnverifiedEmailOfExistingAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	o.users.CreateUser(&models.User{Username: "anna", Email: "anna@example.com"})
+	o.issuer.Claims["email_verified"] = false
+
+	_, err := o.service.CompleteLogin(context.Background(), "test", o.authorize(t))
+	if !errors.Is(err, ErrOIDCEmailConflict) {
+		t.Fatalf("err = %v, want ErrOIDCEmailConflict", err)
+	}
+	if len(o.identities.identities) != 0 {
+		t.Fatal("identity should not be linked")
+	}
+}
+
+func TestOIDCCompleteLoginRejectsInvalidState(t *testing.T) {
+	o := newOIDCTest(t)
+	req := o.authorize(t)
+
+	for name, state := range map[string]string{"unknown": "other-state", "empty": ""} {
+		_, err := o.service.CompleteLogin(context.Background(), "test", &dto.OIDCCallbackRequestDTO{Code: req.Code, State: state})
+		if !errors.Is(err, ErrInvalidOIDCState) {
+			t.Errorf("%s state: err = %v, want ErrInvalidOIDCState", name, err)
+		}
+	}
+	if _, err := o.service.CompleteLogin(context.Background(), "test", req); err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	// state is single use
+	if _, err := o.service.CompleteLogin(context.Background(), "test", req); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("replayed state: err = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCCompleteLoginRejectsInvalidIDToken(t *testing.T) {
+	for name, claims := range map[string]map[string]any{
+		"nonce mismatch": {"nonce": "other-nonce"},
+		"expired":        {"exp": time.Now().Add(-time.Minute).Unix()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			o := newOIDCTest(t)
+			for key, value := range claims {
+				o.issuer.Claims[key] = value
+			}
+
+			if _, err := o.service.CompleteLogin(context.Background(), "test", o.authorize(t)); err == nil {
+				t.Fatal("expected error")
+			}
+			if len(o.users.users) != 0 || len(o.identities.identities) != 0 {
+				t.Fatal("no user or identity should be created")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrReauthenticationRequired = errors.New("sign in again to confirm this change")
+
+// confirms identity of the signed in user before sensitive account changes, accounts created by signing in
+// with a provider have no password and must have signed in recently instead, through the provider or otherwise
+type identityVerifier struct {
+	securityService SecurityService
+	sessionRepo     repositories.SessionRepository
+	window          time.Duration
+}
+
+func (v identityVerifier) verify(ctx context.Context, user *models.User, password string, sessionID uuid.UUID) error {
+	if !user.HasPassword {
+		return v.verifyRecentLogin(ctx, user, sessionID)
+	}
+	if err := v.securityService.ComparePasswordAndHash(password, user.Password); err != nil {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// sessions are created on login and only extended by refresh, so their creation is the time of the last login
+func (v identityVerifier) verifyRecentLogin(ctx context.Context, user *models.User, sessionID uuid.UUID) error {
+	if sessionID == uuid.Nil {
+		return ErrReauthenticationRequired
+	}
+	session, err := v.sessionRepo.GetActiveSession(ctx, sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrReauthenticationRequired
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check session %w", err)
+	}
+	if session.UserID != user.ID || time.Since(session.CreatedAt) > v.window {
+		return ErrReauthenticationRequired
+	}
+	return nil
+}
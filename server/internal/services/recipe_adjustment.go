@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/locale"
+	"foodgenie/internal/models"
+	"foodgenie/internal/units"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidScaleTarget    = errors.New("exactly one of weight, servings or calories is required and the recipe must have it")
+	ErrIngredientNotInRecipe = errors.New("ingredient is not part of the recipe")
+	ErrInvalidSubstitution   = errors.New("ingredient can't be substituted by itself")
+	ErrRecipeNotOwned        = errors.New("only own recipes can be saved")
+)
+
+// resizes recipe to target weight, servings or calories, the recipe is stored only when asked
+func (s *recipeService) ScaleRecipe(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID, manageCatalog bool, req *dto.ScaleRecipeRequestDTO) (*dto.AdjustedRecipeResponseDTO, error) {
+	recipe, err := s.getAdjustableRecipe(ctx, id, viewerID, manageCatalog, req.Save)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := recipeScaleFactor(recipe, req)
+	if err != nil {
+		return nil, err
+	}
+	scaled := scaleRecipe(recipe, factor)
+	if req.Servings > 0 {
+		scaled.Servings = req.Servings
+	}
+	if err := applyRecipeTotals(scaled); err != nil {
+		return nil, err
+	}
+	response, err := s.finishAdjustment(ctx, recipe, scaled, req.Save)
+	if err != nil {
+		return nil, err
+	}
+	response.Factor = factor
+	return response, nil
+}
+
+// swaps one ingredient of the recipe for another and returns the nutrition difference
+func (s *recipeService) SubstituteIngredient(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID, manageCatalog bool, req *dto.SubstituteIngredientRequestDTO) (*dto.AdjustedRecipeResponseDTO, error) {
+	recipe, err := s.getAdjustableRecipe(ctx, id, viewerID, manageCatalog, req.Save)
+	if err != nil {
+		return nil, err
+	}
+	index := slices.IndexFunc(recipe.IngredientUsages, func(usage models.RecipeIngredientUsage) bool {
+		return ingredientHasName(&usage.Ingredient, req.From)
+	})
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrIngredientNotInRecipe, req.From)
+	}
+	substitutes, err := s.ingredientRepo.GetIngredientsByNames(ctx, []string{req.To})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredients %w", err)
+	}
+	substitute, ok := matchIngredientNames(substitutes, []string{req.To})[req.To]
+	if !ok {
+		return nil, &UnknownIngredientsError{Names: []string{req.To}}
+	}
+	if substitute.ID == recipe.IngredientUsages[index].IngredientID {
+		return nil, ErrInvalidSubstitution
+	}
+	replacement := substituteUsage(recipe.IngredientUsages[index], substitute, req.Weight)
+	adjusted := *recipe
+	adjusted.IngredientUsages = slices.Delete(slices.Clone(recipe.IngredientUsages), index, index+1)
+	// substitute already in the recipe gets the replaced amount added
+	existing := slices.IndexFunc(adjusted.IngredientUsages, func(usage models.RecipeIngredientUsage) bool {
+		return usage.IngredientID == substitute.ID
+	})
+	if existing >= 0 {
+		mergeIngredientUsage(&adjusted.IngredientUsages[existing], replacement.Weight, replacement.Quantity, units.Unit(replacement.Unit))
+	} else {
+		adjusted.IngredientUsages = slices.Insert(adjusted.IngredientUsages, index, replacement)
+	}
+	if err := applyRecipeTotals(&adjusted); err != nil {
+		return nil, err
+	}
+	return s.finishAdjustment(ctx, recipe, &adjusted, req.Save)
+}
+
+// any visible recipe can be adjusted, own recipes can be saved and catalog recipes by catalog managers
+func (s *recipeService) getAdjustableRecipe(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID, manageCatalog bool, save bool) (*models.Recipe, error) {
+	recipe, err := s.recipeRepo.GetRecipeByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching recipe %w", err)
+	}
+	if !canViewRecipe(recipe, viewerID) {
+		return nil, ErrRecipeNotFound
+	}
+	if !save {
+		return recipe, nil
+	}
+	scope := viewerID
+	if recipe.OwnerID == nil && manageCatalog {
+		scope = nil
+	}
+	if viewerID == nil || !canManageRecipe(recipe, scope) {
+		return nil, ErrRecipeNotOwned
+	}
+	return recipe, nil
+}
+
+// stores adjusted recipe as new version when asked and compares it with the original,
+// it is saved like an edited recipe so the same validation applies
+func (s *recipeService) finishAdjustment(ctx context.Context, original *models.Recipe, adjusted *models.Recipe, save bool) (*dto.AdjustedRecipeResponseDTO, error) {
+	before := mapRecipeToDTO(original, locale.Languages(ctx))
+	after := mapRecipeToDTO(adjusted, locale.Languages(ctx))
+	if save {
+		saved, err := s.saveRecipe(ctx, original, recipeToRequestDTO(adjusted))
+		if err != nil {
+			return nil, err
+		}
+		after = saved
+	}
+	return &dto.AdjustedRecipeResponseDTO{
+		Recipe:  after,
+		Changes: diffRecipeVersions(recipeSnapshot(before), recipeSnapshot(after)),
+		Saved:   save,
+	}, nil
+}
+func recipeScaleFactor(recipe *models.Recipe, req *dto.ScaleRecipeRequestDTO) (float64, error) {
+	var target, current uint
+	targets := 0
+	if req.Weight > 0 {
+		target, current = req.Weight, recipe.Weight
+		targets++
+	}
+	if req.Servings > 0 {
+		target, current = req.Servings, recipe.Servings
+		targets++
+	}
+	if req.Calories > 0 {
+		target, current = req.Calories, recipe.Calories
+		targets++
+	}
+	if targets != 1 || current == 0 {
+		return 0, ErrInvalidScaleTarget
+	}
+	return float64(target) / float64(current), nil
+}
+
+// copies recipe with ingredient amounts multiplied by factor, portions are absolute and stay the same
+func scaleRecipe(recipe *models.Recipe, factor float64) *models.Recipe {
+	scaled := *recipe
+	scaled.IngredientUsages = make([]models.RecipeIngredientUsage, len(recipe.IngredientUsages))
+	for i, usage := range recipe.IngredientUsages {
+		usage.Weight = max(1, uint(math.Round(float64(usage.Weight)*factor)))
+		usage.Quantity = math.Round(usage.Quantity*factor*100) / 100
+		scaled.IngredientUsages[i] = usage
+	}
+	scaled.CookedWeight = uint(math.Round(float64(recipe.CookedWeight) * factor))
+	scaled.Volume = recipe.Volume * factor
+	scaled.Portions = slices.Clone(recipe.Portions)
+	return &scaled
+}
+
+// same measure is kept when the substitute can be measured in it, e.g. 2 tbsp of butter become 2 tbsp of olive oil,
+// otherwise the same weight is used
+func substituteUsage(original models.RecipeIngredientUsage, substitute *models.Ingredient, weight uint) models.RecipeIngredientUsage {
+	usage := models.RecipeIngredientUsage{
+		RecipeID:     original.RecipeID,
+		IngredientID: substitute.ID,
+		Ingredient:   *substitute,
+		Weight:       original.Weight,
+		Quantity:     float64(original.Weight),
+		Unit:         string(units.Gram),
+		YieldFactor:  substitute.YieldFactor,
+	}
+	if usage.YieldFactor <= 0 {
+		usage.YieldFactor = 1
+	}
+	if weight > 0 {
+		usage.Weight = weight
+		usage.Quantity = float64(weight)
+		return usage
+	}
+	if original.Quantity > 0 && original.Unit != "" {
+		grams, quantity, unit, err := normalizeQuantity(0, original.Quantity, original.Unit, ingredientConversion(substitute))
+		if err == nil {
+			usage.Weight, usage.Quantity, usage.Unit = grams, quantity, string(unit)
+		}
+	}
+	return usage
+}
+
+// recipe in the shape versions are compared in
+func recipeSnapshot(recipe *dto.RecipeDetailResponseDTO) *dto.RecipeVersionDTO {
+	return &dto.RecipeVersionDTO{
+		Version:       recipe.Version,
+		Name:          recipe.Name,
+		Ingredients:   recipe.Ingredients,
+		TotalWeight:   recipe.TotalWeight,
+		RawWeight:     recipe.RawWeight,
+		TotalCalories: recipe.TotalCalories,
+		Volume:        recipe.Volume,
+	}
+}
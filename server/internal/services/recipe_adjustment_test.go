@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *fakeRecipeRepository) GetRecipeByID(ctx context.Context, id uuid.UUID) (*models.Recipe, error) {
+	for _, recipe := range r.recipes {
+		if recipe.ID == id {
+			return recipe, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRecipeRepository) UpdateRecipe(ctx context.Context, recipe *models.Recipe) (*models.Recipe, error) {
+	r.updated = append(r.updated, recipe)
+	return recipe, nil
+}
+
+func TestScaleRecipeSavesCatalogRecipeForCatalogManagers(t *testing.T) {
+	flour := &models.Ingredient{Name: "flour", CaloriesPerGram: 3.64, YieldFactor: 1}
+	flour.ID = uuid.New()
+	recipe := &models.Recipe{Name: "bread", Visibility: models.RecipeVisibilityPublic, Weight: 100, RawWeight: 100, Calories: 364, Servings: 1,
+		IngredientUsages: []models.RecipeIngredientUsage{{IngredientID: flour.ID, Ingredient: *flour, Weight: 100, Quantity: 100, Unit: "g", YieldFactor: 1}}}
+	recipe.ID = uuid.New()
+	recipes := &fakeRecipeRepository{recipes: []*models.Recipe{recipe}}
+	service := NewRecipeService(recipes, &fakeIngredientRepository{ingredients: []*models.Ingredient{flour}})
+	ctx := context.Background()
+	viewerID := uuid.New()
+	req := &dto.ScaleRecipeRequestDTO{Weight: 200, Save: true}
+
+	if _, err := service.ScaleRecipe(ctx, recipe.ID, &viewerID, false, req); !errors.Is(err, ErrRecipeNotOwned) {
+		t.Fatalf("saving catalog recipe as user: err = %v, want ErrRecipeNotOwned", err)
+	}
+	response, err := service.ScaleRecipe(ctx, recipe.ID, &viewerID, true, req)
+	if err != nil {
+		t.Fatalf("ScaleRecipe: %v", err)
+	}
+	if len(recipes.updated) != 1 || recipes.updated[0].OwnerID != nil || recipes.updated[0].Calories != 728 || !response.Saved {
+		t.Fatalf("saved %+v, response %+v", recipes.updated, response)
+	}
+}
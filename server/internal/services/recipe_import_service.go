@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/recipeimport"
+	"foodgenie/internal/repositories"
+	"foodgenie/internal/units"
+	"math"
+	"strings"
+)
+
+const (
+	ImportLineMatched     = "matched"
+	ImportLineEstimated   = "estimated"
+	ImportLineNeedsWeight = "needs_weight"
+	ImportLineUnmatched   = "unmatched"
+)
+
+// volume measures of ingredients without known density are estimated as water
+const fallbackDensity = 1.0
+
+var (
+	ErrImportSourceRequired    = errors.New("url, uploaded page or ingredient text is required")
+	ErrImportSourceUnavailable = errors.New("failed to fetch recipe page")
+)
+
+type RecipeImportService interface {
+	ImportFromURL(ctx context.Context, rawURL string) (*dto.RecipeImportDraftDTO, error)
+	ImportFromHTML(ctx context.Context, page []byte) (*dto.RecipeImportDraftDTO, error)
+	ImportFromText(ctx context.Context, name string, text string) (*dto.RecipeImportDraftDTO, error)
+}
+type recipeImportService struct {
+	ingredientRepo repositories.IngredientRepository
+	fetcher        recipeimport.Fetcher
+}
+
+func NewRecipeImportService(ingredientRepo repositories.IngredientRepository, fetcher recipeimport.Fetcher) RecipeImportService {
+	return &recipeImportService{
+		ingredientRepo: ingredientRepo,
+		fetcher:        fetcher,
+	}
+}
+
+// fetches page and imports schema.org Recipe markup found in it
+func (s *recipeImportService) ImportFromURL(ctx context.Context, rawURL string) (*dto.RecipeImportDraftDTO, error) {
+	page, err := s.fetcher.Fetch(ctx, rawURL)
+	if errors.Is(err, recipeimport.ErrPageTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImportSourceUnavailable, err)
+	}
+	draft, err := s.ImportFromHTML(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+	draft.Source = rawURL
+	return draft, nil
+}
+
+// imports schema.org Recipe markup of uploaded page
+func (s *recipeImportService) ImportFromHTML(ctx context.Context, page []byte) (*dto.RecipeImportDraftDTO, error) {
+	recipe, err := recipeimport.ParseHTML(page)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]recipeimport.Line, len(recipe.Ingredients))
+	for i, raw := range recipe.Ingredients {
+		lines[i] = recipeimport.ParseLine(raw)
+	}
+	draft, err := s.buildDraft(ctx, recipe.Name, lines)
+	if err != nil {
+		return nil, err
+	}
+	draft.Yield = recipe.Yield
+	return draft, nil
+}
+
+// imports ingredient lines like "2 cups flour", one per line
+func (s *recipeImportService) ImportFromText(ctx context.Context, name string, text string) (*dto.RecipeImportDraftDTO, error) {
+	lines := recipeimport.ParseText(text)
+	if len(lines) == 0 {
+		return nil, ErrImportSourceRequired
+	}
+	return s.buildDraft(ctx, strings.TrimSpace(name), lines)
+}
+
+// resolves lines to catalog ingredients and converts their quantities to grams
+func (s *recipeImportService) buildDraft(ctx context.Context, name string, lines []recipeimport.Line) (*dto.RecipeImportDraftDTO, error) {
+	catalog, err := s.matchIngredients(ctx, lines)
+	if err != nil {
+		return nil, err
+	}
+	draft := &dto.RecipeImportDraftDTO{
+		Name:  name,
+		Lines: make([]dto.RecipeImportLineDTO, len(lines)),
+		Recipe: dto.CreateRecipeRequestDTO{
+			Name:        name,
+			Ingredients: []dto.RecipeIngredientUsageRequestDTO{},
+		},
+	}
+	// catalog ingredient can appear on more lines, e.g. sugar for dough and for topping
+	usageIndex := make(map[string]int)
+	usageGrams := make(map[string]uint)
+	for i, line := range lines {
+		lineDTO := dto.RecipeImportLineDTO{
+			Line:     line.Raw,
+			Quantity: line.Quantity,
+			Unit:     string(line.Unit),
+			Name:     line.Name,
+			Status:   ImportLineUnmatched,
+		}
+		ingredient := findCatalogIngredient(catalog, line.Name)
+		if ingredient == nil {
+			draft.Lines[i] = lineDTO
+			continue
+		}
+		usage, grams, status := importedUsage(line, ingredient)
+		lineDTO.Status = status
+		lineDTO.Ingredient = &dto.RecipeIngredientDetailDTO{
+			ID:       ingredient.ID,
+			Name:     ingredient.Name,
+			Weight:   grams,
+			Quantity: line.Quantity,
+			Unit:     string(line.Unit),
+			Calories: uint(ingredient.CaloriesPerGram * float64(grams)),
+		}
+		draft.Lines[i] = lineDTO
+		if grams == 0 {
+			continue
+		}
+		if index, ok := usageIndex[ingredient.Name]; ok {
+			// quantities in different units can't be added up, merged usage falls back to grams
+			usageGrams[ingredient.Name] += grams
+			draft.Recipe.Ingredients[index] = dto.RecipeIngredientUsageRequestDTO{
+				Name:   ingredient.Name,
+				Weight: usageGrams[ingredient.Name],
+			}
+			continue
+		}
+		usageIndex[ingredient.Name] = len(draft.Recipe.Ingredients)
+		usageGrams[ingredient.Name] = grams
+		draft.Recipe.Ingredients = append(draft.Recipe.Ingredients, usage)
+	}
+	return draft, nil
+}
+
+// converts line to recipe usage, quantity and unit are kept when the ingredient can be converted exactly
+func importedUsage(line recipeimport.Line, ingredient *models.Ingredient) (dto.RecipeIngredientUsageRequestDTO, uint, string) {
+	usage := dto.RecipeIngredientUsageRequestDTO{Name: ingredient.Name}
+	if line.Quantity == 0 {
+		return usage, 0, ImportLineNeedsWeight
+	}
+	grams, err := units.ToGrams(line.Quantity, line.Unit, ingredientConversion(ingredient))
+	if err == nil && math.Round(grams) >= 1 {
+		usage.Quantity = line.Quantity
+		usage.Unit = string(line.Unit)
+		return usage, uint(math.Round(grams)), ImportLineMatched
+	}
+	if errors.Is(err, units.ErrDensityUnknown) {
+		grams, err = units.ToGrams(line.Quantity, line.Unit, units.Conversion{Density: fallbackDensity})
+		if err == nil && math.Round(grams) >= 1 {
+			usage.Weight = uint(math.Round(grams))
+			return usage, usage.Weight, ImportLineEstimated
+		}
+	}
+	return usage, 0, ImportLineNeedsWeight
+}
+
+// loads catalog ingredients for all name candidates of all lines in one query, keyed by lower case name
+func (s *recipeImportService) matchIngredients(ctx context.Context, lines []recipeimport.Line) (map[string]*models.Ingredient, error) {
+	var candidates []string
+	for _, line := range lines {
+		candidates = append(candidates, ingredientNameCandidates(line.Name)...)
+	}
+	catalog := make(map[string]*models.Ingredient)
+	if len(candidates) == 0 {
+		return catalog, nil
+	}
+	ingredients, err := s.ingredientRepo.GetIngredientsByNamesIgnoreCase(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match ingredients %w", err)
+	}
+	// catalog names win over aliases of other ingredients
+	for _, ingredient := range ingredients {
+		for _, alias := range ingredient.Aliases {
+			catalog[strings.ToLower(alias.Name)] = ingredient
+		}
+	}
+	for _, ingredient := range ingredients {
+		catalog[strings.ToLower(ingredient.Name)] = ingredient
+	}
+	return catalog, nil
+}
+
+// the most specific candidate wins, "red onions" is preferred over "onions"
+func findCatalogIngredient(catalog map[string]*models.Ingredient, name string) *models.Ingredient {
+	for _, candidate := range ingredientNameCandidates(name) {
+		if ingredient, ok := catalog[candidate]; ok {
+			return ingredient
+		}
+	}
+	return nil
+}
+
+// "large brown eggs" gives large brown eggs, large brown egg, brown eggs, brown egg, eggs, egg
+func ingredientNameCandidates(name string) []string {
+	words := strings.Fields(strings.ToLower(name))
+	var candidates []string
+	for i := range words {
+		suffix := strings.Join(words[i:], " ")
+		candidates = append(candidates, suffix)
+		if singular := singularize(suffix); singular != suffix {
+			candidates = append(candidates, singular)
+		}
+	}
+	return candidates
+}
+
+// naive english singular of the last word, good enough for ingredient names
+func singularize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "oes"),
+		strings.HasSuffix(name, "ches"),
+		strings.HasSuffix(name, "shes"),
+		strings.HasSuffix(name, "sses"),
+		strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
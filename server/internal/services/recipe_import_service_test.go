@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/models"
+	"foodgenie/internal/recipeimport"
+	"foodgenie/internal/repositories"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type stubFetcher struct {
+	pages map[string]string
+	err   error
+}
+
+func (f *stubFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	page, ok := f.pages[rawURL]
+	if !ok {
+		return nil, errors.New("page returned status 404")
+	}
+	return []byte(page), nil
+}
+
+// catalog looked up by lower case names, other methods panic through the nil interface
+type fakeIngredientRepository struct {
+	repositories.IngredientRepository
+	ingredients []*models.Ingredient
+	// writes of imports
+	batches int
+	updates []repositories.IngredientUpdate
+}
+
+func (r *fakeIngredientRepository) GetIngredientsByNamesIgnoreCase(ctx context.Context, names []string) ([]*models.Ingredient, error) {
+	var found []*models.Ingredient
+	for _, ingredient := range r.ingredients {
+		match := slices.Contains(names, strings.ToLower(ingredient.Name))
+		for _, alias := range ingredient.Aliases {
+			match = match || slices.Contains(names, strings.ToLower(alias.Name))
+		}
+		if match {
+			found = append(found, ingredient)
+		}
+	}
+	return found, nil
+}
+
+const pancakePage = `<html><head><script type="application/ld+json">
+{"@context":"https://schema.org","@type":"Recipe","name":"Pancakes","recipeYield":"4",
+ "recipeIngredient":["200 g flour","2 large eggs","1 cup milk","1 tbsp honey","salt to taste","1 tsp sugar","1 vanilla pod"]}
+</script></head></html>`
+
+func newRecipeImportTestService(fetcher recipeimport.Fetcher) RecipeImportService {
+	return NewRecipeImportService(&fakeIngredientRepository{ingredients: []*models.Ingredient{
+		{Name: "Flour", CaloriesPerGram: 3.64},
+		{Name: "Egg", CaloriesPerGram: 1.43, PieceWeight: 50},
+		{Name: "Milk", CaloriesPerGram: 0.42, Density: 1.03},
+		// density unknown, volume is estimated as water
+		{Name: "Honey", CaloriesPerGram: 3.04},
+		{Name: "Salt"},
+		{Name: "Sugar", CaloriesPerGram: 3.87, Density: 0.85, Aliases: []models.IngredientAlias{{Name: "granulated sugar"}}},
+	}}, fetcher)
+}
+
+func TestImportFromURL(t *testing.T) {
+	service := newRecipeImportTestService(&stubFetcher{pages: map[string]string{"https://example.com/pancakes": pancakePage}})
+
+	draft, err := service.ImportFromURL(context.Background(), "https://example.com/pancakes")
+	if err != nil {
+		t.Fatalf("ImportFromURL: %v", err)
+	}
+	if draft.Name != "Pancakes" || draft.Yield != "4" || draft.Source != "https://example.com/pancakes" {
+		t.Fatalf("unexpected draft %+v", draft)
+	}
+	wantStatus := []string{ImportLineMatched, ImportLineMatched, ImportLineMatched, ImportLineEstimated, ImportLineNeedsWeight, ImportLineMatched, ImportLineUnmatched}
+	for i, line := range draft.Lines {
+		if line.Status != wantStatus[i] {
+			t.Errorf("line %q status = %s, want %s", line.Line, line.Status, wantStatus[i])
+		}
+	}
+	wantWeights := map[string]uint{"Flour": 200, "Egg": 100, "Milk": 244, "Honey": 15, "Sugar": 4}
+	if len(draft.Recipe.Ingredients) != len(wantWeights) {
+		t.Fatalf("recipe ingredients = %+v, want %d", draft.Recipe.Ingredients, len(wantWeights))
+	}
+	for _, line := range draft.Lines {
+		if line.Ingredient != nil && line.Ingredient.Weight != wantWeights[line.Ingredient.Name] {
+			t.Errorf("line %q weighs %d g, want %d g", line.Line, line.Ingredient.Weight, wantWeights[line.Ingredient.Name])
+		}
+	}
+	// estimated weight is not exact, the usage is saved in grams instead of the entered measure
+	if honey := draft.Recipe.Ingredients[3]; honey.Weight != 15 || honey.Unit != "" {
+		t.Errorf("honey usage = %+v, want 15 g", honey)
+	}
+	if milk := draft.Recipe.Ingredients[2]; milk.Quantity != 1 || milk.Unit != "cup" {
+		t.Errorf("milk usage = %+v, want 1 cup", milk)
+	}
+}
+
+func TestImportFromURLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		fetcher *stubFetcher
+		want    error
+	}{
+		{name: "fetch fails", fetcher: &stubFetcher{err: errors.New("connection refused")}, want: ErrImportSourceUnavailable},
+		{name: "page too large", fetcher: &stubFetcher{err: recipeimport.ErrPageTooLarge}, want: recipeimport.ErrPageTooLarge},
+		{name: "no recipe", fetcher: &stubFetcher{pages: map[string]string{"https://example.com/": "<html></html>"}}, want: recipeimport.ErrNoRecipe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRecipeImportTestService(tt.fetcher).ImportFromURL(context.Background(), "https://example.com/")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportFromTextMergesRepeatedIngredient(t *testing.T) {
+	service := newRecipeImportTestService(&stubFetcher{})
+
+	draft, err := service.ImportFromText(context.Background(), " Cake ", "100 g granulated sugar\n2 tbsp sugar")
+	if err != nil {
+		t.Fatalf("ImportFromText: %v", err)
+	}
+	if draft.Name != "Cake" || len(draft.Recipe.Ingredients) != 1 {
+		t.Fatalf("unexpected draft %+v", draft)
+	}
+	if sugar := draft.Recipe.Ingredients[0]; sugar.Name != "Sugar" || sugar.Weight != 125 || sugar.Unit != "" {
+		t.Fatalf("merged usage = %+v, want 125 g of Sugar", sugar)
+	}
+	if _, err := service.ImportFromText(context.Background(), "Cake", " \n "); !errors.Is(err, ErrImportSourceRequired) {
+		t.Fatalf("empty text: err = %v, want ErrImportSourceRequired", err)
+	}
+}
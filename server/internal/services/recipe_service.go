@@ -5,10 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/locale"
 	"foodgenie/internal/models"
 	"foodgenie/internal/repositories"
+	"foodgenie/internal/units"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type recipeService struct {
@@ -17,7 +23,44 @@ type recipeService struct {
 }
 type RecipeService interface {
 	CreateRecipe(ctx context.Context, req *dto.CreateRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error)
-	GetRecipeByName(ctx context.Context, name string) (*dto.RecipeDetailResponseDTO, error)
+	// viewerID is nil for anonymous requests
+	GetRecipeByName(ctx context.Context, name string, viewerID *uuid.UUID) (*dto.RecipeDetailResponseDTO, error)
+	GetRecipeByID(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID) (*dto.RecipeDetailResponseDTO, error)
+	ListRecipes(ctx context.Context, query *dto.RecipeListQueryDTO, viewerID *uuid.UUID) (*dto.PaginatedRecipesResponseDTO, error)
+	// ownerID is nil when managing the global catalog
+	ReplaceRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID, req *dto.CreateRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error)
+	PatchRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID, req *dto.PatchRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error)
+	DeleteRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID) error
+	GetRecipeVersions(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID) ([]dto.RecipeVersionDTO, error)
+	// results are stored only when requested, for own recipes of the viewer
+	ScaleRecipe(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID, manageCatalog bool, req *dto.ScaleRecipeRequestDTO) (*dto.AdjustedRecipeResponseDTO, error)
+	SubstituteIngredient(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID, manageCatalog bool, req *dto.SubstituteIngredientRequestDTO) (*dto.AdjustedRecipeResponseDTO, error)
+}
+
+const (
+	defaultRecipePageSize = 20
+	maxRecipePageSize     = 100
+)
+
+var (
+	ErrInvalidRecipeSort       = errors.New("invalid sort, use name, calories, weight or created with optional - prefix")
+	ErrInvalidRecipeVisibility = errors.New("visibility must be private, shared or public, catalog recipes are always public")
+	ErrRecipeNotFound          = errors.New("recipe not found")
+	ErrInvalidCookedWeight     = errors.New("cooked weight of the recipe must be at least one gram")
+	ErrRecipeIncomplete        = errors.New("recipe name and at least one named ingredient are required")
+	ErrRecipeNameTaken         = errors.New("recipe with this name already exists")
+	ErrInvalidPortion          = errors.New("invalid portion")
+	// the same ingredient listed on more lines is merged, unless the lines override yield factor differently
+	ErrConflictingIngredientLines = errors.New("ingredient is listed more times with different yield factors")
+)
+
+// ingredients of the request missing in the catalog, all of them are reported at once
+type UnknownIngredientsError struct {
+	Names []string
+}
+
+func (e *UnknownIngredientsError) Error() string {
+	return "unknown ingredients: " + strings.Join(e.Names, ", ")
 }
 
 func NewRecipeService(recipeRepo repositories.RecipeRepository, ingredientRepo repositories.IngredientRepository) RecipeService {
@@ -29,80 +72,189 @@ func NewRecipeService(recipeRepo repositories.RecipeRepository, ingredientRepo r
 
 // Creates recipe from CreateRecipeRequestDTO
 func (s *recipeService) CreateRecipe(ctx context.Context, req *dto.CreateRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error) {
-	if req.Name == "" || len(req.Ingredients) == 0 {
-		return nil, errors.New("recipe name and at least one ingredient are required")
-	}
-	recipeToCreate, ingModels, err := s.buildRecipeFromDTO(ctx, req)
+	visibility, err := resolveRecipeVisibility(req.OwnerID, req.Visibility)
 	if err != nil {
-		return nil, errors.New("could not prepare model for creation " + err.Error())
+		return nil, err
 	}
-	createdRecipe, err := s.recipeRepo.CreateRecipe(recipeToCreate)
+	recipeToCreate, err := s.buildRecipeFromDTO(ctx, req)
 	if err != nil {
-		return nil, errors.New("failed to create recipe " + err.Error())
-	}
-	ingMap := make(map[uuid.UUID]*models.Ingredient)
-	for _, ing := range ingModels {
-		ingMap[ing.ID] = ing
+		return nil, err
 	}
-	//hydrate Recipe with Ingredients
-	for _, usage := range createdRecipe.IngredientUsages {
-		usage.Ingredient = *ingMap[usage.IngredientID]
+	recipeToCreate.OwnerID = req.OwnerID
+	recipeToCreate.Visibility = visibility
+	createdRecipe, err := s.recipeRepo.CreateRecipe(ctx, recipeToCreate)
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrRecipeNameTaken
+		}
+		return nil, fmt.Errorf("failed to create recipe %w", err)
 	}
-	recipeDTO := mapRecipeToDTO(createdRecipe)
+	recipeDTO := mapRecipeToDTO(createdRecipe, locale.Languages(ctx))
 	return recipeDTO, nil
 }
 
-// Builds Recipe model from CreateRecipeRequestDTO
-func (s *recipeService) buildRecipeFromDTO(ctx context.Context, req *dto.CreateRecipeRequestDTO) (*models.Recipe, []*models.Ingredient, error) {
-	var totalCalories uint
-	var totalWeight uint
-	var ingUsages []models.RecipeIngredientUsage
+// Builds Recipe model from CreateRecipeRequestDTO, lines of the same ingredient are merged into one usage
+func (s *recipeService) buildRecipeFromDTO(ctx context.Context, req *dto.CreateRecipeRequestDTO) (*models.Recipe, error) {
+	if strings.TrimSpace(req.Name) == "" || len(req.Ingredients) == 0 {
+		return nil, ErrRecipeIncomplete
+	}
 	var ingNames []string
 	for _, recipeIng := range req.Ingredients {
-		fmt.Println("Ingredient name: ", recipeIng.Name)
 		if recipeIng.Name == "" {
-			return nil, nil, fmt.Errorf("empty ingredient name")
+			return nil, ErrRecipeIncomplete
 		}
 		ingNames = append(ingNames, recipeIng.Name)
 	}
 	ingredientModels, err := s.ingredientRepo.GetIngredientsByNames(ctx, ingNames)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get ingredients %w", err)
+		return nil, fmt.Errorf("failed to get ingredients %w", err)
 	}
-	ingredientModelsMap := make(map[string]*models.Ingredient)
-	for _, ing := range ingredientModels {
-		ingredientModelsMap[ing.Name] = ing
+	// requests may use any alias, e.g. courgette for zucchini
+	ingredientModelsMap := matchIngredientNames(ingredientModels, ingNames)
+	unknown := &UnknownIngredientsError{}
+	for _, name := range ingNames {
+		if _, ok := ingredientModelsMap[name]; !ok && !slices.Contains(unknown.Names, name) {
+			unknown.Names = append(unknown.Names, name)
+		}
+	}
+	if len(unknown.Names) > 0 {
+		return nil, unknown
 	}
+	var ingUsages []models.RecipeIngredientUsage
+	usageIndex := make(map[uuid.UUID]int)
+	// yield factors given explicitly in the request, ingredient default is used for the rest
+	yieldOverrides := make(map[uuid.UUID]float64)
 	for _, ing := range req.Ingredients {
-		ingModel, ok := ingredientModelsMap[ing.Name]
-		if !ok {
-			return nil, nil, fmt.Errorf("master ingredient not found in database: %s", ing.Name)
+		ingModel := ingredientModelsMap[ing.Name]
+		weight, quantity, unit, err := normalizeQuantity(ing.Weight, ing.Quantity, ing.Unit, ingredientConversion(ingModel))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of %s: %w", ing.Name, err)
+		}
+		if ing.YieldFactor > 0 {
+			if previous, ok := yieldOverrides[ingModel.ID]; ok && previous != ing.YieldFactor {
+				return nil, fmt.Errorf("%w: %s", ErrConflictingIngredientLines, ing.Name)
+			}
+			yieldOverrides[ingModel.ID] = ing.YieldFactor
+		}
+		if index, ok := usageIndex[ingModel.ID]; ok {
+			mergeIngredientUsage(&ingUsages[index], weight, quantity, unit)
+			continue
+		}
+		usageIndex[ingModel.ID] = len(ingUsages)
+		// ingredient is kept on usage for the version snapshot and the response
+		ingUsages = append(ingUsages, models.RecipeIngredientUsage{
+			Weight:       weight,
+			Quantity:     quantity,
+			Unit:         string(unit),
+			IngredientID: ingModel.ID,
+			Ingredient:   *ingModel,
+		})
+	}
+	for i := range ingUsages {
+		usage := &ingUsages[i]
+		usage.YieldFactor = usage.Ingredient.YieldFactor
+		if override, ok := yieldOverrides[usage.IngredientID]; ok {
+			usage.YieldFactor = override
+		}
+		if usage.YieldFactor <= 0 {
+			usage.YieldFactor = 1
 		}
-		totalCalories += uint(float64(ing.Weight) * ingModel.CaloriesPerGram)
-		totalWeight += ing.Weight
-		ingUsages = append(ingUsages, models.RecipeIngredientUsage{Weight: ing.Weight, IngredientID: ingModel.ID})
+	}
+	portions, err := buildRecipePortions(req.Portions)
+	if err != nil {
+		return nil, err
+	}
+	servings := req.Servings
+	if servings == 0 {
+		servings = 1
 	}
 	recipeToCreate := models.Recipe{
 		Name:             req.Name,
 		IngredientUsages: ingUsages,
-		Weight:           totalWeight,
-		Calories:         totalCalories,
+		CookedWeight:     req.CookedWeight,
 		Volume:           req.Volume,
+		Servings:         servings,
+		Portions:         portions,
+	}
+	if err := applyRecipeTotals(&recipeToCreate); err != nil {
+		return nil, err
+	}
+	return &recipeToCreate, nil
+}
+
+// adds another line of the same ingredient, quantities in different units can't be added up and fall back to grams
+func mergeIngredientUsage(usage *models.RecipeIngredientUsage, weight uint, quantity float64, unit units.Unit) {
+	usage.Weight += weight
+	if usage.Unit == string(unit) {
+		usage.Quantity += quantity
+		return
+	}
+	usage.Quantity = float64(usage.Weight)
+	usage.Unit = string(units.Gram)
+}
+
+// computes cached calories and weights of recipe from its usages, usages must be hydrated with ingredients
+func applyRecipeTotals(recipe *models.Recipe) error {
+	var totalCalories uint
+	var rawWeight uint
+	var cookedWeight float64
+	for _, usage := range recipe.IngredientUsages {
+		totalCalories += uint(float64(usage.Weight) * usage.Ingredient.CaloriesPerGram)
+		rawWeight += usage.Weight
+		cookedWeight += float64(usage.Weight) * usage.YieldFactor
+	}
+	// calories stay with the dish when water evaporates or is absorbed, only the weight changes
+	finishedWeight := uint(math.Round(cookedWeight))
+	if recipe.CookedWeight > 0 {
+		finishedWeight = recipe.CookedWeight
+	}
+	if finishedWeight == 0 {
+		return ErrInvalidCookedWeight
 	}
-	return &recipeToCreate, ingredientModels, nil
+	recipe.Calories = totalCalories
+	recipe.RawWeight = rawWeight
+	recipe.Weight = finishedWeight
+	return nil
+}
+
+// portion names must be unique and must not clash with units meals can be logged in
+func buildRecipePortions(portionDTOs []dto.RecipePortionDTO) ([]models.RecipePortion, error) {
+	portions := make([]models.RecipePortion, 0, len(portionDTOs))
+	seen := make(map[string]bool)
+	for _, portion := range portionDTOs {
+		name := strings.ToLower(strings.TrimSpace(portion.Name))
+		if name == "" || portion.Weight == 0 {
+			return nil, fmt.Errorf("%w: portion name and weight are required", ErrInvalidPortion)
+		}
+		if _, err := units.Parse(name); err == nil || isServingUnit(name) {
+			return nil, fmt.Errorf("%w: portion name %q is reserved", ErrInvalidPortion, portion.Name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate portion %q", ErrInvalidPortion, portion.Name)
+		}
+		seen[name] = true
+		portions = append(portions, models.RecipePortion{Name: name, Weight: portion.Weight})
+	}
+	return portions, nil
 }
 
 // mapRecipeToResponseDTO maps Recipe to RecipeDetailResponseDTO
-func mapRecipeToDTO(recipe *models.Recipe) *dto.RecipeDetailResponseDTO {
+// ingredient names are shown in the first of the languages they are translated to
+func mapRecipeToDTO(recipe *models.Recipe, languages []string) *dto.RecipeDetailResponseDTO {
 
 	var ingredientsDTOS []dto.RecipeIngredientDetailDTO
 	for _, usage := range recipe.IngredientUsages {
 		calories := uint(usage.Ingredient.CaloriesPerGram * float64(usage.Weight))
+		quantity, unit := displayQuantity(usage.Weight, usage.Quantity, usage.Unit)
 		ingredientDTO := dto.RecipeIngredientDetailDTO{
-			ID:       usage.Ingredient.ID,
-			Name:     usage.Ingredient.Name,
-			Weight:   usage.Weight,
-			Calories: calories,
+			ID:          usage.Ingredient.ID,
+			Name:        localizedName(&usage.Ingredient, languages),
+			Weight:      usage.Weight,
+			Quantity:    quantity,
+			Unit:        unit,
+			YieldFactor: usage.YieldFactor,
+			Calories:    calories,
+			Allergens:   usage.Ingredient.Allergens,
 		}
 		ingredientsDTOS = append(ingredientsDTOS, ingredientDTO)
 	}
@@ -114,16 +266,356 @@ func mapRecipeToDTO(recipe *models.Recipe) *dto.RecipeDetailResponseDTO {
 		TotalWeight:   recipe.Weight,
 		TotalCalories: recipe.Calories,
 		Volume:        recipe.Volume,
+		Version:       recipe.Version,
+		OwnerID:       recipe.OwnerID,
+		Visibility:    recipe.Visibility,
+		Servings:      recipe.Servings,
+		RawWeight:     recipeRawWeight(recipe),
+		CookedWeight:  recipe.CookedWeight,
+		Allergens:     recipeAllergens(recipe),
+		DietTags:      recipeDietTags(recipe),
+	}
+	servingWeight := recipeServingWeight(recipe)
+	recipeDTO.ServingWeight = uint(math.Round(servingWeight))
+	recipeDTO.CaloriesPerServing = uint(math.Round(recipeCaloriesPerGram(recipe) * servingWeight))
+	for _, portion := range recipe.Portions {
+		recipeDTO.Portions = append(recipeDTO.Portions, dto.RecipePortionDetailDTO{
+			Name:     portion.Name,
+			Weight:   portion.Weight,
+			Calories: uint(math.Round(recipeCaloriesPerGram(recipe) * float64(portion.Weight))),
+		})
 	}
 	return recipeDTO
 }
 
+// recipes created before servings were introduced count as one serving
+func recipeServingWeight(recipe *models.Recipe) float64 {
+	if recipe.Servings == 0 {
+		return float64(recipe.Weight)
+	}
+	return float64(recipe.Weight) / float64(recipe.Servings)
+}
+
+// recipes created before cooking yield was introduced weigh the same raw and finished
+func recipeRawWeight(recipe *models.Recipe) uint {
+	if recipe.RawWeight == 0 {
+		return recipe.Weight
+	}
+	return recipe.RawWeight
+}
+
+// calories per gram of the finished dish
+func recipeCaloriesPerGram(recipe *models.Recipe) float64 {
+	if recipe.Weight == 0 {
+		return 0
+	}
+	return float64(recipe.Calories) / float64(recipe.Weight)
+}
+
+// global recipes and shared or public ones are visible to everyone, private ones only to the owner
+func canViewRecipe(recipe *models.Recipe, viewerID *uuid.UUID) bool {
+	if recipe.OwnerID == nil || recipe.Visibility != models.RecipeVisibilityPrivate {
+		return true
+	}
+	return viewerID != nil && *viewerID == *recipe.OwnerID
+}
+
+// own recipes default to private, global recipes are always public
+func resolveRecipeVisibility(ownerID *uuid.UUID, requested string) (string, error) {
+	if ownerID == nil {
+		if requested != "" && requested != models.RecipeVisibilityPublic {
+			return "", ErrInvalidRecipeVisibility
+		}
+		return models.RecipeVisibilityPublic, nil
+	}
+	if requested == "" {
+		return models.RecipeVisibilityPrivate, nil
+	}
+	if !models.IsValidRecipeVisibility(requested) {
+		return "", ErrInvalidRecipeVisibility
+	}
+	return requested, nil
+}
+
+// fetches recipe which can be modified in given scope, catalog scope (nil owner) covers only global recipes
+func (s *recipeService) getManagedRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID) (*models.Recipe, error) {
+	recipe, err := s.recipeRepo.GetRecipeByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching recipe %w", err)
+	}
+	if !canManageRecipe(recipe, ownerID) {
+		return nil, ErrRecipeNotFound
+	}
+	return recipe, nil
+}
+func canManageRecipe(recipe *models.Recipe, ownerID *uuid.UUID) bool {
+	if ownerID == nil || recipe.OwnerID == nil {
+		return ownerID == nil && recipe.OwnerID == nil
+	}
+	return *ownerID == *recipe.OwnerID
+}
+
 // fetches Recipe from Database
-func (s *recipeService) GetRecipeByName(ctx context.Context, name string) (*dto.RecipeDetailResponseDTO, error) {
-	recipeModel, err := s.recipeRepo.GetRecipeByName(ctx, name)
+func (s *recipeService) GetRecipeByName(ctx context.Context, name string, viewerID *uuid.UUID) (*dto.RecipeDetailResponseDTO, error) {
+	recipeModel, err := s.recipeRepo.GetRecipeByName(ctx, name, viewerID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching recipe %w", err)
 	}
-	recipeDTO := mapRecipeToDTO(recipeModel)
+	recipeDTO := mapRecipeToDTO(recipeModel, locale.Languages(ctx))
 	return recipeDTO, nil
 }
+
+// fetches Recipe by ID
+func (s *recipeService) GetRecipeByID(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID) (*dto.RecipeDetailResponseDTO, error) {
+	recipeModel, err := s.recipeRepo.GetRecipeByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching recipe %w", err)
+	}
+	if !canViewRecipe(recipeModel, viewerID) {
+		return nil, ErrRecipeNotFound
+	}
+	return mapRecipeToDTO(recipeModel, locale.Languages(ctx)), nil
+}
+
+// lists recipes with search, sorting and pagination
+func (s *recipeService) ListRecipes(ctx context.Context, query *dto.RecipeListQueryDTO, viewerID *uuid.UUID) (*dto.PaginatedRecipesResponseDTO, error) {
+	if query.Sort != "" && !repositories.IsValidRecipeSort(query.Sort) {
+		return nil, ErrInvalidRecipeSort
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultRecipePageSize
+	}
+	if query.PageSize > maxRecipePageSize {
+		query.PageSize = maxRecipePageSize
+	}
+	recipes, totalCount, err := s.recipeRepo.ListRecipes(ctx, repositories.RecipeFilter{
+		Query:     strings.TrimSpace(query.Query),
+		Sort:      query.Sort,
+		Page:      query.Page,
+		PageSize:  query.PageSize,
+		ViewerID:  viewerID,
+		OwnedOnly: query.Mine,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recipes %w", err)
+	}
+	items := make([]dto.RecipeListItemDTO, len(recipes))
+	for i, recipe := range recipes {
+		items[i] = dto.RecipeListItemDTO{
+			ID:            recipe.ID,
+			Name:          recipe.Name,
+			OwnerID:       recipe.OwnerID,
+			Visibility:    recipe.Visibility,
+			TotalWeight:   recipe.Weight,
+			TotalCalories: recipe.Calories,
+			Volume:        recipe.Volume,
+			CreatedAt:     recipe.CreatedAt,
+		}
+	}
+	return &dto.PaginatedRecipesResponseDTO{
+		Recipes:    items,
+		TotalCount: totalCount,
+		Page:       query.Page,
+		PageSize:   query.PageSize,
+	}, nil
+}
+
+// replaces name, volume and ingredients of the recipe, totals are recomputed
+func (s *recipeService) ReplaceRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID, req *dto.CreateRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error) {
+	existing, err := s.getManagedRecipe(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Visibility == "" {
+		req.Visibility = existing.Visibility
+	}
+	return s.saveRecipe(ctx, existing, req)
+}
+
+// updates only fields present in the request
+func (s *recipeService) PatchRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID, req *dto.PatchRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error) {
+	existing, err := s.getManagedRecipe(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	merged := recipeToRequestDTO(existing)
+	if req.Name != nil {
+		merged.Name = *req.Name
+	}
+	if req.Volume != nil {
+		merged.Volume = *req.Volume
+	}
+	if req.Ingredients != nil {
+		merged.Ingredients = req.Ingredients
+	}
+	if req.Visibility != nil {
+		merged.Visibility = *req.Visibility
+	}
+	if req.Servings != nil {
+		merged.Servings = *req.Servings
+	}
+	if req.Portions != nil {
+		merged.Portions = req.Portions
+	}
+	if req.CookedWeight != nil {
+		merged.CookedWeight = *req.CookedWeight
+	}
+	return s.saveRecipe(ctx, existing, merged)
+}
+
+// request recreating the recipe, saveRecipe validates and normalizes it again
+func recipeToRequestDTO(recipe *models.Recipe) *dto.CreateRecipeRequestDTO {
+	req := &dto.CreateRecipeRequestDTO{
+		Name:         recipe.Name,
+		Volume:       recipe.Volume,
+		Visibility:   recipe.Visibility,
+		Servings:     recipe.Servings,
+		CookedWeight: recipe.CookedWeight,
+	}
+	for _, portion := range recipe.Portions {
+		req.Portions = append(req.Portions, dto.RecipePortionDTO{Name: portion.Name, Weight: portion.Weight})
+	}
+	for _, usage := range recipe.IngredientUsages {
+		req.Ingredients = append(req.Ingredients, dto.RecipeIngredientUsageRequestDTO{
+			Name:        usage.Ingredient.Name,
+			Weight:      usage.Weight,
+			Quantity:    usage.Quantity,
+			Unit:        usage.Unit,
+			YieldFactor: usage.YieldFactor,
+		})
+	}
+	return req
+}
+func (s *recipeService) saveRecipe(ctx context.Context, existing *models.Recipe, req *dto.CreateRecipeRequestDTO) (*dto.RecipeDetailResponseDTO, error) {
+	visibility, err := resolveRecipeVisibility(existing.OwnerID, req.Visibility)
+	if err != nil {
+		return nil, err
+	}
+	recipeToSave, err := s.buildRecipeFromDTO(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	recipeToSave.BaseModel = existing.BaseModel
+	recipeToSave.OwnerID = existing.OwnerID
+	recipeToSave.Visibility = visibility
+	savedRecipe, err := s.recipeRepo.UpdateRecipe(ctx, recipeToSave)
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrRecipeNameTaken
+		}
+		return nil, fmt.Errorf("failed to update recipe %w", err)
+	}
+	return mapRecipeToDTO(savedRecipe, locale.Languages(ctx)), nil
+}
+
+// soft deletes recipe, meals logged with it stay visible
+func (s *recipeService) DeleteRecipe(ctx context.Context, id uuid.UUID, ownerID *uuid.UUID) error {
+	if _, err := s.getManagedRecipe(ctx, id, ownerID); err != nil {
+		return err
+	}
+	if err := s.recipeRepo.DeleteRecipe(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete recipe %w", err)
+	}
+	return nil
+}
+
+// lists versions of the recipe with ingredient and total differences to the previous version
+func (s *recipeService) GetRecipeVersions(ctx context.Context, id uuid.UUID, viewerID *uuid.UUID) ([]dto.RecipeVersionDTO, error) {
+	recipeModel, err := s.recipeRepo.GetRecipeByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching recipe %w", err)
+	}
+	if !canViewRecipe(recipeModel, viewerID) {
+		return nil, ErrRecipeNotFound
+	}
+	versions, err := s.recipeRepo.GetRecipeVersions(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recipe versions %w", err)
+	}
+	return mapRecipeVersionsToDTO(versions, locale.Languages(ctx)), nil
+}
+
+// versions must be ordered from the oldest, each one carries differences to the previous one
+func mapRecipeVersionsToDTO(versions []*models.RecipeVersion, languages []string) []dto.RecipeVersionDTO {
+	versionDTOs := make([]dto.RecipeVersionDTO, len(versions))
+	for i, version := range versions {
+		versionDTOs[i] = mapRecipeVersionToDTO(version, languages)
+		if i > 0 {
+			versionDTOs[i].Changes = diffRecipeVersions(&versionDTOs[i-1], &versionDTOs[i])
+		}
+	}
+	return versionDTOs
+}
+func mapRecipeVersionToDTO(version *models.RecipeVersion, languages []string) dto.RecipeVersionDTO {
+	ingredients := make([]dto.RecipeIngredientDetailDTO, len(version.Ingredients))
+	for i, ing := range version.Ingredients {
+		quantity, unit := displayQuantity(ing.Weight, ing.Quantity, ing.Unit)
+		name, ok := preferredAlias(ing.Aliases, languages)
+		if !ok {
+			name = ing.Name
+		}
+		ingredients[i] = dto.RecipeIngredientDetailDTO{
+			ID:       ing.IngredientID,
+			Name:     name,
+			Weight:   ing.Weight,
+			Quantity: quantity,
+			Unit:     unit,
+			Calories: uint(ing.CaloriesPerGram * float64(ing.Weight)),
+		}
+	}
+	return dto.RecipeVersionDTO{
+		Version:       version.Number,
+		Name:          version.Name,
+		Ingredients:   ingredients,
+		TotalWeight:   version.Weight,
+		RawWeight:     version.RawWeight,
+		TotalCalories: version.Calories,
+		Volume:        version.Volume,
+		CreatedAt:     version.CreatedAt,
+	}
+}
+func diffRecipeVersions(previous, current *dto.RecipeVersionDTO) *dto.RecipeVersionDiffDTO {
+	diff := &dto.RecipeVersionDiffDTO{
+		AddedIngredients:   []dto.RecipeIngredientDetailDTO{},
+		RemovedIngredients: []dto.RecipeIngredientDetailDTO{},
+		ChangedIngredients: []dto.RecipeIngredientChangeDTO{},
+		WeightDelta:        int(current.TotalWeight) - int(previous.TotalWeight),
+		CaloriesDelta:      int(current.TotalCalories) - int(previous.TotalCalories),
+		VolumeDelta:        current.Volume - previous.Volume,
+	}
+	if previous.Name != current.Name {
+		diff.PreviousName = previous.Name
+	}
+	previousByID := make(map[uuid.UUID]dto.RecipeIngredientDetailDTO)
+	for _, ing := range previous.Ingredients {
+		previousByID[ing.ID] = ing
+	}
+	for _, ing := range current.Ingredients {
+		old, ok := previousByID[ing.ID]
+		if !ok {
+			diff.AddedIngredients = append(diff.AddedIngredients, ing)
+			continue
+		}
+		delete(previousByID, ing.ID)
+		if old.Weight != ing.Weight || old.Calories != ing.Calories {
+			diff.ChangedIngredients = append(diff.ChangedIngredients, dto.RecipeIngredientChangeDTO{
+				ID:               ing.ID,
+				Name:             ing.Name,
+				PreviousWeight:   old.Weight,
+				Weight:           ing.Weight,
+				PreviousCalories: old.Calories,
+				Calories:         ing.Calories,
+			})
+		}
+	}
+	// keep order of the previous version for removed ingredients
+	for _, ing := range previous.Ingredients {
+		if _, ok := previousByID[ing.ID]; ok {
+			diff.RemovedIngredients = append(diff.RemovedIngredients, ing)
+		}
+	}
+	return diff
+}
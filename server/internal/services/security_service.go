@@ -1,6 +1,11 @@
 package services
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"foodgenie/internal/config"
@@ -8,24 +13,31 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type securityService struct {
-	Config config.JWTConfig
+	Config          config.JWTConfig
+	TwoFactorConfig config.TwoFactorConfig
 }
 type SecurityService interface {
 	ComparePasswordAndHash(password, hashedPassword string) error
 	GenerateHashFromPassword(password string) (string, error)
-	GenerateAccessToken(user *models.User) (string, error)
-	GenerateRefreshToken(user *models.User) (string, error)
+	GenerateAccessToken(user *models.User, sessionID uuid.UUID) (string, error)
+	GenerateRefreshToken(user *models.User, sessionID uuid.UUID) (string, error)
 	ValidateAccessToken(tokenString string) (*CustomClaims, error)
 	ValidateRefreshToken(tokenString string) (*CustomClaims, error)
+	GenerateTwoFactorChallengeToken(user *models.User, challengeID uuid.UUID) (string, error)
+	ValidateTwoFactorChallengeToken(tokenString string) (*CustomClaims, error)
+	EncryptSecret(plaintext string) (string, error)
+	DecryptSecret(ciphertext string) (string, error)
 }
 
-func NewSecurityService(cfg config.JWTConfig) SecurityService {
+func NewSecurityService(cfg config.JWTConfig, twoFactorCfg config.TwoFactorConfig) SecurityService {
 	return &securityService{
-		Config: cfg,
+		Config:          cfg,
+		TwoFactorConfig: twoFactorCfg,
 	}
 
 }
@@ -41,21 +53,45 @@ func (s *securityService) ComparePasswordAndHash(password, hashedPassword string
 }
 
 type CustomClaims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	// empty for access and refresh tokens
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (s *securityService) GenerateAccessToken(user *models.User) (string, error) {
-	return s.generateToken(user, s.Config.AccessTokenDuration, s.Config.AccessTokenSecret)
+const twoFactorChallengePurpose = "2fa_challenge"
+
+func (s *securityService) GenerateAccessToken(user *models.User, sessionID uuid.UUID) (string, error) {
+	return s.generateToken(user, sessionID, s.Config.AccessTokenDuration, s.Config.AccessTokenSecret, "")
+}
+func (s *securityService) GenerateRefreshToken(user *models.User, sessionID uuid.UUID) (string, error) {
+	return s.generateToken(user, sessionID, s.Config.RefreshTokenDuration, s.Config.RefreshTokenSecret, "")
 }
-func (s *securityService) GenerateRefreshToken(user *models.User) (string, error) {
-	return s.generateToken(user, s.Config.RefreshTokenDuration, s.Config.RefreshTokenSecret)
+
+// generates short lived token proving that password step of the login succeeded,
+// challenge ID is carried as jti
+func (s *securityService) GenerateTwoFactorChallengeToken(user *models.User, challengeID uuid.UUID) (string, error) {
+	claims := s.newClaims(user, uuid.Nil, s.TwoFactorConfig.ChallengeDuration, twoFactorChallengePurpose)
+	claims.ID = challengeID.String()
+	return signToken(claims, s.Config.AccessTokenSecret)
 }
 
 // generates JWT token
-func (*securityService) generateToken(user *models.User, duration time.Duration, secretKey string) (string, error) {
-	claims := &CustomClaims{
-		UserID: user.ID.String(),
+func (s *securityService) generateToken(user *models.User, sessionID uuid.UUID, duration time.Duration, secretKey string, purpose string) (string, error) {
+	return signToken(s.newClaims(user, sessionID, duration, purpose), secretKey)
+}
+func (*securityService) newClaims(user *models.User, sessionID uuid.UUID, duration time.Duration, purpose string) *CustomClaims {
+	var sid string
+	if sessionID != uuid.Nil {
+		sid = sessionID.String()
+	}
+	return &CustomClaims{
+		UserID:    user.ID.String(),
+		Role:      user.Role,
+		SessionID: sid,
+		Purpose:   purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -63,6 +99,8 @@ func (*securityService) generateToken(user *models.User, duration time.Duration,
 			Subject:   user.Username,
 		},
 	}
+}
+func signToken(claims *CustomClaims, secretKey string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, err := token.SignedString([]byte(secretKey))
 	if err != nil {
@@ -96,8 +134,77 @@ func validateAndExtractClaims(tokenString string, secretKey string) (*CustomClai
 	return claims, nil
 }
 func (s *securityService) ValidateAccessToken(tokenString string) (*CustomClaims, error) {
-	return validateAndExtractClaims(tokenString, s.Config.AccessTokenSecret)
+	claims, err := validateAndExtractClaims(tokenString, s.Config.AccessTokenSecret)
+	if err != nil {
+		return nil, err
+	}
+	// challenge tokens share the secret with access tokens but must not grant access
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
 }
 func (s *securityService) ValidateRefreshToken(tokenString string) (*CustomClaims, error) {
-	return validateAndExtractClaims(tokenString, s.Config.RefreshTokenSecret)
+	claims, err := validateAndExtractClaims(tokenString, s.Config.RefreshTokenSecret)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+func (s *securityService) ValidateTwoFactorChallengeToken(tokenString string) (*CustomClaims, error) {
+	claims, err := validateAndExtractClaims(tokenString, s.Config.AccessTokenSecret)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != twoFactorChallengePurpose {
+		return nil, errors.New("invalid challenge token")
+	}
+	return claims, nil
+}
+
+// encrypts secret with AES-GCM, key is derived from TWO_FACTOR_ENCRYPTION_KEY
+func (s *securityService) EncryptSecret(plaintext string) (string, error) {
+	gcm, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+func (s *securityService) DecryptSecret(ciphertext string) (string, error) {
+	gcm, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret too short")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+func (s *securityService) secretCipher() (cipher.AEAD, error) {
+	if s.TwoFactorConfig.EncryptionKey == "" {
+		return nil, errors.New("secret encryption key is not configured")
+	}
+	key := sha256.Sum256([]byte(s.TwoFactorConfig.EncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
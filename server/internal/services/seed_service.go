@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+
+	"gorm.io/gorm"
+)
+
+type SeedService interface {
+	// nil when the dataset was never seeded
+	LatestSeedVersion(ctx context.Context, dataset string) (*models.SeedVersion, error)
+	RecordSeedVersion(ctx context.Context, version *models.SeedVersion) error
+}
+type seedService struct {
+	seedVersionRepo repositories.SeedVersionRepository
+}
+
+func NewSeedService(seedVersionRepo repositories.SeedVersionRepository) SeedService {
+	return &seedService{
+		seedVersionRepo: seedVersionRepo,
+	}
+}
+func (s *seedService) LatestSeedVersion(ctx context.Context, dataset string) (*models.SeedVersion, error) {
+	version, err := s.seedVersionRepo.GetLatestSeedVersion(ctx, dataset)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return version, err
+}
+func (s *seedService) RecordSeedVersion(ctx context.Context, version *models.SeedVersion) error {
+	return s.seedVersionRepo.CreateSeedVersion(ctx, version)
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"foodgenie/internal/totp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled     = errors.New("two-factor authentication enrollment not started")
+	ErrTwoFactorNotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode     = errors.New("invalid two-factor code")
+	ErrInvalidChallengeToken    = errors.New("invalid or expired challenge token")
+	ErrTooManyTwoFactorAttempts = errors.New("too many invalid two-factor codes, sign in again")
+)
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, userID uuid.UUID) (*dto.TwoFactorEnrollResponseDTO, error)
+	Activate(ctx context.Context, userID uuid.UUID, code string) (*dto.TwoFactorRecoveryCodesResponseDTO, error)
+	Disable(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, req *dto.TwoFactorDisableRequestDTO) error
+	StartLogin(ctx context.Context, user *models.User) (string, error)
+	CompleteLogin(ctx context.Context, req *dto.TwoFactorLoginRequestDTO) (*models.User, error)
+}
+type twoFactorService struct {
+	userRepo         repositories.UserRepository
+	twoFactorRepo    repositories.TwoFactorRepository
+	securityService  SecurityService
+	identityVerifier identityVerifier
+	cfg              config.TwoFactorConfig
+}
+
+func NewTwoFactorService(userRepo repositories.UserRepository, twoFactorRepo repositories.TwoFactorRepository, sessionRepo repositories.SessionRepository, securityService SecurityService, cfg config.TwoFactorConfig, accountCfg config.AccountConfig) TwoFactorService {
+	return &twoFactorService{
+		userRepo:         userRepo,
+		twoFactorRepo:    twoFactorRepo,
+		securityService:  securityService,
+		identityVerifier: identityVerifier{securityService: securityService, sessionRepo: sessionRepo, window: accountCfg.ReauthenticationWindow},
+		cfg:              cfg,
+	}
+}
+
+// generates new TOTP secret for user, 2FA stays inactive until Activate
+func (s *twoFactorService) Enroll(ctx context.Context, userID uuid.UUID) (*dto.TwoFactorEnrollResponseDTO, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %w", err)
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := s.securityService.EncryptSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret %w", err)
+	}
+	user.TwoFactorSecret = encrypted
+	if err := s.userRepo.UpdateUser(user); err != nil {
+		return nil, fmt.Errorf("failed to save secret %w", err)
+	}
+	return &dto.TwoFactorEnrollResponseDTO{
+		Secret:     secret,
+		OTPAuthURI: totp.KeyURI(s.cfg.Issuer, user.Username, secret),
+	}, nil
+}
+
+// confirms enrollment with first code and returns recovery codes, they are shown only once
+func (s *twoFactorService) Activate(ctx context.Context, userID uuid.UUID, code string) (*dto.TwoFactorRecoveryCodesResponseDTO, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %w", err)
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err := s.verifyCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.EnableTwoFactor(ctx, user.ID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication %w", err)
+	}
+	return &dto.TwoFactorRecoveryCodesResponseDTO{RecoveryCodes: codes}, nil
+}
+
+// turns 2FA off, requires both password and current code, accounts without password need a recent login instead
+func (s *twoFactorService) Disable(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, req *dto.TwoFactorDisableRequestDTO) error {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user %w", err)
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := s.identityVerifier.verify(ctx, user, req.Password, sessionID); err != nil {
+		return err
+	}
+	if err := s.verifyCode(ctx, user, req.Code); err != nil {
+		return err
+	}
+	if err := s.twoFactorRepo.DisableTwoFactor(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication %w", err)
+	}
+	return nil
+}
+
+// first step of login succeeded, stores challenge and returns token for the second step
+func (s *twoFactorService) StartLogin(ctx context.Context, user *models.User) (string, error) {
+	challenge := &models.TwoFactorChallenge{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.cfg.ChallengeDuration),
+	}
+	if err := s.twoFactorRepo.CreateChallenge(ctx, challenge); err != nil {
+		return "", fmt.Errorf("failed to store challenge %w", err)
+	}
+	return s.securityService.GenerateTwoFactorChallengeToken(user, challenge.ID)
+}
+
+// second step of login, verifies challenge token and code, every attempt counts against the challenge
+func (s *twoFactorService) CompleteLogin(ctx context.Context, req *dto.TwoFactorLoginRequestDTO) (*models.User, error) {
+	claims, err := s.securityService.ValidateTwoFactorChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, ErrInvalidChallengeToken
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidChallengeToken
+	}
+	challengeID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidChallengeToken
+	}
+	challenge, err := s.twoFactorRepo.CountChallengeAttempt(ctx, challengeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidChallengeToken
+		}
+		return nil, fmt.Errorf("failed to fetch challenge %w", err)
+	}
+	if challenge.UserID != userID {
+		return nil, ErrInvalidChallengeToken
+	}
+	if challenge.Attempts > uint(s.cfg.MaxAttempts) {
+		if err := s.twoFactorRepo.DeleteChallenge(ctx, challenge.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete challenge %w", err)
+		}
+		return nil, ErrTooManyTwoFactorAttempts
+	}
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %w", err)
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	switch {
+	case req.Code != "":
+		if err := s.verifyCode(ctx, user, req.Code); err != nil {
+			return nil, err
+		}
+	case req.RecoveryCode != "":
+		if err := s.twoFactorRepo.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(req.RecoveryCode)); err != nil {
+			return nil, ErrInvalidTwoFactorCode
+		}
+	default:
+		return nil, ErrInvalidTwoFactorCode
+	}
+	// challenge is single use
+	if err := s.twoFactorRepo.DeleteChallenge(ctx, challenge.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete challenge %w", err)
+	}
+	return user, nil
+}
+
+// code is accepted once, its time step is recorded so it can't be replayed within the skew window
+func (s *twoFactorService) verifyCode(ctx context.Context, user *models.User, code string) error {
+	secret, err := s.securityService.DecryptSecret(user.TwoFactorSecret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secret %w", err)
+	}
+	step, ok := totp.ValidateStep(secret, code, time.Now())
+	if !ok || step <= user.TwoFactorLastStep {
+		return ErrInvalidTwoFactorCode
+	}
+	// concurrent request with the same code might have recorded the step already
+	if err := s.twoFactorRepo.UseTimeStep(ctx, user.ID, step); err != nil {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TwoFactorLastStep = step
+	return nil
+}
+
+// returns plain codes for the user and their hashes for the database
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code %w", err)
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		codes[i] = raw[:4] + "-" + raw[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// codes are compared case and dash insensitive
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
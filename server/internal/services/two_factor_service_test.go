@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"foodgenie/internal/totp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *fakeUserRepository) GetUserById(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// keeps 2FA state on the users of fakeUserRepository
+type fakeTwoFactorRepository struct {
+	repositories.TwoFactorRepository
+	users      *fakeUserRepository
+	challenges map[uuid.UUID]*models.TwoFactorChallenge
+}
+
+func (r *fakeTwoFactorRepository) UseTimeStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	for _, user := range r.users.users {
+		if user.ID == userID && user.TwoFactorLastStep < step {
+			user.TwoFactorLastStep = step
+			return nil
+		}
+	}
+	return errors.New("time step already used")
+}
+
+func (r *fakeTwoFactorRepository) CreateChallenge(ctx context.Context, challenge *models.TwoFactorChallenge) error {
+	challenge.ID = uuid.New()
+	r.challenges[challenge.ID] = challenge
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) CountChallengeAttempt(ctx context.Context, id uuid.UUID) (*models.TwoFactorChallenge, error) {
+	challenge, ok := r.challenges[id]
+	if !ok || challenge.ExpiresAt.Before(time.Now()) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	challenge.Attempts++
+	copied := *challenge
+	return &copied, nil
+}
+
+func (r *fakeTwoFactorRepository) DeleteChallenge(ctx context.Context, id uuid.UUID) error {
+	delete(r.challenges, id)
+	return nil
+}
+
+func newTwoFactorTestService(t *testing.T) (TwoFactorService, *models.User, string) {
+	t.Helper()
+	cfg := config.TwoFactorConfig{
+		Issuer:            "FoodGenie",
+		EncryptionKey:     "0123456789abcdef0123456789abcdef",
+		ChallengeDuration: 5 * time.Minute,
+		MaxAttempts:       3,
+	}
+	securityService := NewSecurityService(config.JWTConfig{AccessTokenSecret: "access-secret-0123456789abcdef0123"}, cfg)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := securityService.EncryptSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Username: "anna", TwoFactorSecret: encrypted, TwoFactorEnabled: true}
+	user.ID = uuid.New()
+	users := &fakeUserRepository{users: []*models.User{user}}
+	twoFactorRepo := &fakeTwoFactorRepository{users: users, challenges: map[uuid.UUID]*models.TwoFactorChallenge{}}
+	return NewTwoFactorService(users, twoFactorRepo, nil, securityService, cfg, config.AccountConfig{}), user, secret
+}
+
+func TestCompleteLoginRejectsReplayedCode(t *testing.T) {
+	service, user, secret := newTwoFactorTestService(t)
+	ctx := context.Background()
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := service.StartLogin(ctx, user)
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	if _, err := service.CompleteLogin(ctx, &dto.TwoFactorLoginRequestDTO{ChallengeToken: token, Code: code}); err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	// challenge is single use
+	if _, err := service.CompleteLogin(ctx, &dto.TwoFactorLoginRequestDTO{ChallengeToken: token, Code: code}); !errors.Is(err, ErrInvalidChallengeToken) {
+		t.Fatalf("reused challenge: err = %v, want ErrInvalidChallengeToken", err)
+	}
+	token, err = service.StartLogin(ctx, user)
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	if _, err := service.CompleteLogin(ctx, &dto.TwoFactorLoginRequestDTO{ChallengeToken: token, Code: code}); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("replayed code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestCompleteLoginLimitsAttempts(t *testing.T) {
+	service, user, secret := newTwoFactorTestService(t)
+	ctx := context.Background()
+	token, err := service.StartLogin(ctx, user)
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := service.CompleteLogin(ctx, &dto.TwoFactorLoginRequestDTO{ChallengeToken: token, Code: "000000"}); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidTwoFactorCode", i+1, err)
+		}
+	}
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.CompleteLogin(ctx, &dto.TwoFactorLoginRequestDTO{ChallengeToken: token, Code: code}); !errors.Is(err, ErrTooManyTwoFactorAttempts) {
+		t.Fatalf("attempt over limit: err = %v, want ErrTooManyTwoFactorAttempts", err)
+	}
+	if _, err := service.CompleteLogin(ctx, &dto.TwoFactorLoginRequestDTO{ChallengeToken: token, Code: code}); !errors.Is(err, ErrInvalidChallengeToken) {
+		t.Fatalf("after limit: err = %v, want ErrInvalidChallengeToken", err)
+	}
+}
@@ -2,13 +2,23 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"foodgenie/internal/config"
 	"foodgenie/internal/dto"
+	"foodgenie/internal/mail"
 	"foodgenie/internal/models"
 	"foodgenie/internal/repositories"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UserService interface {
@@ -16,18 +26,49 @@ type UserService interface {
 	Authenticate(ctx context.Context, username string, password string) (*models.User, error)
 	GetUserByEmail(email string) (models.User, error)
 	GetUserByUsername(username string) (*dto.UserResponseDTO, error)
-	GetUserById(id uuid.UUID) (*dto.UserResponseDTO, error)
+	GetUserById(ctx context.Context, id uuid.UUID) (*dto.UserResponseDTO, error)
 	RefreshToken(ctx context.Context, req *dto.RefreshTokenRequestDTO) (*dto.LoginResponseDTO, error)
+	AuthenticateAccessToken(ctx context.Context, tokenString string) (*CustomClaims, error)
+	UpdateUserRole(ctx context.Context, id uuid.UUID, role string) (*dto.UserResponseDTO, error)
+	IssueTokens(ctx context.Context, user *models.User) (*dto.LoginResponseDTO, error)
+	UpdateProfile(ctx context.Context, id uuid.UUID, req *dto.UpdateProfileRequestDTO) (*dto.UserResponseDTO, error)
+	ChangePassword(ctx context.Context, id uuid.UUID, currentSessionID uuid.UUID, req *dto.ChangePasswordRequestDTO) error
+	RequestEmailChange(ctx context.Context, id uuid.UUID, currentSessionID uuid.UUID, req *dto.ChangeEmailRequestDTO) error
+	ConfirmEmailChange(ctx context.Context, req *dto.ConfirmEmailChangeRequestDTO) (*dto.UserResponseDTO, error)
 }
+
+const emailChangeDuration = 24 * time.Hour
+
+var (
+	ErrInvalidRole              = errors.New("invalid role")
+	ErrInvalidPassword          = errors.New("invalid password")
+	ErrInvalidDateOfBirth       = errors.New("date of birth can't be in the future")
+	ErrEmailTaken               = errors.New("email is already in use")
+	ErrEmailUnchanged           = errors.New("new email is the same as the current one")
+	ErrInvalidConfirmationToken = errors.New("invalid or expired confirmation token")
+	ErrInvalidAccessToken       = errors.New("invalid access token")
+	ErrSessionRevoked           = errors.New("session has been revoked or expired")
+)
+
 type userService struct {
-	userRepo        repositories.UserRepository
-	securityService SecurityService
+	userRepo             repositories.UserRepository
+	sessionRepo          repositories.SessionRepository
+	securityService      SecurityService
+	mailer               mail.Mailer
+	mailConfig           config.MailConfig
+	refreshTokenDuration time.Duration
+	identityVerifier     identityVerifier
 }
 
-func NewUserService(userRepo repositories.UserRepository, securityService SecurityService) UserService {
+func NewUserService(userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository, securityService SecurityService, mailer mail.Mailer, cfg *config.AppConfig) UserService {
 	return &userService{
-		userRepo:        userRepo,
-		securityService: securityService,
+		userRepo:             userRepo,
+		sessionRepo:          sessionRepo,
+		securityService:      securityService,
+		mailer:               mailer,
+		mailConfig:           cfg.Mail,
+		refreshTokenDuration: cfg.JWT.RefreshTokenDuration,
+		identityVerifier:     identityVerifier{securityService: securityService, sessionRepo: sessionRepo, window: cfg.Account.ReauthenticationWindow},
 	}
 }
 
@@ -57,19 +98,33 @@ func buildUserFromDTO(req *dto.RegisterUserRequestDTO) *models.User {
 		FirstName:   req.FirstName,
 		LastName:    req.LastName,
 		DateOfBirth: req.DateOfBirth,
+		Role:        models.RoleUser,
+		HasPassword: true,
 	}
 	return userModel
 }
 func mapUserToDTO(user *models.User) *dto.UserResponseDTO {
-	createdAtString := user.CreatedAt.Format(time.RFC3339)
+	var dateOfBirth string
+	if !user.DateOfBirth.IsZero() {
+		dateOfBirth = user.DateOfBirth.Format(time.DateOnly)
+	}
 	userDTO := &dto.UserResponseDTO{
 		ID:          user.ID,
 		Username:    user.Username,
 		Email:       user.Email,
 		FirstName:   user.FirstName,
 		LastName:    user.LastName,
-		DateOfBirth: createdAtString,
+		DateOfBirth: dateOfBirth,
 		CreatedAt:   user.CreatedAt,
+		Role:        user.Role,
+		HasPassword: user.HasPassword,
+		Preferences: dto.UserPreferencesDTO{
+			Language:         user.Preferences.Language,
+			UnitSystem:       user.Preferences.UnitSystem,
+			DailyCalorieGoal: user.Preferences.DailyCalorieGoal,
+			Allergies:        append([]string{}, user.Preferences.Allergies...),
+		},
+		DeletionScheduledAt: user.DeletionScheduledAt,
 	}
 	return userDTO
 }
@@ -79,9 +134,9 @@ func (s *userService) Authenticate(ctx context.Context, username string, passwor
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user %w", err)
 	}
-	// compare password with hash
+	// compare password with hash, accounts created by a provider sign in only through it until a password is set
 	err = s.securityService.ComparePasswordAndHash(password, userModel.Password)
-	if err != nil {
+	if err != nil || !userModel.HasPassword {
 		return nil, fmt.Errorf("invalid password")
 	}
 	return userModel, nil
@@ -96,14 +151,15 @@ func (s *userService) GetUserByUsername(username string) (*dto.UserResponseDTO,
 	}
 	return mapUserToDTO(userModel), err
 }
-func (s *userService) GetUserById(id uuid.UUID) (*dto.UserResponseDTO, error) {
-	userModel, err := s.userRepo.GetUserById(id)
+func (s *userService) GetUserById(ctx context.Context, id uuid.UUID) (*dto.UserResponseDTO, error) {
+	userModel, err := s.userRepo.GetUserById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return mapUserToDTO(userModel), err
 }
 
+// validates refresh token against its session and issues new pair within the same session
 func (s *userService) RefreshToken(ctx context.Context, req *dto.RefreshTokenRequestDTO) (*dto.LoginResponseDTO, error) {
 	claims, err := s.securityService.ValidateRefreshToken(req.RefreshToken)
 	if err != nil {
@@ -113,15 +169,94 @@ func (s *userService) RefreshToken(ctx context.Context, req *dto.RefreshTokenReq
 	if err != nil {
 		return nil, fmt.Errorf("invalid token %w", err)
 	}
-	user, err := s.userRepo.GetUserById(userID)
+	if claims.SessionID == "" {
+		return s.migrateLegacyRefreshToken(ctx, userID, req.RefreshToken)
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token %w", err)
+	}
+	session, err := s.sessionRepo.GetActiveSession(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return nil, ErrSessionRevoked
+	}
+	user, err := s.userRepo.GetUserById(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch user %w", err)
 	}
-	at, err := s.securityService.GenerateAccessToken(user)
+	if err := s.sessionRepo.ExtendSession(ctx, session.ID, time.Now().Add(s.refreshTokenDuration)); err != nil {
+		return nil, fmt.Errorf("failed to extend session %w", err)
+	}
+	return s.generateTokenPair(user, session.ID)
+}
+
+// refresh tokens issued before sessions were introduced carry no sid, each of them is exchanged once
+// for a session whose ID is derived from the token, so using it again hits the existing session
+func (s *userService) migrateLegacyRefreshToken(ctx context.Context, userID uuid.UUID, refreshToken string) (*dto.LoginResponseDTO, error) {
+	user, err := s.userRepo.GetUserById(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %w", err)
+	}
+	session := &models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(s.refreshTokenDuration)}
+	session.ID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(refreshToken))
+	if _, err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrSessionRevoked
+		}
+		return nil, fmt.Errorf("failed to create session %w", err)
+	}
+	return s.generateTokenPair(user, session.ID)
+}
+
+// validates access token and checks that its session is still active, so revoking sessions
+// takes effect before the token expires
+func (s *userService) AuthenticateAccessToken(ctx context.Context, tokenString string) (*CustomClaims, error) {
+	claims, err := s.securityService.ValidateAccessToken(tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAccessToken, err)
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user ID in token", ErrInvalidAccessToken)
+	}
+	// tokens issued before sessions were introduced can't be revoked, they expire within the access token duration
+	if claims.SessionID == "" {
+		return claims, nil
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid session ID in token", ErrInvalidAccessToken)
+	}
+	session, err := s.sessionRepo.GetActiveSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionRevoked
+		}
+		return nil, fmt.Errorf("failed to fetch session %w", err)
+	}
+	if session.UserID != userID {
+		return nil, ErrSessionRevoked
+	}
+	return claims, nil
+}
+
+// starts new session and issues token pair for it
+func (s *userService) IssueTokens(ctx context.Context, user *models.User) (*dto.LoginResponseDTO, error) {
+	session, err := s.sessionRepo.CreateSession(ctx, &models.Session{
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(s.refreshTokenDuration),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session %w", err)
+	}
+	return s.generateTokenPair(user, session.ID)
+}
+func (s *userService) generateTokenPair(user *models.User, sessionID uuid.UUID) (*dto.LoginResponseDTO, error) {
+	at, err := s.securityService.GenerateAccessToken(user, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token %w", err)
 	}
-	rt, err := s.securityService.GenerateRefreshToken(user)
+	rt, err := s.securityService.GenerateRefreshToken(user, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token %w", err)
 	}
@@ -131,3 +266,189 @@ func (s *userService) RefreshToken(ctx context.Context, req *dto.RefreshTokenReq
 	}
 	return response, nil
 }
+
+// applies only fields present in the request
+func (s *userService) UpdateProfile(ctx context.Context, id uuid.UUID, req *dto.UpdateProfileRequestDTO) (*dto.UserResponseDTO, error) {
+	userModel, err := s.userRepo.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.FirstName != nil {
+		userModel.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		userModel.LastName = *req.LastName
+	}
+	if req.DateOfBirth != nil {
+		if req.DateOfBirth.After(time.Now()) {
+			return nil, ErrInvalidDateOfBirth
+		}
+		userModel.DateOfBirth = *req.DateOfBirth
+	}
+	if prefs := req.Preferences; prefs != nil {
+		if prefs.Language != nil {
+			userModel.Preferences.Language = *prefs.Language
+		}
+		if prefs.UnitSystem != nil {
+			userModel.Preferences.UnitSystem = *prefs.UnitSystem
+		}
+		if prefs.DailyCalorieGoal != nil {
+			userModel.Preferences.DailyCalorieGoal = *prefs.DailyCalorieGoal
+		}
+		if prefs.Allergies != nil {
+			if userModel.Preferences.Allergies, err = normalizeTags(prefs.Allergies, models.Allergens); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := s.userRepo.UpdateUser(userModel); err != nil {
+		return nil, fmt.Errorf("failed to update user %w", err)
+	}
+	return mapUserToDTO(userModel), nil
+}
+
+// changes password and signs out every other session of the user,
+// accounts created by a provider set their first password after a recent login without the current one
+func (s *userService) ChangePassword(ctx context.Context, id uuid.UUID, currentSessionID uuid.UUID, req *dto.ChangePasswordRequestDTO) error {
+	userModel, err := s.userRepo.GetUserById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.identityVerifier.verify(ctx, userModel, req.CurrentPassword, currentSessionID); err != nil {
+		return err
+	}
+	hashedPassword, err := s.securityService.GenerateHashFromPassword(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to generate hash from password: %w", err)
+	}
+	userModel.Password = hashedPassword
+	userModel.HasPassword = true
+	if err := s.userRepo.UpdateUser(userModel); err != nil {
+		return fmt.Errorf("failed to update password %w", err)
+	}
+	if err := s.sessionRepo.RevokeOtherSessions(ctx, userModel.ID, currentSessionID); err != nil {
+		return fmt.Errorf("failed to revoke sessions %w", err)
+	}
+	return nil
+}
+
+// sends confirmation link to the new address, email is changed after confirmation
+func (s *userService) RequestEmailChange(ctx context.Context, id uuid.UUID, currentSessionID uuid.UUID, req *dto.ChangeEmailRequestDTO) error {
+	userModel, err := s.userRepo.GetUserById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.identityVerifier.verify(ctx, userModel, req.Password, currentSessionID); err != nil {
+		return err
+	}
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, userModel.Email) {
+		return ErrEmailUnchanged
+	}
+	if err := s.ensureEmailAvailable(newEmail); err != nil {
+		return err
+	}
+	token, tokenHash, err := generateConfirmationToken()
+	if err != nil {
+		return err
+	}
+	err = s.userRepo.CreateEmailChangeRequest(ctx, &models.EmailChangeRequest{
+		UserID:    userModel.ID,
+		NewEmail:  newEmail,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(emailChangeDuration),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store email change request %w", err)
+	}
+	link := strings.TrimSuffix(s.mailConfig.PublicBaseURL, "/") + "/confirm-email?token=" + token
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new FoodGenie email",
+		Body: fmt.Sprintf("Hi %s,\n\nconfirm your new email address by opening the link below:\n%s\n\nThe link expires in %s. If you didn't request this change, ignore this email.\n",
+			userModel.FirstName, link, emailChangeDuration),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send confirmation email %w", err)
+	}
+	return nil
+}
+
+// confirms email change with token from the confirmation email
+func (s *userService) ConfirmEmailChange(ctx context.Context, req *dto.ConfirmEmailChangeRequestDTO) (*dto.UserResponseDTO, error) {
+	changeRequest, err := s.userRepo.GetEmailChangeRequest(ctx, hashConfirmationToken(req.Token))
+	if err != nil {
+		return nil, ErrInvalidConfirmationToken
+	}
+	userModel, err := s.userRepo.GetUserById(ctx, changeRequest.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// address could have been taken since the request was made
+	if err := s.ensureEmailAvailable(changeRequest.NewEmail); err != nil {
+		return nil, err
+	}
+	oldEmail := userModel.Email
+	userModel.Email = changeRequest.NewEmail
+	if err := s.userRepo.UpdateUser(userModel); err != nil {
+		return nil, fmt.Errorf("failed to update email %w", err)
+	}
+	if err := s.userRepo.DeleteEmailChangeRequests(ctx, userModel.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete email change request %w", err)
+	}
+	// notice only, failure to deliver it shouldn't fail the change
+	if err := s.mailer.Send(ctx, mail.Message{
+		To:      oldEmail,
+		Subject: "Your FoodGenie email was changed",
+		Body:    fmt.Sprintf("Hi %s,\n\nthe email address of your account was changed to %s.\n", userModel.FirstName, userModel.Email),
+	}); err != nil {
+		slog.WarnContext(ctx, "failed to notify about email change", "email", oldEmail, "error", err)
+	}
+	return mapUserToDTO(userModel), nil
+}
+func (s *userService) ensureEmailAvailable(email string) error {
+	existing, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to check email %w", err)
+	}
+	if existing.ID != uuid.Nil {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func generateConfirmationToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashConfirmationToken(token), nil
+}
+func hashConfirmationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// changes role of the user, sessions are revoked so it takes effect at once
+func (s *userService) UpdateUserRole(ctx context.Context, id uuid.UUID, role string) (*dto.UserResponseDTO, error) {
+	if !models.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	userModel, err := s.userRepo.GetUserById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if userModel.Role == role {
+		return mapUserToDTO(userModel), nil
+	}
+	userModel.Role = role
+	if err := s.userRepo.UpdateUser(userModel); err != nil {
+		return nil, fmt.Errorf("failed to update user role %w", err)
+	}
+	// issued tokens carry the previous role, the user has to log in again
+	if err := s.sessionRepo.RevokeOtherSessions(ctx, userModel.ID, uuid.Nil); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions %w", err)
+	}
+	return mapUserToDTO(userModel), nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"foodgenie/internal/config"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/models"
+	"foodgenie/internal/repositories"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fakeSessionRepository struct {
+	repositories.SessionRepository
+	sessions map[uuid.UUID]*models.Session
+}
+
+func (r *fakeSessionRepository) CreateSession(ctx context.Context, session *models.Session) (*models.Session, error) {
+	if session.ID == uuid.Nil {
+		session.ID = uuid.New()
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	if _, ok := r.sessions[session.ID]; ok {
+		return nil, gorm.ErrDuplicatedKey
+	}
+	r.sessions[session.ID] = session
+	return session, nil
+}
+
+func (r *fakeSessionRepository) GetActiveSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok || session.RevokedAt != nil || session.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("session not found %w", gorm.ErrRecordNotFound)
+	}
+	return session, nil
+}
+
+func (r *fakeSessionRepository) ExtendSession(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	r.sessions[id].ExpiresAt = expiresAt
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, keepID uuid.UUID) error {
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.ID != keepID {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func newUserTestService() (UserService, SecurityService, *fakeSessionRepository, *models.User) {
+	cfg := &config.AppConfig{
+		JWT: config.JWTConfig{
+			AccessTokenSecret:    "access-secret-0123456789abcdef0123",
+			AccessTokenDuration:  15 * time.Minute,
+			RefreshTokenSecret:   "refresh-secret-0123456789abcdef012",
+			RefreshTokenDuration: time.Hour,
+		},
+		Account: config.AccountConfig{ReauthenticationWindow: 10 * time.Minute},
+	}
+	securityService := NewSecurityService(cfg.JWT, cfg.TwoFactor)
+	user := &models.User{Username: "anna", Role: models.RoleUser}
+	user.ID = uuid.New()
+	sessions := &fakeSessionRepository{sessions: map[uuid.UUID]*models.Session{}}
+	service := NewUserService(&fakeUserRepository{users: []*models.User{user}}, sessions, securityService, nil, cfg)
+	return service, securityService, sessions, user
+}
+
+func TestAuthenticateAccessTokenRejectsRevokedSession(t *testing.T) {
+	service, _, sessions, user := newUserTestService()
+	ctx := context.Background()
+	tokens, err := service.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	if _, err := service.AuthenticateAccessToken(ctx, tokens.AccessToken); err != nil {
+		t.Fatalf("AuthenticateAccessToken: %v", err)
+	}
+	if err := sessions.RevokeOtherSessions(ctx, user.ID, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.AuthenticateAccessToken(ctx, tokens.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("revoked session: err = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := service.AuthenticateAccessToken(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("refresh token as access token: err = %v, want ErrInvalidAccessToken", err)
+	}
+}
+
+func TestRefreshTokenMigratesLegacyTokenOnce(t *testing.T) {
+	service, securityService, _, user := newUserTestService()
+	ctx := context.Background()
+	// issued before sessions existed, it has no sid
+	legacy, err := securityService.GenerateRefreshToken(user, uuid.Nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := service.RefreshToken(ctx, &dto.RefreshTokenRequestDTO{RefreshToken: legacy})
+	if err != nil {
+		t.Fatalf("RefreshToken with legacy token: %v", err)
+	}
+	claims, err := securityService.ValidateRefreshToken(tokens.RefreshToken)
+	if err != nil || claims.SessionID == "" {
+		t.Fatalf("migrated token claims = %+v, %v, want session bound token", claims, err)
+	}
+	if _, err := service.RefreshToken(ctx, &dto.RefreshTokenRequestDTO{RefreshToken: legacy}); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("reused legacy token: err = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := service.RefreshToken(ctx, &dto.RefreshTokenRequestDTO{RefreshToken: tokens.RefreshToken}); err != nil {
+		t.Fatalf("RefreshToken with migrated token: %v", err)
+	}
+}
+
+func (r *fakeUserRepository) UpdateUser(user *models.User) error {
+	for i, existing := range r.users {
+		if existing.ID == user.ID {
+			r.users[i] = user
+		}
+	}
+	return nil
+}
+
+func TestUpdateUserRoleRevokesSessions(t *testing.T) {
+	service, _, _, user := newUserTestService()
+	ctx := context.Background()
+	tokens, err := service.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	if _, err := service.UpdateUserRole(ctx, user.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("UpdateUserRole: %v", err)
+	}
+	if _, err := service.AuthenticateAccessToken(ctx, tokens.AccessToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("token issued with the previous role: err = %v, want ErrSessionRevoked", err)
+	}
+}
+
+func TestChangePasswordSetsFirstPasswordAfterRecentLogin(t *testing.T) {
+	service, securityService, sessions, user := newUserTestService()
+	ctx := context.Background()
+	// created by signing in with a provider
+	user.HasPassword = false
+	tokens, err := service.IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	claims, err := securityService.ValidateRefreshToken(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := uuid.MustParse(claims.SessionID)
+	req := &dto.ChangePasswordRequestDTO{NewPassword: "first-password"}
+
+	sessions.sessions[sessionID].CreatedAt = time.Now().Add(-time.Hour)
+	if err := service.ChangePassword(ctx, user.ID, sessionID, req); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Fatalf("old login: err = %v, want ErrReauthenticationRequired", err)
+	}
+	sessions.sessions[sessionID].CreatedAt = time.Now()
+	if err := service.ChangePassword(ctx, user.ID, sessionID, req); err != nil {
+		t.Fatalf("ChangePassword after recent login: %v", err)
+	}
+	if _, err := service.Authenticate(ctx, user.Username, "first-password"); err != nil {
+		t.Fatalf("Authenticate with the first password: %v", err)
+	}
+	// from now on the password is required
+	if err := service.ChangePassword(ctx, user.ID, sessionID, req); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("without current password: err = %v, want ErrInvalidPassword", err)
+	}
+}
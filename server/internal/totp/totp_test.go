@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890" in base32
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCodeRFC6238Vectors(t *testing.T) {
+	// the RFC lists 8 digit codes, 6 digit codes are their last digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		code, err := GenerateCode(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("GenerateCode at %d: %v", tt.unix, err)
+		}
+		if code != tt.code {
+			t.Fatalf("GenerateCode at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+		if !Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0)) {
+			t.Fatalf("Validate rejected %s at %d", tt.code, tt.unix)
+		}
+	}
+}
+
+func TestValidateStepAcceptsSkew(t *testing.T) {
+	issued := time.Unix(1111111111, 0)
+	code, err := GenerateCode(rfcSecret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := issued.Unix() / Period
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same period", 0, true},
+		{"previous period", -Period * time.Second, true},
+		{"next period", Period * time.Second, true},
+		{"two periods early", -2 * Period * time.Second, false},
+		{"two periods late", 2 * Period * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateStep(rfcSecret, code, issued.Add(tt.offset))
+			if ok != tt.ok {
+				t.Fatalf("ValidateStep ok = %v, want %v", ok, tt.ok)
+			}
+			// the step of the code is reported, not the step of the validation time
+			if ok && got != step {
+				t.Fatalf("ValidateStep step = %d, want %d", got, step)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	if !Validate(rfcSecret, " 287082 ", now) {
+		t.Fatal("surrounding spaces must be ignored")
+	}
+	for _, code := range []string{"", "28708", "2870820", "94287082"} {
+		if Validate(rfcSecret, code, now) {
+			t.Fatalf("Validate accepted %q", code)
+		}
+	}
+	if Validate("not base32!", "287082", now) {
+		t.Fatal("Validate accepted invalid secret")
+	}
+}
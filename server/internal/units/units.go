@@ -0,0 +1,131 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Unit string
+
+const (
+	Gram       Unit = "g"
+	Kilogram   Unit = "kg"
+	Ounce      Unit = "oz"
+	Pound      Unit = "lb"
+	Millilitre Unit = "ml"
+	Litre      Unit = "l"
+	Cup        Unit = "cup"
+	Tablespoon Unit = "tbsp"
+	Teaspoon   Unit = "tsp"
+	Piece      Unit = "piece"
+)
+
+type Kind int
+
+const (
+	Mass Kind = iota
+	Volume
+	Count
+)
+
+var (
+	ErrUnknownUnit         = errors.New("unknown unit, use g, kg, oz, lb, ml, l, cup, tbsp, tsp or piece")
+	ErrDensityUnknown      = errors.New("density is unknown, use a mass unit")
+	ErrPieceWeightUnknown  = errors.New("piece weight is unknown, use a mass unit")
+	ErrNonPositiveQuantity = errors.New("quantity must be greater than zero")
+)
+
+type definition struct {
+	kind Kind
+	// grams for mass units, millilitres for volume units, pieces for count units
+	factor float64
+}
+
+var definitions = map[Unit]definition{
+	Gram:       {Mass, 1},
+	Kilogram:   {Mass, 1000},
+	Ounce:      {Mass, 28.349523125},
+	Pound:      {Mass, 453.59237},
+	Millilitre: {Volume, 1},
+	Litre:      {Volume, 1000},
+	// US customary measures
+	Cup:        {Volume, 236.5882365},
+	Tablespoon: {Volume, 14.78676478125},
+	Teaspoon:   {Volume, 4.92892159375},
+	Piece:      {Count, 1},
+}
+
+// spelled out and plural forms accepted in requests, canonical names are accepted as well
+var aliases = map[string]Unit{
+	"gram": Gram, "grams": Gram, "gr": Gram,
+	"kilogram": Kilogram, "kilograms": Kilogram, "kgs": Kilogram,
+	"ounce": Ounce, "ounces": Ounce,
+	"pound": Pound, "pounds": Pound, "lbs": Pound,
+	"millilitre": Millilitre, "millilitres": Millilitre, "milliliter": Millilitre, "milliliters": Millilitre,
+	"litre": Litre, "litres": Litre, "liter": Litre, "liters": Litre,
+	"cups": Cup, "cupful": Cup, "cupfuls": Cup,
+	"tablespoon": Tablespoon, "tablespoons": Tablespoon, "tbs": Tablespoon,
+	"teaspoon": Teaspoon, "teaspoons": Teaspoon,
+	"pieces": Piece, "pcs": Piece, "pc": Piece,
+}
+
+// measures of single food needed to convert volume and count units to grams, zero when unknown
+type Conversion struct {
+	// grams per millilitre
+	Density float64
+	// grams per piece
+	PieceWeight float64
+}
+
+// parses canonical unit name or its alias, case insensitive
+func Parse(name string) (Unit, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if _, ok := definitions[Unit(normalized)]; ok {
+		return Unit(normalized), nil
+	}
+	if unit, ok := aliases[normalized]; ok {
+		return unit, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownUnit, name)
+}
+
+// all names Parse accepts, used by text parsers
+func Names() map[string]Unit {
+	names := make(map[string]Unit, len(definitions)+len(aliases))
+	for unit := range definitions {
+		names[string(unit)] = unit
+	}
+	for alias, unit := range aliases {
+		names[alias] = unit
+	}
+	return names
+}
+
+func (u Unit) Kind() Kind {
+	return definitions[u].kind
+}
+
+// converts quantity to grams, volume and count units need density or piece weight of the food
+func ToGrams(quantity float64, unit Unit, conversion Conversion) (float64, error) {
+	if quantity <= 0 {
+		return 0, ErrNonPositiveQuantity
+	}
+	def, ok := definitions[unit]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownUnit, unit)
+	}
+	switch def.kind {
+	case Volume:
+		if conversion.Density <= 0 {
+			return 0, ErrDensityUnknown
+		}
+		return quantity * def.factor * conversion.Density, nil
+	case Count:
+		if conversion.PieceWeight <= 0 {
+			return 0, ErrPieceWeightUnknown
+		}
+		return quantity * def.factor * conversion.PieceWeight, nil
+	}
+	return quantity * def.factor, nil
+}
@@ -0,0 +1,78 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		unit Unit
+		err  error
+	}{
+		{"g", Gram, nil},
+		{"KG", Kilogram, nil},
+		{" tbsp ", Tablespoon, nil},
+		{"grams", Gram, nil},
+		{"Ounces", Ounce, nil},
+		{"lbs", Pound, nil},
+		{"milliliter", Millilitre, nil},
+		{"litres", Litre, nil},
+		{"cups", Cup, nil},
+		{"teaspoon", Teaspoon, nil},
+		{"pcs", Piece, nil},
+		{"piece", Piece, nil},
+		{"pinch", "", ErrUnknownUnit},
+		{"", "", ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, err := Parse(tt.name)
+			if unit != tt.unit || !errors.Is(err, tt.err) {
+				t.Fatalf("Parse(%q) = %q, %v, want %q, %v", tt.name, unit, err, tt.unit, tt.err)
+			}
+		})
+	}
+}
+
+func TestNamesMatchParse(t *testing.T) {
+	for name, unit := range Names() {
+		if parsed, err := Parse(name); err != nil || parsed != unit {
+			t.Fatalf("Parse(%q) = %q, %v, want %q", name, parsed, err, unit)
+		}
+	}
+}
+
+func TestToGrams(t *testing.T) {
+	tests := []struct {
+		name       string
+		quantity   float64
+		unit       Unit
+		conversion Conversion
+		grams      float64
+		err        error
+	}{
+		{"grams", 250, Gram, Conversion{}, 250, nil},
+		{"kilograms", 1.5, Kilogram, Conversion{}, 1500, nil},
+		{"pounds", 2, Pound, Conversion{}, 907.18474, nil},
+		{"mass ignores density", 1, Ounce, Conversion{Density: 2}, 28.349523125, nil},
+		{"litres with density", 0.5, Litre, Conversion{Density: 1.03}, 515, nil},
+		{"cup with density", 1, Cup, Conversion{Density: 0.53}, 125.391765345, nil},
+		{"pieces with piece weight", 3, Piece, Conversion{PieceWeight: 50}, 150, nil},
+		{"volume without density", 1, Cup, Conversion{PieceWeight: 50}, 0, ErrDensityUnknown},
+		{"pieces without piece weight", 2, Piece, Conversion{Density: 1}, 0, ErrPieceWeightUnknown},
+		{"zero quantity", 0, Gram, Conversion{}, 0, ErrNonPositiveQuantity},
+		{"negative quantity", -1, Gram, Conversion{}, 0, ErrNonPositiveQuantity},
+		{"unknown unit", 1, Unit("pinch"), Conversion{}, 0, ErrUnknownUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grams, err := ToGrams(tt.quantity, tt.unit, tt.conversion)
+			if !errors.Is(err, tt.err) || math.Abs(grams-tt.grams) > 1e-9 {
+				t.Fatalf("ToGrams(%v, %q) = %v, %v, want %v, %v", tt.quantity, tt.unit, grams, err, tt.grams, tt.err)
+			}
+		})
+	}
+}
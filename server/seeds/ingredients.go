@@ -0,0 +1,161 @@
+package seeds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/locale"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+func (s *Seeder) seedIngredients(ctx context.Context, data []byte, report *Report) error {
+	var ingredients []dto.CreateIngredientRequestDTO
+	if err := json.Unmarshal(data, &ingredients); err != nil {
+		return fmt.Errorf("failed to parse ingredients JSON: %w", err)
+	}
+	for _, ingredient := range ingredients {
+		change, err := s.upsertIngredient(ctx, ingredient)
+		if err := report.add(ctx, ingredient.Name, change, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nil change when the ingredient matches the seed
+func (s *Seeder) upsertIngredient(ctx context.Context, seed dto.CreateIngredientRequestDTO) (*Change, error) {
+	existing, err := s.app.IngredientService.GetIngredientByName(ctx, seed.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !s.options.DryRun {
+			if _, err := s.app.IngredientService.CreateIngredient(ctx, seed); err != nil {
+				return nil, err
+			}
+		}
+		return &Change{Action: ActionCreate, Name: seed.Name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	patch, diff := diffIngredient(existing, seed)
+	aliases := missingAliases(existing, seed.Aliases)
+	for _, alias := range aliases {
+		diff = append(diff, fmt.Sprintf("alias: +%s (%s)", alias.Name, aliasLocale(alias.Locale)))
+	}
+	if len(diff) == 0 {
+		return nil, nil
+	}
+	if !s.options.DryRun {
+		if patch != nil {
+			if _, err := s.app.IngredientService.PatchIngredient(ctx, existing.ID, *patch); err != nil {
+				return nil, err
+			}
+		}
+		for _, alias := range aliases {
+			if _, err := s.app.IngredientService.AddIngredientAlias(ctx, existing.ID, alias); err != nil {
+				return nil, fmt.Errorf("failed to add alias %s: %w", alias.Name, err)
+			}
+		}
+	}
+	return &Change{Action: ActionUpdate, Name: seed.Name, Diff: diff}, nil
+}
+
+// patch of fields differing from the seed, nil when there are none,
+// measures missing in the seed keep values entered by hand
+func diffIngredient(existing *dto.IngredientDTO, seed dto.CreateIngredientRequestDTO) (*dto.PatchIngredientRequestDTO, []string) {
+	var patch dto.PatchIngredientRequestDTO
+	var diff []string
+	if existing.CaloriesPerGram != seed.CaloriesPerGram {
+		patch.CaloriesPerGram = &seed.CaloriesPerGram
+		diff = append(diff, diffLine("caloriesPerGram", existing.CaloriesPerGram, seed.CaloriesPerGram))
+	}
+	if seed.Density > 0 && existing.Density != seed.Density {
+		patch.Density = &seed.Density
+		diff = append(diff, diffLine("density", existing.Density, seed.Density))
+	}
+	if seed.PieceWeight > 0 && existing.PieceWeight != seed.PieceWeight {
+		patch.PieceWeight = &seed.PieceWeight
+		diff = append(diff, diffLine("pieceWeight", existing.PieceWeight, seed.PieceWeight))
+	}
+	if seed.YieldFactor > 0 && existing.YieldFactor != seed.YieldFactor {
+		patch.YieldFactor = &seed.YieldFactor
+		diff = append(diff, diffLine("yieldFactor", existing.YieldFactor, seed.YieldFactor))
+	}
+	for _, nutrient := range []struct {
+		name     string
+		existing float64
+		seed     *float64
+		patch    **float64
+	}{
+		{"proteinPerGram", existing.ProteinPerGram, &seed.ProteinPerGram, &patch.ProteinPerGram},
+		{"fatPerGram", existing.FatPerGram, &seed.FatPerGram, &patch.FatPerGram},
+		{"carbohydratesPerGram", existing.CarbohydratesPerGram, &seed.CarbohydratesPerGram, &patch.CarbohydratesPerGram},
+		{"fiberPerGram", existing.FiberPerGram, &seed.FiberPerGram, &patch.FiberPerGram},
+		{"sugarPerGram", existing.SugarPerGram, &seed.SugarPerGram, &patch.SugarPerGram},
+		{"sodiumPerGram", existing.SodiumPerGram, &seed.SodiumPerGram, &patch.SodiumPerGram},
+	} {
+		if *nutrient.seed > 0 && nutrient.existing != *nutrient.seed {
+			*nutrient.patch = nutrient.seed
+			diff = append(diff, diffLine(nutrient.name, nutrient.existing, *nutrient.seed))
+		}
+	}
+	if seed.Allergens != nil && (!existing.AllergensTagged || !sameTags(existing.Allergens, seed.Allergens)) {
+		patch.Allergens = seed.Allergens
+		var previous any = existing.Allergens
+		if !existing.AllergensTagged {
+			previous = "untagged"
+		}
+		diff = append(diff, diffLine("allergens", previous, seed.Allergens))
+	}
+	if seed.DietTags != nil && !sameTags(existing.DietTags, seed.DietTags) {
+		patch.DietTags = seed.DietTags
+		diff = append(diff, diffLine("dietTags", existing.DietTags, seed.DietTags))
+	}
+	if len(diff) == 0 {
+		return nil, nil
+	}
+	return &patch, diff
+}
+
+// aliases of the seed the ingredient doesn't have, aliases added by hand are kept
+func missingAliases(existing *dto.IngredientDTO, aliases []dto.IngredientAliasRequestDTO) []dto.IngredientAliasRequestDTO {
+	var missing []dto.IngredientAliasRequestDTO
+	for _, alias := range aliases {
+		if !slices.ContainsFunc(existing.Aliases, func(current dto.IngredientAliasDTO) bool {
+			return strings.EqualFold(current.Name, alias.Name) && current.Locale == aliasLocale(alias.Locale)
+		}) {
+			missing = append(missing, alias)
+		}
+	}
+	return missing
+}
+func aliasLocale(tag string) string {
+	if tag == "" {
+		return locale.Default
+	}
+	return locale.Normalize(tag)
+}
+
+// tags are stored lower case in fixed order, the seed may list them in any
+func sameTags(existing []string, seed []string) bool {
+	normalized := make([]string, 0, len(seed))
+	for _, tag := range seed {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) != len(existing) {
+		return false
+	}
+	for _, tag := range normalized {
+		if !slices.Contains(existing, tag) {
+			return false
+		}
+	}
+	return true
+}
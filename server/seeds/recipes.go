@@ -0,0 +1,118 @@
+package seeds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"foodgenie/internal/dto"
+	"foodgenie/internal/units"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+func (s *Seeder) seedRecipes(ctx context.Context, data []byte, report *Report) error {
+	var recipes []dto.CreateRecipeRequestDTO
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		return fmt.Errorf("failed to parse recipes JSON: %w", err)
+	}
+	for _, recipe := range recipes {
+		change, err := s.upsertRecipe(ctx, &recipe)
+		if err := report.add(ctx, recipe.Name, change, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seeds are recipes of the global catalog, a changed recipe is replaced as a new version
+func (s *Seeder) upsertRecipe(ctx context.Context, seed *dto.CreateRecipeRequestDTO) (*Change, error) {
+	existing, err := s.app.RecipeService.GetRecipeByName(ctx, seed.Name, nil)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !s.options.DryRun {
+			if _, err := s.app.RecipeService.CreateRecipe(ctx, seed); err != nil {
+				return nil, err
+			}
+		}
+		return &Change{Action: ActionCreate, Name: seed.Name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	diff := diffRecipe(existing, seed)
+	if len(diff) == 0 {
+		return nil, nil
+	}
+	if !s.options.DryRun {
+		if _, err := s.app.RecipeService.ReplaceRecipe(ctx, existing.ID, nil, seed); err != nil {
+			return nil, err
+		}
+	}
+	return &Change{Action: ActionUpdate, Name: seed.Name, Diff: diff}, nil
+}
+func diffRecipe(existing *dto.RecipeDetailResponseDTO, seed *dto.CreateRecipeRequestDTO) []string {
+	var diff []string
+	if existing.Volume != seed.Volume {
+		diff = append(diff, diffLine("volume", existing.Volume, seed.Volume))
+	}
+	// both default to one serving
+	if max(existing.Servings, 1) != max(seed.Servings, 1) {
+		diff = append(diff, diffLine("servings", max(existing.Servings, 1), max(seed.Servings, 1)))
+	}
+	if existing.CookedWeight != seed.CookedWeight {
+		diff = append(diff, diffLine("cookedWeight", existing.CookedWeight, seed.CookedWeight))
+	}
+	var existingIngredients, seedIngredients []string
+	for _, ingredient := range existing.Ingredients {
+		existingIngredients = append(existingIngredients, describeUsage(ingredient.Name, ingredient.Weight, ingredient.Quantity, ingredient.Unit))
+	}
+	for _, ingredient := range seed.Ingredients {
+		seedIngredients = append(seedIngredients, describeUsage(ingredient.Name, ingredient.Weight, ingredient.Quantity, ingredient.Unit))
+	}
+	for _, removed := range subtract(existingIngredients, seedIngredients) {
+		diff = append(diff, "ingredient: -"+removed)
+	}
+	for _, added := range subtract(seedIngredients, existingIngredients) {
+		diff = append(diff, "ingredient: +"+added)
+	}
+	var existingPortions, seedPortions []string
+	for _, portion := range existing.Portions {
+		existingPortions = append(existingPortions, fmt.Sprintf("%s %dg", portion.Name, portion.Weight))
+	}
+	for _, portion := range seed.Portions {
+		seedPortions = append(seedPortions, fmt.Sprintf("%s %dg", portion.Name, portion.Weight))
+	}
+	for _, removed := range subtract(existingPortions, seedPortions) {
+		diff = append(diff, "portion: -"+removed)
+	}
+	for _, added := range subtract(seedPortions, existingPortions) {
+		diff = append(diff, "portion: +"+added)
+	}
+	return diff
+}
+
+// grams are compared by weight, other units by the quantity entered
+func describeUsage(name string, weight uint, quantity float64, unit string) string {
+	if parsed, err := units.Parse(unit); err == nil {
+		unit = string(parsed)
+	}
+	if quantity == 0 || unit == "" || unit == string(units.Gram) {
+		if weight == 0 {
+			weight = uint(quantity)
+		}
+		return fmt.Sprintf("%s %dg", name, weight)
+	}
+	return fmt.Sprintf("%s %g %s", name, quantity, unit)
+}
+
+// values of a missing in b
+func subtract(a []string, b []string) []string {
+	var missing []string
+	for _, value := range a {
+		if !slices.Contains(b, value) {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
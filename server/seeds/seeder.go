@@ -2,101 +2,169 @@ package seeds
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"foodgenie/internal/app"
-	"foodgenie/internal/dto"
-	"log"
-	"os"
+	"foodgenie/internal/models"
+	"slices"
 )
 
-func Seed(application *app.App) {
-	seeder := NewSeeder(application)
+//go:embed ingredients.json recipes.json
+var files embed.FS
 
-	ingredients, err := loadIngredients()
-	if err != nil {
-		log.Fatalf("Failed to load ingredients: %v", err)
-	}
+const (
+	DatasetIngredients = "ingredients"
+	DatasetRecipes     = "recipes"
+)
 
-	recipes, err := loadRecipes()
-	if err != nil {
-		log.Fatalf("Failed to load recipes: %v", err)
-	}
+// ingredients go first, recipes are built from them
+var Datasets = []string{DatasetIngredients, DatasetRecipes}
 
-	log.Println("Seeding ingredients...")
-	if err := seeder.seedIngredients(ingredients); err != nil {
-		log.Printf("Warning: Some ingredients failed to seed: %v", err)
-	}
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionFailed = "failed"
+)
 
-	log.Println("Seeding recipes...")
-	if err := seeder.seedRecipes(recipes); err != nil {
-		log.Printf("Warning: Some recipes failed to seed: %v", err)
-	}
+var ErrUnknownDataset = errors.New("unknown seed dataset, use ingredients or recipes")
 
-	log.Println("Seeding completed!")
+type Options struct {
+	// computes changes without writing them
+	DryRun bool
+	// seeds datasets whose file was already applied, e.g. after the catalog was edited by hand
+	Force bool
+	// datasets to seed, all when empty
+	Only []string
 }
-func loadIngredients() ([]*dto.CreateIngredientRequestDTO, error) {
-	data, err := os.ReadFile("seeds/ingredients.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read ingredients.json: %w", err)
-	}
 
-	var ingredients []*dto.CreateIngredientRequestDTO
+// change of one seeded item, unchanged items are only counted
+type Change struct {
+	Action string
+	Name   string
+	// changed fields as "field: old -> new"
+	Diff []string
+	Err  error
+}
 
-	err = json.Unmarshal(data, &ingredients)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse ingredients JSON: %w", err)
-	}
+type Report struct {
+	Dataset  string
+	Checksum string
+	// file is the latest applied version, nothing was compared
+	UpToDate bool
+	// latest version in the ledger, 0 when the dataset was never applied
+	Version   uint
+	Changes   []Change
+	Unchanged int
+}
 
-	return ingredients, nil
+func (r *Report) Count(action string) int {
+	count := 0
+	for _, change := range r.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
 }
 
-type seeder struct {
-	App *app.App
+// seeds the catalog from embedded files, items are matched by name so running it again only applies
+// what changed, items removed from the files stay in the catalog
+type Seeder struct {
+	app     *app.App
+	options Options
 }
 
-func NewSeeder(app *app.App) *seeder {
-	return &seeder{
-		App: app,
+func NewSeeder(application *app.App, options Options) *Seeder {
+	return &Seeder{
+		app:     application,
+		options: options,
 	}
 }
-func (s *seeder) seedIngredients(ingredients []*dto.CreateIngredientRequestDTO) error {
-	ctx := context.Background()
-	for _, ing := range ingredients {
-		_, err := s.App.IngredientService.CreateIngredient(ctx, *ing)
-		if err != nil {
-			log.Printf("Warning: Failed to create ingredient %s: %v", ing.Name, err)
+func (s *Seeder) Run(ctx context.Context) ([]Report, error) {
+	for _, dataset := range s.options.Only {
+		if !slices.Contains(Datasets, dataset) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDataset, dataset)
+		}
+	}
+	var reports []Report
+	for _, dataset := range Datasets {
+		if len(s.options.Only) > 0 && !slices.Contains(s.options.Only, dataset) {
 			continue
 		}
-		log.Printf("Created ingredient: %s", ing.Name)
+		report, err := s.seedDataset(ctx, dataset)
+		if err != nil {
+			return reports, fmt.Errorf("failed to seed %s: %w", dataset, err)
+		}
+		reports = append(reports, *report)
 	}
-	return nil
+	return reports, nil
 }
-func loadRecipes() ([]*dto.CreateRecipeRequestDTO, error) {
-	data, err := os.ReadFile("seeds/recipes.json")
+
+// ledger gets a new version when the file changed and all its items were applied
+func (s *Seeder) seedDataset(ctx context.Context, dataset string) (*Report, error) {
+	data, err := files.ReadFile(dataset + ".json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read recipes.json: %w", err)
+		return nil, err
 	}
-
-	var recipes []*dto.CreateRecipeRequestDTO
-
-	err = json.Unmarshal(data, &recipes)
+	sum := sha256.Sum256(data)
+	report := &Report{Dataset: dataset, Checksum: hex.EncodeToString(sum[:])}
+	latest, err := s.app.SeedService.LatestSeedVersion(ctx, dataset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse recipes JSON: %w", err)
+		return nil, err
 	}
-
-	return recipes, nil
+	if latest != nil {
+		report.Version = latest.Version
+		if latest.Checksum == report.Checksum && !s.options.Force {
+			report.UpToDate = true
+			return report, nil
+		}
+	}
+	switch dataset {
+	case DatasetIngredients:
+		err = s.seedIngredients(ctx, data, report)
+	case DatasetRecipes:
+		err = s.seedRecipes(ctx, data, report)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.options.DryRun || report.Count(ActionFailed) > 0 || (latest != nil && latest.Checksum == report.Checksum) {
+		return report, nil
+	}
+	version := &models.SeedVersion{
+		Dataset:   dataset,
+		Checksum:  report.Checksum,
+		Created:   report.Count(ActionCreate),
+		Updated:   report.Count(ActionUpdate),
+		Unchanged: report.Unchanged,
+	}
+	if err := s.app.SeedService.RecordSeedVersion(ctx, version); err != nil {
+		return nil, err
+	}
+	report.Version = version.Version
+	return report, nil
 }
-func (s *seeder) seedRecipes(recipes []*dto.CreateRecipeRequestDTO) error {
-	ctx := context.Background()
 
-	for _, recipe := range recipes {
-		_, err := s.App.RecipeService.CreateRecipe(ctx, recipe)
-		if err != nil {
-			log.Printf("Warning: Failed to create recipe %s: %v", recipe.Name, err)
-			continue
+// adds outcome of one item to the report, errors of the context stop seeding
+func (r *Report) add(ctx context.Context, name string, change *Change, err error) error {
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-		log.Printf("Created recipe: %s", recipe.Name)
+		r.Changes = append(r.Changes, Change{Action: ActionFailed, Name: name, Err: err})
+		return nil
+	}
+	if change == nil {
+		r.Unchanged++
+		return nil
 	}
+	r.Changes = append(r.Changes, *change)
 	return nil
 }
+
+func diffLine(field string, previous any, current any) string {
+	return fmt.Sprintf("%s: %v -> %v", field, previous, current)
+}